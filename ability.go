@@ -4,22 +4,29 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+
+	"github.com/asticode/go-astibob/brain"
 )
 
 // ability represents an ability as Bob knows it
 type ability struct {
-	key  string
-	isOn bool
-	m    sync.Mutex // Locks attributes
-	name string
+	api         bool
+	audioFormat *astibrain.AudioFormat
+	health      astibrain.HealthStatus
+	key         string
+	m           sync.Mutex // Locks attributes
+	name        string
+	state       astibrain.AbilityState
+	stats       *astibrain.AbilityStats
 }
 
 // newAbility creates a new ability
-func newAbility(name string, isOn bool) *ability {
+func newAbility(name string, state astibrain.AbilityState, health astibrain.HealthStatus) *ability {
 	return &ability{
-		key:  abilityKey(name),
-		isOn: isOn,
-		name: name,
+		health: health,
+		key:    abilityKey(name),
+		name:   name,
+		state:  state,
 	}
 }
 
@@ -30,3 +37,40 @@ var regexpAbilityKey = regexp.MustCompile("[^\\w]+")
 func abilityKey(name string) string {
 	return regexpAbilityKey.ReplaceAllString(strings.ToLower(name), "-")
 }
+
+// setState sets the ability state
+func (a *ability) setState(s astibrain.AbilityState) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.state = s
+}
+
+// setHealth sets the ability health
+func (a *ability) setHealth(h astibrain.HealthStatus) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.health = h
+}
+
+// setStats sets the ability stats
+func (a *ability) setStats(s astibrain.AbilityStats) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	a.stats = &s
+}
+
+// toAPI returns the API representation of the ability
+func (a *ability) toAPI() APIAbility {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return APIAbility{
+		API:         a.api,
+		AudioFormat: a.audioFormat,
+		Health:      string(a.health),
+		IsOn:        a.state.IsOn(),
+		Key:         a.key,
+		Name:        a.name,
+		State:       string(a.state),
+		Stats:       a.stats,
+	}
+}
@@ -0,0 +1,203 @@
+package astibob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astilog"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+// Ability API constants
+const (
+	abilityAPIMaxRequestBodySize = webSocketMaxMessageSize / 2 // Request bodies are base64 encoded in json
+	abilityAPIResponseQueueSize  = 16
+	abilityAPITimeout            = 30 * time.Second // Max duration Bob waits for each chunk of a response
+)
+
+// abilityAPIRequests forwards ability API requests to brains through their websocket and matches their responses
+type abilityAPIRequests struct {
+	id      uint64
+	m       sync.Mutex // Locks id and pending
+	pending map[string]*abilityAPIRequest
+}
+
+// abilityAPIRequest is a pending ability API request receiving the chunks of its response
+type abilityAPIRequest struct {
+	c    chan astibrain.WebSocketAbilityAPIResponse
+	done chan struct{} // Closed once the request is not waiting for chunks anymore
+}
+
+// newAbilityAPIRequests creates a new abilityAPIRequests
+func newAbilityAPIRequests() *abilityAPIRequests {
+	return &abilityAPIRequests{pending: make(map[string]*abilityAPIRequest)}
+}
+
+// do sends a request to a brain and executes fn on each chunk of its response until the last one
+func (rs *abilityAPIRequests) do(ctx context.Context, b *brain, r astibrain.WebSocketAbilityAPIRequest, fn func(o astibrain.WebSocketAbilityAPIResponse) error) (err error) {
+	// Add pending request
+	var p = &abilityAPIRequest{
+		c:    make(chan astibrain.WebSocketAbilityAPIResponse, abilityAPIResponseQueueSize),
+		done: make(chan struct{}),
+	}
+	rs.m.Lock()
+	rs.id++
+	r.ID = strconv.FormatUint(rs.id, 10)
+	rs.pending[r.ID] = p
+	rs.m.Unlock()
+
+	// Remove pending request
+	defer func() {
+		rs.m.Lock()
+		defer rs.m.Unlock()
+		close(p.done)
+		delete(rs.pending, r.ID)
+	}()
+
+	// Send
+	if err = b.send(astibrain.WebsocketEventNameAbilityAPIRequest, r); err != nil {
+		err = errors.Wrap(err, "astibob: sending ability API request failed")
+		return
+	}
+
+	// Wait for chunks
+	var t = time.NewTimer(abilityAPITimeout)
+	defer t.Stop()
+	for {
+		select {
+		case o := <-p.c:
+			// Handle chunk
+			if err = fn(o); err != nil {
+				err = errors.Wrap(err, "astibob: handling ability API response chunk failed")
+				return
+			}
+
+			// Last chunk
+			if !o.More {
+				return
+			}
+
+			// Reset timer
+			if !t.Stop() {
+				<-t.C
+			}
+			t.Reset(abilityAPITimeout)
+		case <-t.C:
+			err = fmt.Errorf("astibob: waiting for brain %s to answer %s %s timed out", b.name, r.Method, r.Path)
+			return
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "astibob: waiting for brain %s to answer %s %s failed", b.name, r.Method, r.Path)
+			return
+		}
+	}
+}
+
+// respond forwards a response chunk to its pending request.
+// It blocks while the request is handling previous chunks so that chunks are never dropped.
+func (rs *abilityAPIRequests) respond(r astibrain.WebSocketAbilityAPIResponse) {
+	// Get pending request
+	rs.m.Lock()
+	p, ok := rs.pending[r.ID]
+	rs.m.Unlock()
+	if !ok {
+		astilog.Debugf("astibob: no pending ability API request %s", r.ID)
+		return
+	}
+
+	// Forward
+	select {
+	case p.c <- r:
+	case <-p.done:
+	}
+}
+
+// handleAbilityAPIResponse handles the ability.api.response websocket event
+// Responses are live data and are therefore not wrapped nor deduplicated.
+func (s *brainsServer) handleAbilityAPIResponse(payload json.RawMessage) (err error) {
+	// Decode payload
+	var p astibrain.WebSocketAbilityAPIResponse
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrap(err, "astibob: json unmarshaling ability.api.response payload failed")
+		return
+	}
+
+	// Respond
+	s.apiRequests.respond(p)
+	return
+}
+
+// handleAPIAbilityAPI forwards a request to an ability API through its brain.
+// Since requests go through the brain's websocket, their bodies are limited in size. Responses are streamed in chunks.
+func (s *clientsServer) handleAPIAbilityAPI(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve brain and ability
+	b, a, err := s.brainAbility(p.ByName("brain"), p.ByName("ability"))
+	if err != nil {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusNotFound, err)
+		return
+	}
+
+	// Ability doesn't expose an API
+	if !a.toAPI().API {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astibob: ability %s of brain %s doesn't expose an API", a.name, b.name))
+		return
+	}
+
+	// Read body
+	var body []byte
+	if body, err = ioutil.ReadAll(io.LimitReader(r.Body, abilityAPIMaxRequestBodySize+1)); err != nil {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrap(err, "astibob: reading body failed"))
+		return
+	} else if len(body) > abilityAPIMaxRequestBodySize {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusRequestEntityTooLarge, fmt.Errorf("astibob: body is bigger than %d bytes", abilityAPIMaxRequestBodySize))
+		return
+	}
+
+	// Forward and stream the response
+	var headerWritten bool
+	if err = s.apiRequests.do(r.Context(), b, astibrain.WebSocketAbilityAPIRequest{
+		Body:        body,
+		ContentType: r.Header.Get("Content-Type"),
+		Method:      r.Method,
+		Name:        a.name,
+		Path:        p.ByName("path"),
+		Query:       r.URL.RawQuery,
+	}, func(o astibrain.WebSocketAbilityAPIResponse) (err error) {
+		// Write header
+		if !headerWritten {
+			if len(o.ContentType) > 0 {
+				rw.Header().Set("Content-Type", o.ContentType)
+			}
+			rw.WriteHeader(o.StatusCode)
+			headerWritten = true
+		}
+
+		// Write body
+		if _, err = rw.Write(o.Body); err != nil {
+			err = errors.Wrap(err, "astibob: writing ability API response failed")
+			return
+		}
+		return
+	}); err != nil {
+		// Response has started, it can only be cut short
+		if headerWritten {
+			astilog.Error(errors.Wrap(err, "astibob: forwarding ability API request failed"))
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusGatewayTimeout, errors.Wrap(err, "astibob: forwarding ability API request failed"))
+		return
+	}
+}
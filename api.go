@@ -0,0 +1,232 @@
+package astibob
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// eventHandlerQueueSize is the number of events a handler can lag behind before events are dropped
+const eventHandlerQueueSize = 256
+
+// Event is an event dispatched by Bob.
+// Payload types depend on the event name:
+//   - ability.crashed: APIAbilityCrashed
+//   - ability.health: APIAbilityHealth
+//   - ability.transitioned: APIAbilityTransition
+//   - brain.disconnected: APIBrain
+//   - brain.registered: APIBrain
+//   - brain.stats: APIBrainStats
+//
+// The typed Subscribe* methods spare handlers the type assertion.
+type Event struct {
+	Name    string
+	Payload interface{}
+}
+
+// EventHandler handles events dispatched by Bob.
+type EventHandler func(e Event)
+
+// eventSubscriber executes a handler in its own go routine with the events queued for it
+type eventSubscriber struct {
+	done chan struct{}
+	h    EventHandler
+	once sync.Once
+	q    chan Event
+}
+
+// newEventSubscriber creates a new event subscriber and starts handling its events
+func newEventSubscriber(h EventHandler) (s *eventSubscriber) {
+	s = &eventSubscriber{
+		done: make(chan struct{}),
+		h:    h,
+		q:    make(chan Event, eventHandlerQueueSize),
+	}
+	go s.run()
+	return
+}
+
+// run handles queued events until the subscriber is closed
+func (s *eventSubscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case e := <-s.q:
+			s.h(e)
+		}
+	}
+}
+
+// close stops the subscriber, events still queued are discarded
+func (s *eventSubscriber) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// Subscribe adds a handler executed on each event dispatched by Bob.
+// Each handler is executed in its own go routine, in the order events are dispatched. Events are queued while the
+// handler is busy and are dropped if it lags too far behind.
+// The returned function must be called to unsubscribe.
+func (b *Bob) Subscribe(h EventHandler) (unsubscribe func()) {
+	// Lock
+	b.mh.Lock()
+	defer b.mh.Unlock()
+
+	// Add subscriber
+	var s = newEventSubscriber(h)
+	b.handlers[s] = true
+	return func() {
+		b.mh.Lock()
+		defer b.mh.Unlock()
+		s.close()
+		delete(b.handlers, s)
+	}
+}
+
+// SubscribeAbilityCrashed adds a handler executed each time an ability crashes
+func (b *Bob) SubscribeAbilityCrashed(fn func(e APIAbilityCrashed)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if p, ok := e.Payload.(APIAbilityCrashed); ok && e.Name == ClientsWebsocketEventNameAbilityCrashed {
+			fn(p)
+		}
+	})
+}
+
+// SubscribeAbilityHealth adds a handler executed each time an ability's health changes
+func (b *Bob) SubscribeAbilityHealth(fn func(e APIAbilityHealth)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if p, ok := e.Payload.(APIAbilityHealth); ok && e.Name == ClientsWebsocketEventNameAbilityHealth {
+			fn(p)
+		}
+	})
+}
+
+// SubscribeAbilityTransitioned adds a handler executed each time an ability transitions
+func (b *Bob) SubscribeAbilityTransitioned(fn func(e APIAbilityTransition)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if p, ok := e.Payload.(APIAbilityTransition); ok && e.Name == ClientsWebsocketEventNameAbilityTransitioned {
+			fn(p)
+		}
+	})
+}
+
+// SubscribeBrainDisconnected adds a handler executed each time a brain disconnects
+func (b *Bob) SubscribeBrainDisconnected(fn func(e APIBrain)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if p, ok := e.Payload.(APIBrain); ok && e.Name == ClientsWebsocketEventNameBrainDisconnected {
+			fn(p)
+		}
+	})
+}
+
+// SubscribeBrainRegistered adds a handler executed each time a brain registers
+func (b *Bob) SubscribeBrainRegistered(fn func(e APIBrain)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if p, ok := e.Payload.(APIBrain); ok && e.Name == ClientsWebsocketEventNameBrainRegistered {
+			fn(p)
+		}
+	})
+}
+
+// SubscribeBrainStats adds a handler executed each time a brain sends its stats
+func (b *Bob) SubscribeBrainStats(fn func(e APIBrainStats)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if p, ok := e.Payload.(APIBrainStats); ok && e.Name == ClientsWebsocketEventNameBrainStats {
+			fn(p)
+		}
+	})
+}
+
+// dispatchHandlers queues an event for each handler without blocking
+func (b *Bob) dispatchHandlers(name string, payload interface{}) {
+	// Lock
+	b.mh.Lock()
+	defer b.mh.Unlock()
+
+	// Queue
+	var e = Event{Name: name, Payload: payload}
+	for s := range b.handlers {
+		select {
+		case s.q <- e:
+		default:
+			astilog.Errorf("astibob: event handler is too slow, dropping %s event", name)
+		}
+	}
+}
+
+// API returns Bob's API representation
+func (b *Bob) API() APIBob {
+	return b.brains.toAPI()
+}
+
+// Brains returns the registered brains sorted by name
+func (b *Bob) Brains() (o []APIBrain) {
+	b.brains.brains(func(b *brain) error {
+		o = append(o, b.toAPI())
+		return nil
+	})
+	sort.Slice(o, func(i, j int) bool { return o[i].Name < o[j].Name })
+	return
+}
+
+// Brain returns a registered brain based on its name
+func (b *Bob) Brain(name string) (o APIBrain, ok bool) {
+	var v *brain
+	if v, ok = b.brains.brain(name); !ok {
+		return
+	}
+	return v.toAPI(), true
+}
+
+// StartAbility asks a brain to start an ability.
+// The ability is started asynchronously: an ability.transitioned event is dispatched once it has transitioned.
+func (b *Bob) StartAbility(brainName, abilityName string) error {
+	return b.sendToAbility(brainName, abilityName, astibrain.WebsocketEventNameAbilityStart, abilityName)
+}
+
+// StopAbility asks a brain to stop an ability.
+// The ability is stopped asynchronously: an ability.transitioned event is dispatched once it has transitioned.
+func (b *Bob) StopAbility(brainName, abilityName string) error {
+	return b.sendToAbility(brainName, abilityName, astibrain.WebsocketEventNameAbilityStop, abilityName)
+}
+
+// SendCommand sends a command to an ability which needs to implement the astibrain.Commander interface.
+// The payload is JSON encoded.
+func (b *Bob) SendCommand(brainName, abilityName string, payload interface{}) (err error) {
+	// Encode payload
+	var p json.RawMessage
+	if p, err = json.Marshal(payload); err != nil {
+		err = errors.Wrap(err, "astibob: json marshaling command payload failed")
+		return
+	}
+
+	// Send
+	return b.sendToAbility(brainName, abilityName, astibrain.WebsocketEventNameAbilityCommand, astibrain.WebSocketAbilityCommand{
+		Name:    abilityName,
+		Payload: p,
+	})
+}
+
+// sendToAbility sends an event to the brain of an ability after making sure the ability exists
+func (b *Bob) sendToAbility(brainName, abilityName, eventName string, payload interface{}) (err error) {
+	// Retrieve brain
+	v, ok := b.brains.brain(brainName)
+	if !ok {
+		err = fmt.Errorf("astibob: unknown brain %s", brainName)
+		return
+	}
+
+	// Retrieve ability
+	if _, ok = v.ability(abilityName); !ok {
+		err = fmt.Errorf("astibob: unknown ability %s for brain %s", abilityName, brainName)
+		return
+	}
+
+	// Send
+	return v.send(eventName, payload)
+}
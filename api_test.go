@@ -0,0 +1,84 @@
+package astibob
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBob_Subscribe(t *testing.T) {
+	b := &Bob{handlers: make(map[*eventSubscriber]bool)}
+	var es = make(chan Event, 10)
+	u := b.Subscribe(func(e Event) { es <- e })
+	var bs = make(chan APIBrain, 10)
+	ub := b.SubscribeBrainRegistered(func(e APIBrain) { bs <- e })
+	defer ub()
+	receive := func(c interface{}) interface{} {
+		i, v, _ := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(time.After(time.Second))},
+		})
+		if i != 0 {
+			t.Fatal("handler was not executed")
+		}
+		return v.Interface()
+	}
+
+	// Handlers receive events in order, typed handlers only receive their events
+	b.dispatchHandlers(ClientsWebsocketEventNameBrainDisconnected, APIBrain{Name: "disconnected"})
+	b.dispatchHandlers(ClientsWebsocketEventNameBrainRegistered, APIBrain{Name: "registered"})
+	assert.Equal(t, Event{Name: ClientsWebsocketEventNameBrainDisconnected, Payload: APIBrain{Name: "disconnected"}}, receive(es))
+	assert.Equal(t, Event{Name: ClientsWebsocketEventNameBrainRegistered, Payload: APIBrain{Name: "registered"}}, receive(es))
+	assert.Equal(t, APIBrain{Name: "registered"}, receive(bs))
+
+	// Unsubscribe
+	u()
+	b.dispatchHandlers(ClientsWebsocketEventNameBrainRegistered, APIBrain{Name: "test"})
+	receive(bs)
+	assert.Len(t, es, 0)
+}
+
+func TestBob_SubscribeDoesNotBlock(t *testing.T) {
+	b := &Bob{handlers: make(map[*eventSubscriber]bool)}
+	var c = make(chan struct{})
+	u := b.Subscribe(func(e Event) { <-c })
+	defer u()
+	defer close(c)
+
+	// Dispatching to a blocked handler drops events instead of blocking
+	var done = make(chan struct{})
+	go func() {
+		for i := 0; i < 2*eventHandlerQueueSize; i++ {
+			b.dispatchHandlers(ClientsWebsocketEventNameBrainStats, APIBrainStats{})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatching blocked")
+	}
+}
+
+func TestBob_SendToAbility(t *testing.T) {
+	b := &Bob{brains: newBrains()}
+	v := newBrain("brain")
+	v.set(newAbility("a", astibrain.AbilityStateStopped, astibrain.HealthStatusUnknown))
+	b.brains.set(v)
+
+	// Unknown brain
+	assert.EqualError(t, b.StartAbility("unknown", "a"), "astibob: unknown brain unknown")
+	assert.EqualError(t, b.StopAbility("unknown", "a"), "astibob: unknown brain unknown")
+	assert.EqualError(t, b.SendCommand("unknown", "a", nil), "astibob: unknown brain unknown")
+
+	// Unknown ability
+	assert.EqualError(t, b.StartAbility("brain", "b"), "astibob: unknown ability b for brain brain")
+	assert.EqualError(t, b.StopAbility("brain", "b"), "astibob: unknown ability b for brain brain")
+	assert.EqualError(t, b.SendCommand("brain", "b", nil), "astibob: unknown ability b for brain brain")
+
+	// Invalid payload
+	assert.Error(t, b.SendCommand("brain", "a", make(chan int)))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"flag"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/asticode/go-astibob/client"
+	"github.com/asticode/go-astibob/hearing"
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// export exports a dataset out of utterances stores.
+// Usage: astibob [flags] export -o <dir> [export flags] [store directories...]
+// When no store directory is provided, the dataset is exported from the brains connected to the running Bob.
+func export(c *Configuration, args []string) (err error) {
+	// Parse flags
+	var o astihearing.DatasetOptions
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "", "the output directory")
+	formats := fs.String("formats", "", "the comma separated dataset formats (commonvoice, deepspeech, kaldi), all of them if empty")
+	devRatio := fs.Float64("dev-ratio", -1, "the ratio of utterances in the dev split, the default one if negative")
+	fs.IntVar(&o.SampleRate, "sample-rate", 0, "the sample rate utterances are resampled to")
+	testRatio := fs.Float64("test-ratio", -1, "the ratio of utterances in the test split, the default one if negative")
+	fs.Parse(args)
+	if len(*formats) > 0 {
+		o.Formats = strings.Split(*formats, ",")
+	}
+	if *devRatio >= 0 {
+		o.DevRatio = devRatio
+	}
+	if *testRatio >= 0 {
+		o.TestRatio = testRatio
+	}
+
+	// No output
+	if len(*output) == 0 {
+		err = errors.New("astibob: no output directory provided")
+		return
+	}
+
+	// No store directories
+	if fs.NArg() == 0 {
+		return exportFromBob(c, o, *output)
+	}
+
+	// Create source
+	var s astihearing.DatasetSource
+	if s, err = astihearing.NewDirDatasetSource(fs.Args()...); err != nil {
+		err = errors.Wrap(err, "astibob: creating dataset source failed")
+		return
+	}
+
+	// Export
+	var r astihearing.DatasetReport
+	if r, err = astihearing.ExportDataset(s, astihearing.NewDirDatasetWriter(*output), o); err != nil {
+		err = errors.Wrap(err, "astibob: exporting dataset failed")
+		return
+	}
+	astilog.Infof("astibob: exported dataset of %s with splits %v in %s", r.Duration, r.Splits, *output)
+	return
+}
+
+// exportFromBob downloads a dataset from the running Bob and extracts it in a directory
+func exportFromBob(c *Configuration, o astihearing.DatasetOptions, output string) (err error) {
+	// Download in a pipe so that the archive is extracted while being downloaded
+	pr, pw := io.Pipe()
+	go func() {
+		cl := astibobclient.New(astibobclient.Options{
+			Addr:     c.Bob.ClientsServer.PublicAddr,
+			Password: c.Bob.ClientsServer.Password,
+			Username: c.Bob.ClientsServer.Username,
+		})
+		pw.CloseWithError(cl.Dataset(ctx, o, pw))
+	}()
+	defer pr.Close()
+
+	// Create gzip reader
+	var gr *gzip.Reader
+	if gr, err = gzip.NewReader(pr); err != nil {
+		err = errors.Wrap(err, "astibob: creating gzip reader failed")
+		return
+	}
+	defer gr.Close()
+
+	// Loop through files
+	w := astihearing.NewDirDatasetWriter(output)
+	tr := tar.NewReader(gr)
+	for {
+		// Next file
+		var h *tar.Header
+		if h, err = tr.Next(); err != nil {
+			if err == io.EOF {
+				err = nil
+				break
+			}
+			err = errors.Wrap(err, "astibob: reading tar header failed")
+			return
+		}
+
+		// Read
+		var b []byte
+		if b, err = ioutil.ReadAll(tr); err != nil {
+			err = errors.Wrapf(err, "astibob: reading %s failed", h.Name)
+			return
+		}
+
+		// Write
+		if err = w.WriteFile(h.Name, b); err != nil {
+			err = errors.Wrapf(err, "astibob: writing %s failed", h.Name)
+			return
+		}
+	}
+	astilog.Infof("astibob: exported dataset in %s", output)
+	return
+}
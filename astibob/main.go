@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/asticode/go-astibob"
+	"github.com/asticode/go-astibob/flags"
 	"github.com/asticode/go-astilog"
 	"github.com/asticode/go-astitools/config"
 	"github.com/pkg/errors"
@@ -18,18 +19,31 @@ import (
 var (
 	ctx, cancel = context.WithCancel(context.Background())
 	config      = flag.String("c", "", "the config path")
+	fc          = &Configuration{}
 )
 
 func main() {
 	// Parse flags
+	// Every configuration field can be set with a flag or an environment variable derived from its toml tags
+	if err := astiflags.Bind(flag.CommandLine, "ASTIBOB", fc); err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibob: binding flags failed"))
+	}
 	flag.Parse()
 	astilog.FlagInit()
 
 	// Create configuration
-	c := newConfiguration()
+	c, err := newConfiguration()
+	if err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibob: creating configuration failed"))
+	}
 
-	// Handle signals
-	handleSignals()
+	// Export a dataset
+	if flag.Arg(0) == "export" {
+		if err = export(c, flag.Args()[1:]); err != nil {
+			astilog.Fatal(errors.Wrap(err, "astibob: exporting failed"))
+		}
+		return
+	}
 
 	// Create bob
 	bob, err := astibob.New(c.Bob)
@@ -38,6 +52,18 @@ func main() {
 	}
 	defer bob.Close()
 
+	// Reload the configuration file on demand
+	bob.SetConfigLoader(func() (o astibob.Options, err error) {
+		var c *Configuration
+		if c, err = newConfiguration(); err != nil {
+			return
+		}
+		return c.Bob, nil
+	})
+
+	// Handle signals
+	handleSignals(bob)
+
 	// Run Bob
 	if err = bob.Run(ctx); err != nil {
 		astilog.Fatal(errors.Wrap(err, "astibob: running bob failed"))
@@ -50,7 +76,7 @@ type Configuration struct {
 }
 
 // newConfiguration creates a new configuration
-func newConfiguration() *Configuration {
+func newConfiguration() (_ *Configuration, err error) {
 	// Global config
 	gc := &Configuration{
 		Bob: astibob.Options{
@@ -72,18 +98,20 @@ func newConfiguration() *Configuration {
 		},
 	}
 
-	// TODO Flag config
-	fc := &Configuration{}
-
 	// Build configuration
-	c, err := asticonfig.New(gc, *config, fc)
-	if err != nil {
-		astilog.Fatal(err)
+	// Precedence is: flags > environment variables > config file > default values.
+	// Since configurations are merged on non-zero values, flags and environment variables can't set zero values.
+	// Flag config is copied since it is merged into.
+	var f = *fc
+	var c interface{}
+	if c, err = asticonfig.New(gc, *config, &f); err != nil {
+		err = errors.Wrap(err, "astibob: building configuration failed")
+		return
 	}
-	return c.(*Configuration)
+	return c.(*Configuration), nil
 }
 
-func handleSignals() {
+func handleSignals(bob *astibob.Bob) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch)
 	go func() {
@@ -91,6 +119,10 @@ func handleSignals() {
 			astilog.Debugf("astibob: received signal %s", s)
 			if s == syscall.SIGABRT || s == syscall.SIGKILL || s == syscall.SIGINT || s == syscall.SIGQUIT || s == syscall.SIGTERM {
 				cancel()
+			} else if s == syscall.SIGHUP {
+				if _, err := bob.Reload(); err != nil {
+					astilog.Error(errors.Wrap(err, "astibob: reloading failed"))
+				}
 			}
 		}
 	}()
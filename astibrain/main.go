@@ -6,9 +6,11 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"syscall"
 
 	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astibob/flags"
 	"github.com/asticode/go-astibob/hearing"
 	"github.com/asticode/go-astibob/portaudio"
 	"github.com/asticode/go-astibob/speaking"
@@ -21,46 +23,112 @@ import (
 var (
 	ctx, cancel = context.WithCancel(context.Background())
 	config      = flag.String("c", "", "the config path")
+	fc          = &Configuration{}
 )
 
 func main() {
 	// Parse flags
+	// Every configuration field can be set with a flag or an environment variable derived from its toml tags
+	if err := astiflags.Bind(flag.CommandLine, "ASTIBRAIN", fc); err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibrain: binding flags failed"))
+	}
 	flag.Parse()
 	astilog.FlagInit()
 
 	// Init configuration
-	c := newConfiguration()
+	c, err := newConfiguration()
+	if err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibrain: creating configuration failed"))
+	}
+
+	// Init brain
+	brain := astibrain.New(c.Brain)
+	defer brain.Close()
 
 	// Handle signals
-	handleSignals()
+	handleSignals(brain, c)
 
-	// Init portaudio
-	p, err := astiportaudio.New()
-	if err != nil {
-		astilog.Fatal(errors.Wrap(err, "astibrain: creating portaudio failed"))
+	// Init hearing input
+	var r astihearing.SamplesReader
+	var f = astibrain.AudioFormat{
+		BitDepth:    32,
+		NumChannels: c.PortAudio.NumInputChannels,
+		SampleRate:  int(c.PortAudio.SampleRate),
 	}
-	defer p.Close()
+	switch c.HearingInput {
+	case hearingInputFile:
+		// Init file reader, which is stdin if the path is "-"
+		fr, err := astihearing.NewFileReader(c.HearingFile)
+		if err != nil {
+			astilog.Fatal(errors.Wrap(err, "astibrain: creating file reader failed"))
+		}
+		defer fr.Close()
+		r = fr
 
-	// Init portaudio stream
-	s, err := p.NewDefaultStream(make([]int32, 192), c.PortAudio)
-	if err != nil {
-		astilog.Fatal(errors.Wrap(err, "astibrain: creating portaudio default stream failed"))
+		// Hearing format is the file format
+		f = astibrain.AudioFormat{
+			BitDepth:    fr.Format().BitDepth,
+			NumChannels: fr.Format().NumChannels,
+			SampleRate:  fr.Format().SampleRate,
+		}
+	case hearingInputBrowser:
+		// Init audio input fed by a browser microphone through Bob
+		if r, err = brain.NewAudioInput("Microphone", f); err != nil {
+			astilog.Fatal(errors.Wrap(err, "astibrain: creating audio input failed"))
+		}
+	default:
+		// Init portaudio
+		p, err := astiportaudio.New()
+		if err != nil {
+			astilog.Fatal(errors.Wrap(err, "astibrain: creating portaudio failed"))
+		}
+		defer p.Close()
+
+		// Init portaudio stream
+		s, err := p.NewDefaultStream(make([]int32, 192), c.PortAudio)
+		if err != nil {
+			astilog.Fatal(errors.Wrap(err, "astibrain: creating portaudio default stream failed"))
+		}
+		defer s.Close()
+		r = s
 	}
-	defer s.Close()
 
 	// Init hearing
-	hearing := astihearing.New(s, c.Hearing)
+	// Hearing format is the input format and its utterances are stored with the brain name
+	ho := c.Hearing
+	ho.Brain = c.Brain.Name
+	ho.BitDepth = f.BitDepth
+	ho.NumChannels = f.NumChannels
+	ho.SampleRate = f.SampleRate
+	hearing := astihearing.New(r, ho)
 
 	// Init speaking
 	speaking := astispeaking.New(c.Speaking)
 
-	// Init brain
-	brain := astibrain.New(c.Brain)
-	defer brain.Close()
-
 	// Learn abilities
-	brain.Learn("Hearing", hearing, astibrain.AbilityOptions{AutoStart: c.AutoStart})
-	brain.Learn("Speaking", speaking, astibrain.AbilityOptions{AutoStart: c.AutoStart})
+	var ao = c.abilityOptions()
+	if err = brain.Learn("Hearing", hearing, ao["Hearing"]); err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibrain: learning hearing failed"))
+	}
+	if err = brain.Learn("Speaking", speaking, ao["Speaking"]); err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibrain: learning speaking failed"))
+	}
+
+	// Stream what hearing hears to Bob
+	// Samples are streamed once preprocessed and once channels have been handled, which may change their format
+	hf := f
+	hf.NumChannels = hearing.NumChannels()
+	hf.SampleRate = hearing.SampleRate()
+	as, err := brain.NewAudioStream("Hearing", hf)
+	if err != nil {
+		astilog.Fatal(errors.Wrap(err, "astibrain: creating hearing audio stream failed"))
+	}
+	hearing.OnSamples(as.Write)
+
+	// Play what speaking renders through Bob
+	if c.Speaking.Render {
+		speaking.OnAudio(brain.NewAudioOutput("Speaking").Play)
+	}
 
 	// Run the brain
 	if err = brain.Run(ctx); err != nil {
@@ -68,17 +136,47 @@ func main() {
 	}
 }
 
+// Hearing inputs
+const (
+	hearingInputBrowser   = "browser"
+	hearingInputFile      = "file"
+	hearingInputPortAudio = "portaudio"
+)
+
 // Configuration represents a configuration
 type Configuration struct {
-	AutoStart bool                        `toml:"auto_start"`
-	Brain     astibrain.Options           `toml:"brain"`
-	Hearing   astihearing.Options         `toml:"hearing"`
-	PortAudio astiportaudio.StreamOptions `toml:"portaudio"`
-	Speaking  astispeaking.Options        `toml:"speaking"`
+	Abilities    ConfigurationAbilities        `toml:"abilities"`
+	AutoStart    bool                          `toml:"auto_start"`
+	Brain        astibrain.Options             `toml:"brain"`
+	Hearing      astihearing.Options           `toml:"hearing"`
+	HearingFile  astihearing.FileReaderOptions `toml:"hearing_file"`
+	HearingInput string                        `toml:"hearing_input"`
+	PortAudio    astiportaudio.StreamOptions   `toml:"portaudio"`
+	Speaking     astispeaking.Options          `toml:"speaking"`
+}
+
+// ConfigurationAbilities represents the options of each ability
+type ConfigurationAbilities struct {
+	Hearing  astibrain.AbilityOptions `toml:"hearing"`
+	Speaking astibrain.AbilityOptions `toml:"speaking"`
+}
+
+// abilityOptions returns the ability options indexed by ability name.
+// The global auto start switches on the auto start of every ability.
+func (c *Configuration) abilityOptions() (o map[string]astibrain.AbilityOptions) {
+	o = map[string]astibrain.AbilityOptions{
+		"Hearing":  c.Abilities.Hearing,
+		"Speaking": c.Abilities.Speaking,
+	}
+	for n, ao := range o {
+		ao.AutoStart = ao.AutoStart || c.AutoStart
+		o[n] = ao
+	}
+	return
 }
 
 // newConfiguration creates a new configuration
-func newConfiguration() *Configuration {
+func newConfiguration() (_ *Configuration, err error) {
 	// Global config
 	gc := &Configuration{
 		Brain: astibrain.Options{
@@ -89,6 +187,7 @@ func newConfiguration() *Configuration {
 		Hearing: astihearing.Options{
 			WorkingDirectory: filepath.Join(os.TempDir(), "bob", "hearing"),
 		},
+		HearingInput: hearingInputPortAudio,
 		PortAudio: astiportaudio.StreamOptions{
 			NumInputChannels: 1,
 			SampleRate:       16000,
@@ -98,25 +197,69 @@ func newConfiguration() *Configuration {
 		},
 	}
 
-	// TODO Flag config
-	fc := &Configuration{}
-
 	// Build configuration
-	c, err := asticonfig.New(gc, *config, fc)
+	// Precedence is: flags > environment variables > config file > default values.
+	// Since configurations are merged on non-zero values, flags and environment variables can't set zero values.
+	// Flag config is copied since it is merged into.
+	var f = *fc
+	var c interface{}
+	if c, err = asticonfig.New(gc, *config, &f); err != nil {
+		err = errors.Wrap(err, "astibrain: building configuration failed")
+		return
+	}
+	return c.(*Configuration), nil
+}
+
+// reload reads the configuration file again, applies it to the brain and returns the configuration now applied.
+// Sections requiring a restart keep their running values in the returned configuration.
+func reload(brain *astibrain.Brain, applied *Configuration) *Configuration {
+	// Create configuration
+	c, err := newConfiguration()
 	if err != nil {
-		astilog.Fatal(err)
+		astilog.Error(errors.Wrap(err, "astibrain: creating configuration failed"))
+		return applied
 	}
-	return c.(*Configuration)
+
+	// Apply brain and abilities options
+	r := brain.Reload(c.Brain, c.abilityOptions())
+
+	// Abilities are created once
+	for n, v := range map[string][2]interface{}{
+		"hearing":       {applied.Hearing, c.Hearing},
+		"hearing_file":  {applied.HearingFile, c.HearingFile},
+		"hearing_input": {applied.HearingInput, c.HearingInput},
+		"portaudio":     {applied.PortAudio, c.PortAudio},
+		"speaking":      {applied.Speaking, c.Speaking},
+	} {
+		if !reflect.DeepEqual(v[0], v[1]) {
+			r.RestartRequired = append(r.RestartRequired, n)
+		}
+	}
+	if len(r.RestartRequired) > 0 {
+		astilog.Warnf("astibrain: restart required to apply %v", r.RestartRequired)
+	}
+
+	// Keep running values
+	c.Hearing = applied.Hearing
+	c.HearingFile = applied.HearingFile
+	c.HearingInput = applied.HearingInput
+	c.PortAudio = applied.PortAudio
+	c.Speaking = applied.Speaking
+	return c
 }
 
-func handleSignals() {
+func handleSignals(brain *astibrain.Brain, c *Configuration) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch)
 	go func() {
+		// Reloads compare with the last applied configuration
+		var applied = c
 		for s := range ch {
 			astilog.Debugf("astibrain: received signal %s", s)
 			if s == syscall.SIGABRT || s == syscall.SIGKILL || s == syscall.SIGINT || s == syscall.SIGQUIT || s == syscall.SIGTERM {
 				cancel()
+			} else if s == syscall.SIGHUP {
+				applied = reload(brain, applied)
 			}
 		}
 	}()
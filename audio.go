@@ -0,0 +1,240 @@
+package astibob
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astilog"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// audioSubscriberQueueSize is the number of payloads a subscriber can lag behind before payloads are dropped
+const audioSubscriberQueueSize = 32
+
+// audioUpgrader upgrades HTTP connections to audio websockets
+var audioUpgrader = websocket.Upgrader{}
+
+// upgradeAudio upgrades an HTTP connection to an audio websocket.
+// Upgrade failures are replied to by the upgrader.
+func upgradeAudio(rw http.ResponseWriter, r *http.Request, name string) (a *astibrain.AudioWebSocket, err error) {
+	var c *websocket.Conn
+	if c, err = audioUpgrader.Upgrade(rw, r, nil); err != nil {
+		err = errors.Wrapf(err, "astibob: upgrading audio websocket %s failed", name)
+		return
+	}
+	a = astibrain.NewAudioWebSocket(c, name)
+	return
+}
+
+// audioSubscriber represents a subscriber to a brain's audio.
+// Payloads are dispatched to it through a bounded queue and are dropped when the queue is full so that a slow
+// subscriber never blocks the brain's read loop nor other subscribers.
+type audioSubscriber struct {
+	done chan struct{}
+	fn   func(payload interface{})
+	once sync.Once
+	q    chan interface{}
+}
+
+// newAudioSubscriber creates a new audio subscriber and starts executing fn with its payloads
+func newAudioSubscriber(fn func(payload interface{})) (s *audioSubscriber) {
+	s = &audioSubscriber{
+		done: make(chan struct{}),
+		fn:   fn,
+		q:    make(chan interface{}, audioSubscriberQueueSize),
+	}
+	go s.run()
+	return
+}
+
+// run executes fn with queued payloads until the subscriber is closed
+func (s *audioSubscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case p := <-s.q:
+			s.fn(p)
+		}
+	}
+}
+
+// queue queues a payload and returns false if it has been dropped
+func (s *audioSubscriber) queue(payload interface{}) bool {
+	select {
+	case s.q <- payload:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops the subscriber
+func (s *audioSubscriber) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// audioHubFunc represents a function executed when the number of subscribers to a brain's audio changes
+type audioHubFunc func(b *brain, name string) error
+
+// audioHub dispatches audio sent by brains to their subscribers.
+type audioHub struct {
+	m       sync.Mutex // Locks subs
+	onFirst audioHubFunc
+	onLast  audioHubFunc
+	subs    map[string]map[*audioSubscriber]bool // Indexed by audio key
+}
+
+// newAudioHub creates a new audio hub.
+// onFirst and onLast are optional and are executed respectively when the first subscriber subscribes and when the
+// last subscriber unsubscribes.
+func newAudioHub(onFirst, onLast audioHubFunc) *audioHub {
+	return &audioHub{
+		onFirst: onFirst,
+		onLast:  onLast,
+		subs:    make(map[string]map[*audioSubscriber]bool),
+	}
+}
+
+// newAudioStreams creates a new audio hub for abilities' live audio streams.
+// Brains are asked to stream audio only while there is at least one subscriber.
+func newAudioStreams() *audioHub {
+	return newAudioHub(func(b *brain, name string) error {
+		return b.send(astibrain.WebsocketEventNameAudioStart, name)
+	}, func(b *brain, name string) error {
+		return b.send(astibrain.WebsocketEventNameAudioStop, name)
+	})
+}
+
+// audioKey creates an audio key
+func audioKey(brainName, name string) string {
+	return brainName + "/" + name
+}
+
+// subscribe subscribes to a brain's audio.
+// The returned function must be called to unsubscribe. The returned channel is closed once unsubscribed, which
+// happens as well when the brain disconnects.
+func (h *audioHub) subscribe(b *brain, name string, fn func(payload interface{})) (unsubscribe func(), done <-chan struct{}, err error) {
+	// Lock
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	// First subscriber
+	var k = audioKey(b.name, name)
+	if len(h.subs[k]) == 0 {
+		if h.onFirst != nil {
+			astilog.Debugf("astibob: first subscriber to audio %s", k)
+			if err = h.onFirst(b, name); err != nil {
+				err = errors.Wrapf(err, "astibob: handling first subscriber to audio %s failed", k)
+				return
+			}
+		}
+		h.subs[k] = make(map[*audioSubscriber]bool)
+	}
+
+	// Add subscriber
+	var s = newAudioSubscriber(fn)
+	h.subs[k][s] = true
+	done = s.done
+
+	// Create unsubscribe func
+	var once sync.Once
+	unsubscribe = func() { once.Do(func() { h.unsubscribe(b, name, s) }) }
+	return
+}
+
+// unsubscribe removes a subscriber
+func (h *audioHub) unsubscribe(b *brain, name string, s *audioSubscriber) {
+	// Lock
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	// Remove subscriber
+	s.close()
+	var k = audioKey(b.name, name)
+	if _, ok := h.subs[k][s]; !ok {
+		return
+	}
+	delete(h.subs[k], s)
+
+	// Last subscriber
+	if len(h.subs[k]) == 0 {
+		delete(h.subs, k)
+		if h.onLast != nil {
+			astilog.Debugf("astibob: last subscriber to audio %s has left", k)
+			if err := h.onLast(b, name); err != nil {
+				astilog.Error(errors.Wrapf(err, "astibob: handling last subscriber to audio %s failed", k))
+			}
+		}
+	}
+}
+
+// dispatch dispatches a payload to the subscribers of a brain's audio without blocking
+func (h *audioHub) dispatch(b *brain, name string, payload interface{}) {
+	// Lock
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	// Queue
+	var k = audioKey(b.name, name)
+	for s := range h.subs[k] {
+		if !s.queue(payload) {
+			astilog.Debugf("astibob: audio %s subscriber is too slow, dropping payload", k)
+		}
+	}
+}
+
+// delBrain removes all subscriptions to a brain's audio
+func (h *audioHub) delBrain(b *brain) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for k, ss := range h.subs {
+		if strings.HasPrefix(k, audioKey(b.name, "")) {
+			for s := range ss {
+				s.close()
+			}
+			delete(h.subs, k)
+		}
+	}
+}
+
+// writeWAV writes little-endian signed PCM data as a WAV file
+func writeWAV(w io.Writer, f astibrain.AudioFormat, data []byte) (err error) {
+	// Create header
+	var blockAlign = f.NumChannels * f.BitDepth / 8
+	var h = []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + len(data)),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),
+		uint16(1), // PCM
+		uint16(f.NumChannels),
+		uint32(f.SampleRate),
+		uint32(f.SampleRate * blockAlign),
+		uint16(blockAlign),
+		uint16(f.BitDepth),
+		[4]byte{'d', 'a', 't', 'a'},
+		uint32(len(data)),
+	}
+
+	// Write header
+	for _, v := range h {
+		if err = binary.Write(w, binary.LittleEndian, v); err != nil {
+			err = errors.Wrap(err, "astibob: writing wav header failed")
+			return
+		}
+	}
+
+	// Write data
+	if _, err = w.Write(data); err != nil {
+		err = errors.Wrap(err, "astibob: writing wav data failed")
+		return
+	}
+	return
+}
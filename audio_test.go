@@ -0,0 +1,63 @@
+package astibob
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioHub(t *testing.T) {
+	// Create hub
+	var firsts, lasts int
+	h := newAudioHub(func(b *brain, name string) error {
+		firsts++
+		return nil
+	}, func(b *brain, name string) error {
+		lasts++
+		return nil
+	})
+	b := newBrain("brain")
+
+	// Subscribe a fast subscriber and a slow one that blocks until released
+	fast := make(chan interface{})
+	unsubscribeFast, _, err := h.subscribe(b, "a", func(p interface{}) { fast <- p })
+	assert.NoError(t, err)
+	release := make(chan bool)
+	unsubscribeSlow, doneSlow, err := h.subscribe(b, "a", func(p interface{}) { <-release })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, firsts)
+
+	// Dispatching doesn't block on the slow subscriber which drops what doesn't fit in its queue
+	for i := 0; i < 4*audioSubscriberQueueSize; i++ {
+		h.dispatch(b, "a", i)
+		select {
+		case p := <-fast:
+			assert.Equal(t, i, p)
+		case <-time.After(time.Second):
+			t.Fatal("payload was not received in time")
+		}
+	}
+
+	// Unsubscribe
+	unsubscribeSlow()
+	close(release)
+	select {
+	case <-doneSlow:
+	default:
+		t.Fatal("unsubscribed subscriber is not done")
+	}
+	assert.Equal(t, 0, lasts)
+	unsubscribeFast()
+	assert.Equal(t, 1, lasts)
+
+	// Brain disconnects
+	_, done, err := h.subscribe(b, "a", func(p interface{}) {})
+	assert.NoError(t, err)
+	h.delBrain(b)
+	select {
+	case <-done:
+	default:
+		t.Fatal("subscriber of disconnected brain is not done")
+	}
+}
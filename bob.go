@@ -3,6 +3,7 @@ package astibob
 import (
 	"context"
 	"path/filepath"
+	"sync"
 	"text/template"
 
 	"github.com/asticode/go-astilog"
@@ -13,27 +14,38 @@ import (
 
 // Bob is an object handling a collection of brains.
 type Bob struct {
+	apiRequests   *abilityAPIRequests
+	audioOutputs  *audioHub
+	audioStreams  *audioHub
 	brains        *brains
 	brainsServer  *brainsServer
 	cancel        context.CancelFunc
 	clientsServer *clientsServer
 	ctx           context.Context
+	handlers      map[*eventSubscriber]bool
+	loader        ConfigLoader
+	m             sync.Mutex // Locks loader and o
+	mh            sync.Mutex // Locks handlers
 	o             Options
 }
 
 // Options are Bob options.
 type Options struct {
-	BrainsServer       ServerOptions
-	ClientsServer      ServerOptions
-	ResourcesDirectory string
+	BrainsServer       ServerOptions `toml:"brains_server"`
+	ClientsServer      ServerOptions `toml:"clients_server"`
+	ResourcesDirectory string        `toml:"resources_directory"`
 }
 
 // New creates a new Bob.
 func New(o Options) (b *Bob, err error) {
 	// Create bob
 	b = &Bob{
-		brains: newBrains(),
-		o:      o,
+		apiRequests:  newAbilityAPIRequests(),
+		audioOutputs: newAudioHub(nil, nil),
+		audioStreams: newAudioStreams(),
+		brains:       newBrains(),
+		handlers:     make(map[*eventSubscriber]bool),
+		o:            o,
 	}
 
 	// Parse templates
@@ -45,8 +57,8 @@ func New(o Options) (b *Bob, err error) {
 	}
 
 	// Create servers
-	b.brainsServer = newBrainsServer(b.brains, o.BrainsServer)
-	b.clientsServer = newClientsServer(t, b.brains, b.stop, o)
+	b.clientsServer = newClientsServer(t, b.brains, b.audioStreams, b.audioOutputs, b.apiRequests, b.stop, b.Reload, o)
+	b.brainsServer = newBrainsServer(b.brains, b.audioStreams, b.audioOutputs, b.apiRequests, b.dispatch, o.BrainsServer)
 	return
 }
 
@@ -73,8 +85,18 @@ func (b *Bob) Run(ctx context.Context) (err error) {
 	b.ctx, b.cancel = context.WithCancel(ctx)
 	defer b.cancel()
 
-	// Run brains server
-	var chanDone = make(chan error)
+	// Listen
+	if err = b.brainsServer.listen(); err != nil {
+		err = errors.Wrap(err, "astibob: listening for brains failed")
+		return
+	}
+	if err = b.clientsServer.listen(); err != nil {
+		err = errors.Wrap(err, "astibob: listening for clients failed")
+		return
+	}
+
+	// Run servers
+	var chanDone = make(chan error, 2)
 	go func() {
 		if err := b.brainsServer.run(); err != nil {
 			chanDone <- err
@@ -90,7 +112,7 @@ func (b *Bob) Run(ctx context.Context) (err error) {
 	select {
 	case <-b.ctx.Done():
 		if b.ctx.Err() != context.Canceled {
-			err = errors.Wrap(err, "astibob: context error")
+			err = errors.Wrap(b.ctx.Err(), "astibob: context error")
 		}
 		return
 	case err = <-chanDone:
@@ -99,7 +121,18 @@ func (b *Bob) Run(ctx context.Context) (err error) {
 		}
 		return
 	}
-	return
+}
+
+// BrainsServerAddr returns the addr the brains server is listening on.
+// It is empty until Bob runs, which is useful when listening on a random port.
+func (b *Bob) BrainsServerAddr() string {
+	return b.brainsServer.addr()
+}
+
+// ClientsServerAddr returns the addr the clients server is listening on.
+// It is empty until Bob runs, which is useful when listening on a random port.
+func (b *Bob) ClientsServerAddr() string {
+	return b.clientsServer.addr()
 }
 
 // stop stops Bob
@@ -107,6 +140,15 @@ func (b *Bob) stop() {
 	b.cancel()
 }
 
+// dispatchFunc represents a function capable of dispatching an event
+type dispatchFunc func(name string, payload interface{})
+
+// dispatch dispatches an event to the clients and to the event handlers.
+func (b *Bob) dispatch(name string, payload interface{}) {
+	dispatchWsEvent(b.clientsServer.ws, name, payload)
+	b.dispatchHandlers(name, payload)
+}
+
 // dispatchWsEvent dispatches a websocket event.
 func dispatchWsEvent(ws *astiws.Manager, name string, payload interface{}) {
 	ws.Loop(func(k interface{}, c *astiws.Client) {
@@ -0,0 +1,420 @@
+// Package astibobtest runs a real Bob and real brains in-process so that features spanning Bob and brains can be
+// tested end to end.
+package astibobtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astibob"
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astiws"
+	"github.com/pkg/errors"
+)
+
+// defaultTimeout is the default max duration helpers wait for
+const defaultTimeout = 5 * time.Second
+
+// Options are harness options
+type Options struct {
+	// If empty, a temporary resources directory without templates is created
+	ResourcesDirectory string
+	Timeout            time.Duration
+}
+
+// Event is an event dispatched by Bob to its clients
+type Event struct {
+	Name    string
+	Payload json.RawMessage
+}
+
+// Harness runs a Bob listening on random ports and brains connected to it
+type Harness struct {
+	Bob          *astibob.Bob
+	brains       []*astibrain.Brain
+	c            *astiws.Client
+	cancel       context.CancelFunc
+	ctx          context.Context
+	e            []Event
+	me           sync.Mutex // Locks e
+	o            Options
+	resourcesDir string // Temporary resources directory to remove on close
+	wg           sync.WaitGroup
+}
+
+// New creates a new harness, runs Bob and connects to its clients websocket to record events
+func New(o Options) (h *Harness, err error) {
+	// Default timeout
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	// Create harness
+	h = &Harness{o: o}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+
+	// Make sure the harness is closed on error
+	defer func() {
+		if err != nil {
+			h.Close()
+		}
+	}()
+
+	// Create temporary resources directory
+	if len(o.ResourcesDirectory) == 0 {
+		if h.resourcesDir, err = ioutil.TempDir("", "astibobtest"); err != nil {
+			err = errors.Wrap(err, "astibobtest: creating temporary resources directory failed")
+			return
+		}
+		for _, d := range []string{"pages", "layouts"} {
+			if err = os.MkdirAll(filepath.Join(h.resourcesDir, "templates", d), 0755); err != nil {
+				err = errors.Wrapf(err, "astibobtest: creating templates directory %s failed", d)
+				return
+			}
+		}
+		o.ResourcesDirectory = h.resourcesDir
+	}
+
+	// Create Bob
+	if h.Bob, err = astibob.New(astibob.Options{
+		BrainsServer:       astibob.ServerOptions{ListenAddr: "127.0.0.1:0"},
+		ClientsServer:      astibob.ServerOptions{ListenAddr: "127.0.0.1:0", Timeout: o.Timeout},
+		ResourcesDirectory: o.ResourcesDirectory,
+	}); err != nil {
+		err = errors.Wrap(err, "astibobtest: creating bob failed")
+		return
+	}
+
+	// Run Bob
+	var chanDone = make(chan error, 1)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := h.Bob.Run(h.ctx); err != nil {
+			chanDone <- err
+		}
+	}()
+
+	// Wait for Bob to listen
+	if err = h.wait(func() (bool, error) {
+		select {
+		case err := <-chanDone:
+			return false, errors.Wrap(err, "astibobtest: running bob failed")
+		default:
+		}
+		return len(h.Bob.BrainsServerAddr()) > 0 && len(h.Bob.ClientsServerAddr()) > 0, nil
+	}); err != nil {
+		err = errors.Wrap(err, "astibobtest: waiting for bob to listen failed")
+		return
+	}
+
+	// Connect to the clients websocket
+	if err = h.dialClients(); err != nil {
+		err = errors.Wrap(err, "astibobtest: dialing clients websocket failed")
+		return
+	}
+	return
+}
+
+// dialClients connects to the clients websocket and records every event
+func (h *Harness) dialClients() (err error) {
+	// Create client
+	h.c = astiws.NewClient(1 << 20)
+
+	// Record events
+	for _, n := range []string{
+		astibob.ClientsWebsocketEventNameAbilityCrashed,
+		astibob.ClientsWebsocketEventNameAbilityHealth,
+		astibob.ClientsWebsocketEventNameAbilityTransitioned,
+		astibob.ClientsWebsocketEventNameBrainDisconnected,
+		astibob.ClientsWebsocketEventNameBrainRegistered,
+		astibob.ClientsWebsocketEventNameBrainStats,
+	} {
+		h.c.AddListener(n, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+			h.me.Lock()
+			defer h.me.Unlock()
+			h.e = append(h.e, Event{Name: eventName, Payload: payload})
+			return nil
+		})
+	}
+
+	// Dial
+	if err = h.c.Dial("ws://" + h.Bob.ClientsServerAddr() + "/websocket"); err != nil {
+		err = errors.Wrap(err, "astibobtest: dialing failed")
+		return
+	}
+
+	// Read
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := h.c.Read(); err != nil && h.ctx.Err() == nil {
+			astilog.Error(errors.Wrap(err, "astibobtest: reading clients websocket failed"))
+		}
+	}()
+	return
+}
+
+// Close implements the io.Closer interface
+func (h *Harness) Close() (err error) {
+	// Stop everything running
+	h.cancel()
+
+	// Close brains
+	for _, b := range h.brains {
+		if err := b.Close(); err != nil {
+			astilog.Error(errors.Wrap(err, "astibobtest: closing brain failed"))
+		}
+	}
+
+	// Close clients websocket
+	if h.c != nil {
+		if err := h.c.Close(); err != nil {
+			astilog.Error(errors.Wrap(err, "astibobtest: closing clients websocket failed"))
+		}
+	}
+
+	// Close Bob
+	if h.Bob != nil {
+		if err := h.Bob.Close(); err != nil {
+			astilog.Error(errors.Wrap(err, "astibobtest: closing bob failed"))
+		}
+	}
+
+	// Wait for go routines
+	h.wg.Wait()
+
+	// Remove temporary resources directory
+	if len(h.resourcesDir) > 0 {
+		if err = os.RemoveAll(h.resourcesDir); err != nil {
+			err = errors.Wrapf(err, "astibobtest: removing %s failed", h.resourcesDir)
+			return
+		}
+	}
+	return
+}
+
+// AddBrain creates a brain connected to Bob, lets fn teach it abilities, runs it and waits for it to be registered
+func (h *Harness) AddBrain(name string, fn func(b *astibrain.Brain) error) (b *astibrain.Brain, err error) {
+	// Create brain
+	b = astibrain.New(astibrain.Options{
+		Name: name,
+		WebSocket: astibrain.WebSocketOptions{
+			Backoff: astibrain.BackoffOptions{InitialInterval: 10 * time.Millisecond, MaxInterval: 100 * time.Millisecond},
+			URL:     "ws://" + h.Bob.BrainsServerAddr() + "/websocket",
+		},
+	})
+	h.brains = append(h.brains, b)
+
+	// Learn abilities
+	if fn != nil {
+		if err = fn(b); err != nil {
+			err = errors.Wrapf(err, "astibobtest: teaching abilities to brain %s failed", name)
+			return
+		}
+	}
+
+	// Run brain
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := b.Run(h.ctx); err != nil {
+			astilog.Error(errors.Wrapf(err, "astibobtest: running brain %s failed", name))
+		}
+	}()
+
+	// Wait for the brain to be registered
+	if err = h.wait(func() (bool, error) {
+		_, ok, err := h.brain(name)
+		return ok, err
+	}); err != nil {
+		err = errors.Wrapf(err, "astibobtest: waiting for brain %s to be registered failed", name)
+		return
+	}
+	return
+}
+
+// API returns Bob's API representation
+func (h *Harness) API() (o astibob.APIBob, err error) {
+	err = h.do(http.MethodGet, "/api/bob", &o)
+	return
+}
+
+// brain returns the API representation of a brain based on its name
+func (h *Harness) brain(name string) (o astibob.APIBrain, ok bool, err error) {
+	var b astibob.APIBob
+	if b, err = h.API(); err != nil {
+		return
+	}
+	for _, v := range b.Brains {
+		if v.Name == name {
+			return v, true, nil
+		}
+	}
+	return
+}
+
+// ability returns the API representation of an ability based on its brain name and its name
+func (h *Harness) ability(brainName, name string) (b astibob.APIBrain, a astibob.APIAbility, err error) {
+	// Retrieve brain
+	var ok bool
+	if b, ok, err = h.brain(brainName); err != nil {
+		return
+	} else if !ok {
+		err = fmt.Errorf("astibobtest: unknown brain %s", brainName)
+		return
+	}
+
+	// Retrieve ability
+	for _, v := range b.Abilities {
+		if v.Name == name {
+			return b, v, nil
+		}
+	}
+	err = fmt.Errorf("astibobtest: unknown ability %s for brain %s", name, brainName)
+	return
+}
+
+// StartAbility asks Bob to start an ability and waits for it to be running
+func (h *Harness) StartAbility(brainName, name string) error {
+	return h.toggleAbility(brainName, name, "start", astibrain.AbilityStateRunning)
+}
+
+// StopAbility asks Bob to stop an ability and waits for it to be stopped
+func (h *Harness) StopAbility(brainName, name string) error {
+	return h.toggleAbility(brainName, name, "stop", astibrain.AbilityStateStopped)
+}
+
+// toggleAbility asks Bob to start or stop an ability and waits for it to reach the provided state
+func (h *Harness) toggleAbility(brainName, name, action string, s astibrain.AbilityState) (err error) {
+	// Retrieve ability
+	b, a, err := h.ability(brainName, name)
+	if err != nil {
+		return
+	}
+
+	// Toggle
+	if err = h.do(http.MethodPost, "/api/brains/"+b.Key+"/abilities/"+a.Key+"/"+action, nil); err != nil {
+		err = errors.Wrapf(err, "astibobtest: %s ability %s of brain %s failed", action, name, brainName)
+		return
+	}
+
+	// Wait
+	if err = h.WaitForAbilityState(brainName, name, s); err != nil {
+		return
+	}
+	return
+}
+
+// WaitForAbilityState waits for Bob to see an ability in the provided state
+func (h *Harness) WaitForAbilityState(brainName, name string, s astibrain.AbilityState) (err error) {
+	if err = h.wait(func() (bool, error) {
+		_, a, err := h.ability(brainName, name)
+		return err == nil && a.State == string(s), nil
+	}); err != nil {
+		err = errors.Wrapf(err, "astibobtest: waiting for ability %s of brain %s to be %s failed", name, brainName, s)
+		return
+	}
+	return
+}
+
+// Events returns the events Bob has dispatched to its clients so far
+func (h *Harness) Events() []Event {
+	h.me.Lock()
+	defer h.me.Unlock()
+	return append([]Event{}, h.e...)
+}
+
+// WaitForEvent waits for Bob to dispatch an event with the provided name whose payload, decoded into v, satisfies
+// fn. Events dispatched before the call are taken into account. v and fn are optional.
+func (h *Harness) WaitForEvent(name string, v interface{}, fn func() bool) (err error) {
+	var idx int
+	if err = h.wait(func() (bool, error) {
+		es := h.Events()
+		for ; idx < len(es); idx++ {
+			// Invalid name
+			if es[idx].Name != name {
+				continue
+			}
+
+			// Decode
+			if v != nil {
+				if err := json.Unmarshal(es[idx].Payload, v); err != nil {
+					return false, errors.Wrapf(err, "astibobtest: json unmarshaling %s payload failed", name)
+				}
+			}
+
+			// Check
+			if fn == nil || fn() {
+				return true, nil
+			}
+		}
+		return false, nil
+	}); err != nil {
+		err = errors.Wrapf(err, "astibobtest: waiting for %s event failed", name)
+		return
+	}
+	return
+}
+
+// do sends a request to Bob's clients server and decodes the response into v if provided
+func (h *Harness) do(method, path string, v interface{}) (err error) {
+	// Create request
+	var req *http.Request
+	if req, err = http.NewRequest(method, "http://"+h.Bob.ClientsServerAddr()+path, nil); err != nil {
+		err = errors.Wrapf(err, "astibobtest: creating %s %s request failed", method, path)
+		return
+	}
+
+	// Send
+	var resp *http.Response
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		err = errors.Wrapf(err, "astibobtest: sending %s %s failed", method, path)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Invalid status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e astibrain.APIError
+		json.NewDecoder(resp.Body).Decode(&e)
+		err = fmt.Errorf("astibobtest: %s %s returned status code %d with message %s", method, path, resp.StatusCode, e.Message)
+		return
+	}
+
+	// Decode
+	if v != nil {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			err = errors.Wrapf(err, "astibobtest: json decoding %s %s response failed", method, path)
+			return
+		}
+	}
+	return
+}
+
+// wait polls fn until it returns true, an error or the timeout is reached
+func (h *Harness) wait(fn func() (bool, error)) (err error) {
+	var t = time.Now().Add(h.o.Timeout)
+	for {
+		// Check
+		var ok bool
+		if ok, err = fn(); err != nil || ok {
+			return
+		}
+
+		// Timeout
+		if time.Now().After(t) {
+			err = fmt.Errorf("astibobtest: timeout of %s reached", h.o.Timeout)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
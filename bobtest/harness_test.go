@@ -0,0 +1,150 @@
+package astibobtest
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astibob"
+	"github.com/asticode/go-astibob/brain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHarness(t *testing.T) {
+	// Create harness
+	h, err := New(Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer h.Close()
+
+	// Add brain
+	a, r := NewRunner(), NewRunner()
+	_, err = h.AddBrain("brain", func(b *astibrain.Brain) (err error) {
+		if err = b.Learn("a", a, astibrain.AbilityOptions{}); err != nil {
+			return
+		}
+		return b.Learn("r", r, astibrain.AbilityOptions{RestartDelay: time.Millisecond, RestartPolicy: astibrain.RestartPolicyOnFailure})
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, h.WaitForEvent(astibob.ClientsWebsocketEventNameBrainRegistered, nil, nil))
+
+	// Start
+	assert.NoError(t, h.StartAbility("brain", "a"))
+	var tr astibob.APIAbilityTransition
+	assert.NoError(t, h.WaitForEvent(astibob.ClientsWebsocketEventNameAbilityTransitioned, &tr, func() bool {
+		return tr.AbilityKey == "a" && tr.To == string(astibrain.AbilityStateRunning)
+	}))
+
+	// Crash without restart
+	assert.NoError(t, a.Crash(errors.New("crashed")))
+	var c astibob.APIAbilityCrashed
+	assert.NoError(t, h.WaitForEvent(astibob.ClientsWebsocketEventNameAbilityCrashed, &c, func() bool { return c.AbilityKey == "a" }))
+	assert.Equal(t, "crashed", c.Error)
+	assert.NoError(t, h.WaitForAbilityState("brain", "a", astibrain.AbilityStateCrashed))
+	a.CrashTimeout = 10 * time.Millisecond
+	assert.Error(t, a.Crash(errors.New("not running")))
+
+	// Crash with restart
+	assert.NoError(t, h.StartAbility("brain", "r"))
+	assert.NoError(t, r.Crash(errors.New("crashed")))
+	assert.NoError(t, h.WaitForEvent(astibob.ClientsWebsocketEventNameAbilityTransitioned, &tr, func() bool {
+		return tr.AbilityKey == "r" && tr.To == string(astibrain.AbilityStateRestarting)
+	}))
+	assert.NoError(t, h.WaitForAbilityState("brain", "r", astibrain.AbilityStateRunning))
+	assert.Equal(t, 2, r.Runs())
+
+	// State changing routes are not GETs
+	resp, err := http.Get("http://" + h.Bob.ClientsServerAddr() + "/api/brains/brain/abilities/r/stop")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+
+	// Stop
+	assert.NoError(t, h.StopAbility("brain", "r"))
+	assert.NoError(t, h.WaitForEvent(astibob.ClientsWebsocketEventNameAbilityTransitioned, &tr, func() bool {
+		return tr.AbilityKey == "r" && tr.To == string(astibrain.AbilityStateStopped)
+	}))
+
+	// Bob API
+	var ts = make(chan astibob.APIAbilityTransition, 10)
+	u := h.Bob.SubscribeAbilityTransitioned(func(e astibob.APIAbilityTransition) {
+		if e.To == string(astibrain.AbilityStateRunning) || e.To == string(astibrain.AbilityStateStopped) {
+			ts <- e
+		}
+	})
+	defer u()
+	assert.NoError(t, h.Bob.StartAbility("brain", "r"))
+	assert.NoError(t, h.WaitForAbilityState("brain", "r", astibrain.AbilityStateRunning))
+	assert.NoError(t, h.Bob.StopAbility("brain", "r"))
+	assert.NoError(t, h.WaitForAbilityState("brain", "r", astibrain.AbilityStateStopped))
+	for _, s := range []astibrain.AbilityState{astibrain.AbilityStateRunning, astibrain.AbilityStateStopped} {
+		select {
+		case e := <-ts:
+			assert.Equal(t, string(s), e.To)
+		case <-time.After(time.Second):
+			t.Fatalf("%s transition was not dispatched", s)
+		}
+	}
+	assert.NoError(t, h.Bob.SendCommand("brain", "r", map[string]string{"key": "value"}))
+	assert.NoError(t, h.wait(func() (bool, error) { return len(r.Commands()) == 1, nil }))
+	if cs := r.Commands(); len(cs) == 1 {
+		assert.JSONEq(t, `{"key":"value"}`, string(cs[0]))
+	}
+}
+
+// apiRunner is a runner exposing an API serving a big body
+type apiRunner struct {
+	*Runner
+	body []byte
+}
+
+func (r apiRunner) APIHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/octet-stream")
+		rw.Write(r.body)
+	})
+}
+
+func TestHarnessAbilityAPI(t *testing.T) {
+	// Create harness
+	h, err := New(Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer h.Close()
+
+	// Add brain
+	r := apiRunner{Runner: NewRunner(), body: bytes.Repeat([]byte("0123456789"), 300000)}
+	_, err = h.AddBrain("brain", func(b *astibrain.Brain) error { return b.Learn("a", r, astibrain.AbilityOptions{}) })
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, h.WaitForEvent(astibob.ClientsWebsocketEventNameBrainRegistered, nil, nil))
+
+	// Responses bigger than websocket messages are streamed
+	resp, err := http.Get("http://" + h.Bob.ClientsServerAddr() + "/api/brains/brain/abilities/a/api/")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.True(t, bytes.Equal(r.body, b))
+
+	// Requests bigger than websocket messages are rejected
+	resp2, err := http.Post("http://"+h.Bob.ClientsServerAddr()+"/api/brains/brain/abilities/a/api/", "application/octet-stream", bytes.NewReader(r.body))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp2.StatusCode)
+}
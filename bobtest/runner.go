@@ -0,0 +1,77 @@
+package astibobtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Runner is a fake ability runner.
+// It runs until its context is done or until it is crashed, and records the commands it receives.
+type Runner struct {
+	// Max duration Crash waits for the runner to be running
+	CrashTimeout time.Duration
+	c            chan error // Makes the current run return
+	commands     []json.RawMessage
+	m            sync.Mutex // Locks commands and runs
+	runs         int
+}
+
+// NewRunner creates a new fake runner
+func NewRunner() *Runner {
+	return &Runner{
+		CrashTimeout: defaultTimeout,
+		c:            make(chan error),
+	}
+}
+
+// Run implements the astibrain.Runner interface
+func (r *Runner) Run(ctx context.Context) (err error) {
+	// Increment runs
+	r.m.Lock()
+	r.runs++
+	r.m.Unlock()
+
+	// Wait for context to be done or for a crash
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err = <-r.c:
+		return
+	}
+}
+
+// Crash makes the current run return the provided error.
+// It waits for the runner to be running and fails if it is not running in time.
+func (r *Runner) Crash(err error) error {
+	select {
+	case r.c <- err:
+		return nil
+	case <-time.After(r.CrashTimeout):
+		return fmt.Errorf("astibobtest: runner is not running after %s", r.CrashTimeout)
+	}
+}
+
+// Runs returns the number of times the runner has been run
+func (r *Runner) Runs() int {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.runs
+}
+
+// HandleCommand implements the astibrain.Commander interface
+func (r *Runner) HandleCommand(payload json.RawMessage) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.commands = append(r.commands, payload)
+	return nil
+}
+
+// Commands returns the command payloads the runner has received
+func (r *Runner) Commands() []json.RawMessage {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return append([]json.RawMessage{}, r.commands...)
+}
@@ -1,20 +1,100 @@
 package astibob
 
-import "sync"
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astiws"
+	"github.com/pkg/errors"
+)
 
 // brain is a brain as Bob knows it
 type brain struct {
-	a    map[string]*ability
-	m    sync.Mutex // Locks a
-	name string
+	a            map[string]*ability
+	audio        *astibrain.AudioWebSocket
+	audioInputs  map[string]astibrain.AudioFormat
+	audioOutputs map[string]bool
+	key          string
+	m            sync.Mutex // Locks a, audio and runtime
+	name         string
+	runtime      *astibrain.RuntimeStats
+	ws           *astiws.Client
 }
 
 // newBrain creates a new brain
 func newBrain(name string) *brain {
 	return &brain{
-		a:    make(map[string]*ability),
-		name: name,
+		a:            make(map[string]*ability),
+		audioOutputs: make(map[string]bool),
+		key:          brainKey(name),
+		name:         name,
+	}
+}
+
+// brainKey creates a brain key
+func brainKey(name string) string {
+	return abilityKey(name)
+}
+
+// newBrainFromWebSocket creates a new brain based on a websocket register payload
+func newBrainFromWebSocket(r astibrain.WebSocketRegister, ws *astiws.Client) (b *brain) {
+	b = newBrain(r.Name)
+	b.audioInputs = r.AudioInputs
+	for _, n := range r.AudioOutputs {
+		b.audioOutputs[n] = true
+	}
+	b.ws = ws
+	for _, a := range r.Abilities {
+		v := newAbility(a.Name, a.State, a.Health)
+		v.api = a.API
+		v.audioFormat = a.AudioFormat
+		b.set(v)
+	}
+	return
+}
+
+// send sends an event to the brain
+func (b *brain) send(eventName string, payload interface{}) (err error) {
+	if err = b.ws.Write(eventName, payload); err != nil {
+		err = errors.Wrapf(err, "astibob: sending %s event to brain %s failed", eventName, b.name)
+		return
+	}
+	return
+}
+
+// setAudio sets the brain's audio websocket and closes the previous one
+func (b *brain) setAudio(a *astibrain.AudioWebSocket) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.audio != nil && b.audio != a {
+		b.audio.Close()
+	}
+	b.audio = a
+}
+
+// delAudio removes the brain's audio websocket if it is the provided one
+func (b *brain) delAudio(a *astibrain.AudioWebSocket) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.audio == a {
+		b.audio = nil
+	}
+}
+
+// sendAudio sends an audio frame to the brain through its audio websocket.
+// Frames are dropped if the brain can't keep up.
+func (b *brain) sendAudio(f astibrain.AudioFrame) (err error) {
+	b.m.Lock()
+	var a = b.audio
+	b.m.Unlock()
+	if a == nil {
+		err = fmt.Errorf("astibob: brain %s has no audio websocket", b.name)
+		return
 	}
+	a.Write(f)
+	return
 }
 
 // ability returns a specific ability based on its name.
@@ -25,6 +105,18 @@ func (b *brain) ability(name string) (a *ability, ok bool) {
 	return
 }
 
+// abilityByKey returns a specific ability based on its key.
+func (b *brain) abilityByKey(key string) (a *ability, ok bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	for _, v := range b.a {
+		if v.key == key {
+			return v, true
+		}
+	}
+	return
+}
+
 // abilities loops through abilities and execute a function on each of them.
 // If an error is returned by the function, the loop is stopped.
 func (b *brain) abilities(fn func(a *ability) error) (err error) {
@@ -37,3 +129,47 @@ func (b *brain) abilities(fn func(a *ability) error) (err error) {
 	}
 	return
 }
+
+// set sets a new ability to the brain.
+func (b *brain) set(a *ability) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.a[a.name] = a
+}
+
+// setStats sets the brain stats
+func (b *brain) setStats(s astibrain.Stats) {
+	// Set runtime stats
+	b.m.Lock()
+	b.runtime = &s.Runtime
+	b.m.Unlock()
+
+	// Set abilities stats
+	for n, as := range s.Abilities {
+		if a, ok := b.ability(n); ok {
+			a.setStats(as)
+		}
+	}
+}
+
+// toAPI returns the API representation of the brain
+func (b *brain) toAPI() (o APIBrain) {
+	o = APIBrain{
+		Abilities:   make(map[string]APIAbility),
+		AudioInputs: b.audioInputs,
+		Key:         b.key,
+		Name:        b.name,
+	}
+	for n := range b.audioOutputs {
+		o.AudioOutputs = append(o.AudioOutputs, n)
+	}
+	sort.Strings(o.AudioOutputs)
+	b.abilities(func(a *ability) error {
+		o.Abilities[a.key] = a.toAPI()
+		return nil
+	})
+	b.m.Lock()
+	o.Runtime = b.runtime
+	b.m.Unlock()
+	return
+}
@@ -1,6 +1,12 @@
 package astibrain
 
-import "sync"
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
 
 // abilities is a pool of abilities
 type abilities struct {
@@ -35,9 +41,150 @@ func (as *abilities) abilities(fn func(a *ability) error) (err error) {
 }
 
 // set sets a new ability to the pool.
-func (as *abilities) set(a *ability) {
+// An error is returned if it creates a dependency cycle.
+func (as *abilities) set(a *ability) (err error) {
+	// Lock
 	as.m.Lock()
 	defer as.m.Unlock()
+
+	// Check cycles
+	var m = make(map[string]*ability)
+	for k, v := range as.a {
+		m[k] = v
+	}
+	m[a.name] = a
+	if _, err = sortAbilities(m); err != nil {
+		err = errors.Wrapf(err, "astibrain: checking dependencies of %s failed", a.name)
+		return
+	}
+
+	// Set
 	as.a[a.name] = a
 	return
 }
+
+// ordered returns the abilities sorted so that each ability comes after its dependencies.
+func (as *abilities) ordered() ([]*ability, error) {
+	as.m.Lock()
+	defer as.m.Unlock()
+	return sortAbilities(as.a)
+}
+
+// sortAbilities sorts abilities topologically and detects dependency cycles.
+// Dependencies that are not in the map are ignored.
+func sortAbilities(m map[string]*ability) (o []*ability, err error) {
+	// Sort names so that the order is deterministic
+	var names []string
+	for n := range m {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// Depth-first search
+	const (
+		visiting = iota + 1
+		visited
+	)
+	var states = make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("astibrain: dependency cycle detected: %v", append(path, name))
+		}
+		states[name] = visiting
+		for _, d := range m[name].options().Dependencies {
+			if _, ok := m[d]; !ok {
+				continue
+			}
+			if err := visit(d, append(path, name)); err != nil {
+				return err
+			}
+		}
+		states[name] = visited
+		o = append(o, m[name])
+		return nil
+	}
+	for _, n := range names {
+		if err = visit(n, nil); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// start starts an ability after making sure its dependencies are on.
+func (as *abilities) start(name string) (err error) {
+	// Retrieve ability
+	a, ok := as.ability(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+
+	// Check dependencies
+	for _, n := range a.options().Dependencies {
+		d, ok := as.ability(n)
+		if !ok {
+			err = fmt.Errorf("astibrain: unknown dependency %s of %s", n, name)
+			return
+		}
+		if !d.sm.state().IsOn() {
+			err = fmt.Errorf("astibrain: dependency %s of %s is off", n, name)
+			return
+		}
+	}
+
+	// Start
+	a.on()
+	return
+}
+
+// stop stops an ability after stopping the abilities depending on it.
+func (as *abilities) stop(name string) (err error) {
+	// Retrieve ability
+	a, ok := as.ability(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+
+	// Stop dependents
+	as.stopDependents(name)
+
+	// Stop
+	a.off()
+	return
+}
+
+// stopDependents stops the abilities depending on the provided ability.
+func (as *abilities) stopDependents(name string) {
+	// Get dependents without keeping the lock
+	var ds []string
+	as.abilities(func(a *ability) error {
+		for _, d := range a.options().Dependencies {
+			if d == name {
+				ds = append(ds, a.name)
+				break
+			}
+		}
+		return nil
+	})
+
+	// Stop dependents
+	for _, d := range ds {
+		as.stop(d)
+	}
+}
+
+// toWebSocket returns the websocket representation of the abilities indexed by name
+func (as *abilities) toWebSocket() (o map[string]WebSocketAbility) {
+	o = make(map[string]WebSocketAbility)
+	as.abilities(func(a *ability) error {
+		o[a.name] = a.toWebSocket()
+		return nil
+	})
+	return
+}
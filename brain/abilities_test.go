@@ -0,0 +1,96 @@
+package astibrain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbilitiesDependencies(t *testing.T) {
+	as := newAbilities()
+	assert.NoError(t, as.set(&ability{name: "c", o: AbilityOptions{Dependencies: []string{"b"}}}))
+	assert.NoError(t, as.set(&ability{name: "b", o: AbilityOptions{Dependencies: []string{"a"}}}))
+	assert.NoError(t, as.set(&ability{name: "a"}))
+	o, err := as.ordered()
+	assert.NoError(t, err)
+	var names []string
+	for _, a := range o {
+		names = append(names, a.name)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+	assert.Error(t, as.set(&ability{name: "a", o: AbilityOptions{Dependencies: []string{"c"}}}))
+}
+
+// mockedRunner is a runner running until its context is done
+type mockedRunner struct{}
+
+func (mockedRunner) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestAbilitiesStartStop(t *testing.T) {
+	// Learn
+	b := New(Options{})
+	assert.NoError(t, b.Learn("a", mockedRunner{}, AbilityOptions{}))
+	assert.NoError(t, b.Learn("b", mockedRunner{}, AbilityOptions{Dependencies: []string{"a"}}))
+	assert.NoError(t, b.Learn("c", mockedRunner{}, AbilityOptions{Dependencies: []string{"b"}}))
+
+	// Dependency is stopped
+	assert.EqualError(t, b.abilities.start("b"), "astibrain: dependency a of b is off")
+	assert.Equal(t, AbilityStateStopped, mustAbilityState(t, b, "b"))
+
+	// Start
+	for _, n := range []string{"a", "b", "c"} {
+		assert.NoError(t, b.abilities.start(n))
+		assert.NoError(t, b.WaitForAbilityState(n, time.Second, AbilityStateRunning))
+	}
+
+	// Stopping a dependency stops its dependents
+	assert.NoError(t, b.abilities.stop("a"))
+	for _, n := range []string{"a", "b", "c"} {
+		assert.NoError(t, b.WaitForAbilityState(n, time.Second, AbilityStateStopped))
+	}
+
+	// Stopping from both ends at the same time doesn't deadlock
+	for _, n := range []string{"a", "b", "c"} {
+		assert.NoError(t, b.abilities.start(n))
+		assert.NoError(t, b.WaitForAbilityState(n, time.Second, AbilityStateRunning))
+	}
+	go b.abilities.stop("c")
+	go b.abilities.stop("a")
+	for _, n := range []string{"a", "b", "c"} {
+		assert.NoError(t, b.WaitForAbilityState(n, time.Second, AbilityStateStopped))
+	}
+}
+
+func mustAbilityState(t *testing.T, b *Brain, name string) AbilityState {
+	s, err := b.AbilityState(name)
+	assert.NoError(t, err)
+	return s
+}
+
+// mockedClosingRunner is a runner recording whether it has been closed
+type mockedClosingRunner struct {
+	mockedRunner
+	closed bool
+}
+
+func (r *mockedClosingRunner) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestBrainCloseClosesAbilities(t *testing.T) {
+	b := New(Options{})
+	r := &mockedClosingRunner{}
+	assert.NoError(t, b.Learn("a", r, AbilityOptions{}))
+	assert.NoError(t, b.abilities.start("a"))
+	assert.NoError(t, b.WaitForAbilityState("a", time.Second, AbilityStateRunning))
+	b.Close()
+	assert.True(t, r.closed)
+	a, _ := b.abilities.ability("a")
+	assert.Equal(t, AbilityStateStopped, a.sm.state())
+}
@@ -2,6 +2,9 @@ package astibrain
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/asticode/go-astilog"
 	"github.com/pkg/errors"
@@ -17,77 +20,238 @@ type Initializer interface {
 	Init() error
 }
 
+// Restart policies
+const (
+	RestartPolicyNever     = "never"
+	RestartPolicyOnFailure = "on_failure"
+	// The ability is restarted on failure as well as when it has been unhealthy for too long
+	RestartPolicyOnUnhealthy = "on_unhealthy"
+)
+
 // AbilityOptions represents ability options
 type AbilityOptions struct {
-	AutoStart bool
+	AutoStart           bool          `toml:"auto_start"`
+	Dependencies        []string      `toml:"dependencies"` // Names of the abilities that need to be on for this ability to start
+	HealthCheckInterval time.Duration `toml:"health_check_interval"`
+	HealthCheckTimeout  time.Duration `toml:"health_check_timeout"`
+	RestartDelay        time.Duration `toml:"restart_delay"`
+	RestartPolicy       string        `toml:"restart_policy"`
+	UnhealthyThreshold  int           `toml:"unhealthy_threshold"`
 }
 
 // ability represents an ability.
 type ability struct {
-	name string
-	o    AbilityOptions
-	r    Runner
-	t    *toggle
-	ws   *webSocket
+	as     *abilities
+	cancel context.CancelFunc
+	health HealthStatus
+	m      sync.Mutex // Locks cancel and serializes state decisions
+	mh     sync.Mutex // Locks health
+	mo     sync.Mutex // Locks o
+	name   string
+	o      AbilityOptions
+	r      Runner
+	sm     *stateMachine
+	stats  *abilityStats
+	ws     *webSocket
 }
 
 // newAbility creates a new ability.
-func newAbility(name string, r Runner, ws *webSocket, o AbilityOptions) *ability {
-	return &ability{
-		name: name,
-		o:    o,
-		r:    r,
-		t:    newToggle(r.Run),
-		ws:   ws,
+func newAbility(name string, r Runner, as *abilities, ws *webSocket, o AbilityOptions) (a *ability) {
+	a = &ability{
+		as:     as,
+		health: HealthStatusUnknown,
+		name:   name,
+		o:      o,
+		r:      r,
+		stats:  &abilityStats{},
+		ws:     ws,
 	}
+	a.sm = newStateMachine(a.onTransition)
+	return
+}
+
+// options returns the ability options
+func (a *ability) options() AbilityOptions {
+	a.mo.Lock()
+	defer a.mo.Unlock()
+	return a.o
+}
+
+// onTransition is executed on each state transition
+func (a *ability) onTransition(from, to AbilityState) {
+	// Log
+	astilog.Infof("astibrain: %s transitioned from %s to %s", a.name, from, to)
+
+	// Dispatch websocket event
+	a.stats.eventEmitted()
+	a.ws.send(WebsocketEventNameAbilityTransitioned, WebSocketAbilityTransition{
+		From: from,
+		Name: a.name,
+		To:   to,
+	})
+}
+
+// toWebSocket returns the websocket representation of the ability
+func (a *ability) toWebSocket() WebSocketAbility {
+	s := a.sm.state()
+	o := WebSocketAbility{
+		Health: a.healthStatus(),
+		IsOn:   s.IsOn(),
+		Name:   a.name,
+		State:  s,
+	}
+	if as, ok := a.ws.audioStream(a.name); ok {
+		o.AudioFormat = &as.f
+	}
+	_, o.API = a.r.(APIHandler)
+	return o
 }
 
 // on switches the ability on.
 func (a *ability) on() {
-	// Ability is already on
-	if a.t.isOn() {
+	// Lock
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	// Transition
+	// An error means the ability is already on
+	if err := a.sm.transition(AbilityStateStarting); err != nil {
 		return
 	}
 
-	// Switch on
+	// Create context
+	var ctx context.Context
+	ctx, a.cancel = context.WithCancel(context.Background())
+
+	// Run in a go routine
 	astilog.Debugf("astibrain: switching %s on", a.name)
-	a.t.on()
+	go a.run(ctx)
+}
+
+// run runs the ability and handles restarts until it is switched off or it crashes without being restarted
+func (a *ability) run(ctx context.Context) {
+	for {
+		// Transition
+		a.m.Lock()
+		if a.sm.state() == AbilityStateStopping {
+			a.stopAndUnlock()
+			return
+		}
+		a.sm.transition(AbilityStateRunning)
+		a.m.Unlock()
+
+		// Run
+		a.stats.runStarted()
+		unhealthy, err := a.runOnce(ctx)
+		a.stats.runEnded()
+
+		// Lock
+		a.m.Lock()
+
+		// Ability has been switched off or has stopped by itself
+		if a.sm.state() == AbilityStateStopping || (!unhealthy && (err == nil || errors.Cause(err) == context.Canceled)) {
+			a.stopAndUnlock()
+			return
+		}
+
+		// Ability has been unhealthy for too long
+		if unhealthy {
+			err = fmt.Errorf("astibrain: %s has been unhealthy for too long", a.name)
+		}
+
+		// Ability has crashed
+		astilog.Error(errors.Wrapf(err, "astibrain: %s crashed", a.name))
+		a.stats.crashed()
+		a.stats.eventEmitted()
+		a.ws.send(WebsocketEventNameAbilityCrashed, WebSocketAbilityCrashed{
+			Error: err.Error(),
+			Name:  a.name,
+		})
+		a.sm.transition(AbilityStateCrashed)
+
+		// No restart
+		if p := a.options().RestartPolicy; p != RestartPolicyOnFailure && p != RestartPolicyOnUnhealthy {
+			a.cancel()
+			a.m.Unlock()
+			a.as.stopDependents(a.name)
+			return
+		}
 
-	// Wait for the end of execution in a go routine
+		// Restart
+		a.sm.transition(AbilityStateRestarting)
+		a.m.Unlock()
+
+		// Wait for the restart delay
+		select {
+		case <-time.After(a.options().RestartDelay):
+		case <-ctx.Done():
+		}
+
+		// Transition
+		a.m.Lock()
+		if a.sm.state() == AbilityStateStopping {
+			a.stopAndUnlock()
+			return
+		}
+		a.sm.transition(AbilityStateStarting)
+		a.m.Unlock()
+	}
+}
+
+// runOnce runs the ability once while checking its health if it implements the HealthChecker interface.
+// unhealthy is true if the run has been interrupted because the ability has been unhealthy for too long.
+func (a *ability) runOnce(ctx context.Context) (unhealthy bool, err error) {
+	// No health checks
+	hc, ok := a.r.(HealthChecker)
+	if !ok {
+		err = a.r.Run(ctx)
+		return
+	}
+
+	// Check health in a go routine
+	ctx, cancel := context.WithCancel(ctx)
+	var chanUnhealthy = make(chan bool)
 	go func() {
-		// Wait
-		if err := a.t.wait(); err != nil && err != context.Canceled {
-			// Log
-			astilog.Error(errors.Wrapf(err, "astibrain: %s crashed", a.name))
-
-			// Dispatch websocket event
-			a.ws.send(WebsocketEventNameAbilityCrashed, a.name)
-		} else {
-			// Log
-			astilog.Infof("astibrain: %s have been switched off", a.name)
-
-			// Dispatch websocket event
-			a.ws.send(WebsocketEventNameAbilityStopped, a.name)
+		restart := a.checkHealth(ctx, hc)
+		if restart {
+			cancel()
 		}
+		chanUnhealthy <- restart
 	}()
 
-	// Log
-	astilog.Infof("astibrain: %s have been switched on", a.name)
+	// Run
+	err = a.r.Run(ctx)
 
-	// Dispatch websocket event
-	a.ws.send(WebsocketEventNameAbilityStarted, a.name)
+	// Stop health checks
+	cancel()
+	unhealthy = <-chanUnhealthy
+	return
+}
+
+// stopAndUnlock transitions to the stopped state while making the assumption that the mutex is locked, and unlocks it.
+// Abilities depending on it are stopped once the mutex is unlocked so that ability locks are never nested.
+func (a *ability) stopAndUnlock() {
+	a.cancel()
+	a.sm.transition(AbilityStateStopped)
+	a.m.Unlock()
+	a.as.stopDependents(a.name)
 }
 
 // off switches the ability off.
 func (a *ability) off() {
-	// Ability is already off
-	if !a.t.isOn() {
+	// Lock
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	// Transition
+	// An error means the ability is already off
+	if err := a.sm.transition(AbilityStateStopping); err != nil {
 		return
 	}
 
 	// Switch off
 	astilog.Debugf("astibrain: switching %s off", a.name)
-	a.t.off()
+	a.cancel()
 
-	// The rest is handled through the wait function
+	// The rest is handled in the run function
 }
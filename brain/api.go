@@ -0,0 +1,235 @@
+package astibrain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astiws"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+// APIHandler represents an object exposing its own API.
+// Its handler receives requests whose path is relative to the ability API root.
+type APIHandler interface {
+	APIHandler() http.Handler
+}
+
+// abilityAPIHandler returns the API handler of an ability
+func (as *abilities) abilityAPIHandler(name string) (h http.Handler, err error) {
+	// Retrieve ability
+	a, ok := as.ability(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+
+	// Ability doesn't expose an API
+	ah, ok := a.r.(APIHandler)
+	if !ok {
+		err = fmt.Errorf("astibrain: ability %s doesn't expose an API", name)
+		return
+	}
+	return ah.APIHandler(), nil
+}
+
+// withPath returns a shallow copy of a request with a new path
+func withPath(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	r2.URL = &u
+	return r2
+}
+
+// handleAPIAbilityAPI forwards a request to an ability API.
+func (s *server) handleAPIAbilityAPI(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve handler
+	h, err := s.abilities.abilityAPIHandler(p.ByName("name"))
+	if err != nil {
+		rw.Header().Set("Content-Type", "application/json")
+		APIWriteError(rw, http.StatusNotFound, err)
+		return
+	}
+
+	// Serve
+	h.ServeHTTP(rw, withPath(r, p.ByName("path")))
+}
+
+// WebSocketAbilityAPIRequest is a websocket ability API request payload.
+// It allows Bob to reach abilities APIs without having access to the local server.
+type WebSocketAbilityAPIRequest struct {
+	Body        []byte `json:"body,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	ID          string `json:"id"`
+	Method      string `json:"method"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Query       string `json:"query,omitempty"`
+}
+
+// abilityAPIResponseChunkSize is the max size of the body of a websocket ability API response.
+// Bodies are base64 encoded in json, hence the margin with the websocket max message size.
+const abilityAPIResponseChunkSize = webSocketMaxMessageSize / 2
+
+// WebSocketAbilityAPIResponse is a websocket ability API response payload.
+// Bodies are streamed in chunks sent as successive responses with the same id. Content type and status code are only
+// set in the first one, and More is false in the last one.
+type WebSocketAbilityAPIResponse struct {
+	Body        []byte `json:"body,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	ID          string `json:"id"`
+	More        bool   `json:"more,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+}
+
+// abilityAPIResponseWriter is an http.ResponseWriter sending the body in chunks
+type abilityAPIResponseWriter struct {
+	buf        []byte
+	err        error // Error of the last chunk sent, once set nothing is sent anymore
+	fn         func(o WebSocketAbilityAPIResponse) error
+	h          http.Header
+	id         string
+	sent       bool // Whether the first chunk has been sent
+	statusCode int
+}
+
+// newAbilityAPIResponseWriter creates a new ability API response writer
+func newAbilityAPIResponseWriter(id string, fn func(o WebSocketAbilityAPIResponse) error) *abilityAPIResponseWriter {
+	return &abilityAPIResponseWriter{
+		fn: fn,
+		h:  make(http.Header),
+		id: id,
+	}
+}
+
+// Header implements the http.ResponseWriter interface
+func (w *abilityAPIResponseWriter) Header() http.Header {
+	return w.h
+}
+
+// WriteHeader implements the http.ResponseWriter interface
+func (w *abilityAPIResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// Write implements the http.ResponseWriter interface
+func (w *abilityAPIResponseWriter) Write(b []byte) (n int, err error) {
+	// Sending has failed
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.WriteHeader(http.StatusOK)
+	for len(b) > 0 {
+		// Buffer
+		m := abilityAPIResponseChunkSize - len(w.buf)
+		if m > len(b) {
+			m = len(b)
+		}
+		w.buf = append(w.buf, b[:m]...)
+		b = b[m:]
+		n += m
+
+		// Send chunk
+		if len(w.buf) == abilityAPIResponseChunkSize {
+			if err = w.send(true); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// send sends the buffered chunk
+func (w *abilityAPIResponseWriter) send(more bool) (err error) {
+	// Sending has failed
+	if w.err != nil {
+		return w.err
+	}
+
+	// Create response
+	o := WebSocketAbilityAPIResponse{
+		Body: w.buf,
+		ID:   w.id,
+		More: more,
+	}
+	if !w.sent {
+		w.WriteHeader(http.StatusOK)
+		o.ContentType = w.h.Get("Content-Type")
+		o.StatusCode = w.statusCode
+		w.sent = true
+	}
+	w.buf = nil
+
+	// Send
+	if err = w.fn(o); err != nil {
+		err = errors.Wrap(err, "astibrain: sending ability API response chunk failed")
+		w.err = err
+		return
+	}
+	return
+}
+
+// close sends the last chunk
+func (w *abilityAPIResponseWriter) close() error {
+	return w.send(false)
+}
+
+// handleAbilityAPIRequest handles the websocket ability.api.request event
+func (ws *webSocket) handleAbilityAPIRequest(c *astiws.Client, eventName string, payload json.RawMessage) (err error) {
+	// Decode payload
+	var p WebSocketAbilityAPIRequest
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibrain: json unmarshaling ability.api.request payload %s failed", payload)
+		return
+	}
+
+	// Serve in the background so that reading is not blocked
+	go func() {
+		if err := ws.serveAbilityAPI(p, func(o WebSocketAbilityAPIResponse) error {
+			return ws.c.Write(WebsocketEventNameAbilityAPIResponse, o)
+		}); err != nil {
+			astilog.Error(errors.Wrapf(err, "astibrain: serving %s %s of ability %s for Bob failed", p.Method, p.Path, p.Name))
+		}
+	}()
+	return
+}
+
+// serveAbilityAPI serves an ability API request and executes fn on each chunk of the response
+func (ws *webSocket) serveAbilityAPI(p WebSocketAbilityAPIRequest, fn func(o WebSocketAbilityAPIResponse) error) (err error) {
+	// Create response writer
+	rw := newAbilityAPIResponseWriter(p.ID, fn)
+
+	// Retrieve handler
+	h, err := ws.abilities.abilityAPIHandler(p.Name)
+	if err != nil {
+		rw.Header().Set("Content-Type", "application/json")
+		APIWriteError(rw, http.StatusNotFound, err)
+	} else {
+		// Create request
+		var r *http.Request
+		if r, err = http.NewRequest(p.Method, p.Path, bytes.NewReader(p.Body)); err != nil {
+			rw.Header().Set("Content-Type", "application/json")
+			APIWriteError(rw, http.StatusBadRequest, errors.Wrap(err, "astibrain: creating request failed"))
+		} else {
+			// Serve
+			r.URL.RawQuery = p.Query
+			if len(p.ContentType) > 0 {
+				r.Header.Set("Content-Type", p.ContentType)
+			}
+			astilog.Debugf("astibrain: serving %s %s of ability %s for Bob", p.Method, p.Path, p.Name)
+			h.ServeHTTP(rw, r)
+		}
+	}
+
+	// Send last chunk
+	return rw.close()
+}
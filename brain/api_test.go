@@ -0,0 +1,84 @@
+package astibrain
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockedAPIRunnable is a runnable exposing an API echoing requests
+type mockedAPIRunnable struct{}
+
+func (r mockedAPIRunnable) Run(ctx context.Context) error { return nil }
+
+func (r mockedAPIRunnable) APIHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusCreated)
+		rw.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + " " + string(b)))
+	})
+}
+
+func TestServeAbilityAPI(t *testing.T) {
+	as := newAbilities()
+	assert.NoError(t, as.set(&ability{name: "a", r: mockedAPIRunnable{}}))
+	ws := &webSocket{abilities: as}
+	var rs []WebSocketAbilityAPIResponse
+	fn := func(o WebSocketAbilityAPIResponse) error {
+		rs = append(rs, o)
+		return nil
+	}
+	assert.NoError(t, ws.serveAbilityAPI(WebSocketAbilityAPIRequest{Body: []byte("body"), ID: "1", Method: http.MethodPost, Name: "a", Path: "/path", Query: "k=v"}, fn))
+	assert.Equal(t, []WebSocketAbilityAPIResponse{{Body: []byte("POST /path?k=v body"), ContentType: "text/plain", ID: "1", StatusCode: http.StatusCreated}}, rs)
+	rs = nil
+	assert.NoError(t, ws.serveAbilityAPI(WebSocketAbilityAPIRequest{ID: "2", Method: http.MethodGet, Name: "b", Path: "/"}, fn))
+	if assert.Len(t, rs, 1) {
+		assert.Equal(t, http.StatusNotFound, rs[0].StatusCode)
+	}
+}
+
+func TestAbilityAPIResponseWriter(t *testing.T) {
+	// Body is sent in chunks
+	var rs []WebSocketAbilityAPIResponse
+	w := newAbilityAPIResponseWriter("1", func(o WebSocketAbilityAPIResponse) error {
+		rs = append(rs, o)
+		return nil
+	})
+	w.Header().Set("Content-Type", "application/octet-stream")
+	b := bytes.Repeat([]byte("a"), 2*abilityAPIResponseChunkSize+1)
+	n, err := w.Write(b)
+	assert.NoError(t, err)
+	assert.Equal(t, len(b), n)
+	assert.NoError(t, w.close())
+	if !assert.Len(t, rs, 3) {
+		return
+	}
+	assert.Equal(t, "application/octet-stream", rs[0].ContentType)
+	assert.Equal(t, http.StatusOK, rs[0].StatusCode)
+	var body []byte
+	for i, o := range rs {
+		assert.Equal(t, "1", o.ID)
+		assert.Equal(t, i < 2, o.More)
+		if i > 0 {
+			assert.Equal(t, 0, o.StatusCode)
+		}
+		body = append(body, o.Body...)
+	}
+	assert.Equal(t, b, body)
+
+	// Sending stops once it has failed
+	var count int
+	w = newAbilityAPIResponseWriter("2", func(o WebSocketAbilityAPIResponse) error {
+		count++
+		return context.Canceled
+	})
+	_, err = w.Write(b)
+	assert.Error(t, err)
+	assert.Error(t, w.close())
+	assert.Equal(t, 1, count)
+}
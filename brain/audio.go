@@ -0,0 +1,225 @@
+package astibrain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astiws"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// audioFrameDuration is the duration of audio sent in each frame
+const audioFrameDuration = 100 * time.Millisecond
+
+// AudioFormat represents an audio format
+type AudioFormat struct {
+	BitDepth    int `json:"bit_depth"`
+	NumChannels int `json:"num_channels"`
+	SampleRate  int `json:"sample_rate"`
+}
+
+// AudioStream streams an ability's audio samples to Bob while Bob is subscribed to it
+type AudioStream struct {
+	buf  []int32
+	f    AudioFormat
+	isOn bool
+	m    sync.Mutex // Locks buf, isOn and seq
+	name string
+	seq  uint32
+	ws   *webSocket
+}
+
+// NewAudioStream creates a new audio stream for an ability.
+// Samples written to the stream are only sent when Bob has subscribed to it.
+func (b *Brain) NewAudioStream(name string, f AudioFormat) (s *AudioStream, err error) {
+	// Retrieve ability
+	if _, ok := b.abilities.ability(name); !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+
+	// Check format
+	if f.BitDepth%8 != 0 || f.BitDepth <= 0 || f.BitDepth > 32 || f.NumChannels <= 0 || f.SampleRate <= 0 {
+		err = fmt.Errorf("astibrain: invalid audio format %+v", f)
+		return
+	}
+
+	// Create stream
+	s = &AudioStream{
+		f:    f,
+		name: name,
+		ws:   b.ws,
+	}
+	b.ws.setAudioStream(s)
+	return
+}
+
+// frameSize returns the number of samples in a frame
+func (s *AudioStream) frameSize() int {
+	return int(audioFrameDuration.Seconds()*float64(s.f.SampleRate)) * s.f.NumChannels
+}
+
+// setOn switches the stream on or off
+func (s *AudioStream) setOn(isOn bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.isOn = isOn
+	s.buf = s.buf[:0]
+}
+
+// Write writes interleaved samples to the stream.
+// It never blocks on the network: frames are dropped if they can't be sent fast enough.
+func (s *AudioStream) Write(samples []int32) {
+	// Lock
+	s.m.Lock()
+
+	// Stream is off
+	if !s.isOn {
+		s.m.Unlock()
+		return
+	}
+
+	// Buffer
+	s.buf = append(s.buf, samples...)
+	if len(s.buf) < s.frameSize() {
+		s.m.Unlock()
+		return
+	}
+
+	// Create frame
+	var f = AudioFrame{
+		Data:     encodePCM(s.buf, s.f.BitDepth),
+		Format:   s.f,
+		Name:     s.name,
+		Sequence: s.seq,
+	}
+	s.buf = s.buf[:0]
+	s.seq++
+	s.m.Unlock()
+
+	// Send
+	s.ws.abilities.eventEmitted(s.name)
+	s.ws.sendAudio(f)
+}
+
+// encodePCM encodes samples to little-endian signed PCM
+func encodePCM(samples []int32, bitDepth int) (o []byte) {
+	var n = bitDepth / 8
+	o = make([]byte, len(samples)*n)
+	for i, s := range samples {
+		for j := 0; j < n; j++ {
+			o[i*n+j] = byte(s >> uint(8*j))
+		}
+	}
+	return
+}
+
+// sendAudio queues an audio frame on the audio websocket and drops it if the audio websocket is not connected
+func (ws *webSocket) sendAudio(f AudioFrame) {
+	ws.m.Lock()
+	var a = ws.as
+	ws.m.Unlock()
+	if a != nil {
+		a.Write(f)
+	}
+}
+
+// dialAudio dials the audio websocket and reads audio frames until the context is done.
+// Bob may not have processed the register event yet, in which case dialing is retried.
+func (ws *webSocket) dialAudio(ctx context.Context, url, name string) {
+	var b = newBackoff(ws.o.Backoff)
+	for {
+		// Dial
+		c, _, err := websocket.DefaultDialer.Dial(url+AudioWebSocketPath+"?name="+neturl.QueryEscape(name), nil)
+		if err != nil {
+			astilog.Error(errors.Wrapf(err, "astibrain: dialing audio websocket %s failed", url))
+		} else {
+			// Store audio websocket
+			a := NewAudioWebSocket(c, url+AudioWebSocketPath)
+			ws.m.Lock()
+			ws.as = a
+			ws.m.Unlock()
+
+			// Close once the context is done
+			go func() {
+				select {
+				case <-a.Done():
+				case <-ctx.Done():
+					a.Close()
+				}
+			}()
+
+			// Read
+			if err = a.Read(ws.handleAudioFrame); err != nil {
+				astilog.Error(errors.Wrap(err, "astibrain: reading audio websocket failed"))
+			}
+			a.Close()
+
+			// Remove audio websocket
+			ws.m.Lock()
+			if ws.as == a {
+				ws.as = nil
+			}
+			ws.m.Unlock()
+		}
+
+		// Wait
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.next()):
+		}
+	}
+}
+
+// setAudioStream stores an audio stream
+func (ws *webSocket) setAudioStream(s *AudioStream) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	ws.audio[s.name] = s
+}
+
+// audioStream returns the audio stream of an ability
+func (ws *webSocket) audioStream(name string) (s *AudioStream, ok bool) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	s, ok = ws.audio[name]
+	return
+}
+
+// handleAudioStart handles the websocket audio.start event
+func (ws *webSocket) handleAudioStart(c *astiws.Client, eventName string, payload json.RawMessage) error {
+	return ws.toggleAudio(payload, true)
+}
+
+// handleAudioStop handles the websocket audio.stop event
+func (ws *webSocket) handleAudioStop(c *astiws.Client, eventName string, payload json.RawMessage) error {
+	return ws.toggleAudio(payload, false)
+}
+
+// toggleAudio switches an audio stream on or off
+func (ws *webSocket) toggleAudio(payload json.RawMessage, isOn bool) (err error) {
+	// Decode payload
+	var name string
+	if err = json.Unmarshal(payload, &name); err != nil {
+		err = errors.Wrapf(err, "astibrain: json unmarshaling audio payload %s failed", payload)
+		return
+	}
+
+	// Retrieve stream
+	s, ok := ws.audioStream(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: no audio stream for ability %s", name)
+		return
+	}
+
+	// Toggle
+	s.setOn(isOn)
+	return
+}
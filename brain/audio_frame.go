@@ -0,0 +1,218 @@
+package astibrain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// Audio websocket constants
+const (
+	audioFrameHeaderSize         = 12 // Without the name
+	audioFrameVersion            = 1
+	audioWebSocketMaxMessageSize = 1 << 20
+	audioWebSocketQueueSize      = 32
+	audioWebSocketWriteTimeout   = 5 * time.Second
+)
+
+// AudioWebSocketPath is the path of the websocket dedicated to audio, relative to the websocket path
+const AudioWebSocketPath = "/audio"
+
+// AudioFrame is an audio frame exchanged as a binary websocket message.
+// Its binary representation is a header followed by the data:
+//
+//	version (1 byte) | name length (1 byte) | name | bit depth (1 byte) | number of channels (1 byte) |
+//	sample rate (4 bytes) | sequence (4 bytes) | data
+//
+// Integers are little-endian.
+type AudioFrame struct {
+	Data     []byte // Interleaved little-endian signed PCM
+	Format   AudioFormat
+	Name     string
+	Sequence uint32
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface
+func (f AudioFrame) MarshalBinary() (b []byte, err error) {
+	// Check name
+	if len(f.Name) > 255 {
+		err = fmt.Errorf("astibrain: audio frame name %s is too long", f.Name)
+		return
+	}
+
+	// Write header
+	b = make([]byte, audioFrameHeaderSize+len(f.Name)+len(f.Data))
+	b[0] = audioFrameVersion
+	b[1] = byte(len(f.Name))
+	var i = 2 + copy(b[2:], f.Name)
+	b[i] = byte(f.Format.BitDepth)
+	b[i+1] = byte(f.Format.NumChannels)
+	binary.LittleEndian.PutUint32(b[i+2:], uint32(f.Format.SampleRate))
+	binary.LittleEndian.PutUint32(b[i+6:], f.Sequence)
+
+	// Write data
+	copy(b[i+10:], f.Data)
+	return
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// Data is not copied.
+func (f *AudioFrame) UnmarshalBinary(b []byte) (err error) {
+	// Check version
+	if len(b) < 2 {
+		err = fmt.Errorf("astibrain: audio frame of %d bytes is too short", len(b))
+		return
+	} else if b[0] != audioFrameVersion {
+		err = fmt.Errorf("astibrain: unsupported audio frame version %d", b[0])
+		return
+	}
+
+	// Check length
+	var n = int(b[1])
+	if len(b) < audioFrameHeaderSize+n {
+		err = fmt.Errorf("astibrain: audio frame of %d bytes is too short", len(b))
+		return
+	}
+
+	// Read header
+	f.Name = string(b[2 : 2+n])
+	var i = 2 + n
+	f.Format = AudioFormat{
+		BitDepth:    int(b[i]),
+		NumChannels: int(b[i+1]),
+		SampleRate:  int(binary.LittleEndian.Uint32(b[i+2:])),
+	}
+	f.Sequence = binary.LittleEndian.Uint32(b[i+6:])
+
+	// Check data
+	f.Data = b[i+10:]
+	if f.Format.BitDepth%8 != 0 || f.Format.BitDepth <= 0 || f.Format.NumChannels <= 0 {
+		err = fmt.Errorf("astibrain: invalid audio frame format %+v", f.Format)
+		return
+	} else if len(f.Data)%(f.Format.BitDepth/8*f.Format.NumChannels) != 0 {
+		err = fmt.Errorf("astibrain: audio frame data of %d bytes doesn't contain whole samples", len(f.Data))
+		return
+	}
+	return
+}
+
+// AudioWebSocket exchanges audio frames as binary messages over a websocket.
+// Frames are written by a dedicated goroutine through a bounded queue: they are dropped when the queue is full so
+// that a slow peer never blocks the writer.
+type AudioWebSocket struct {
+	c    *websocket.Conn
+	done chan struct{}
+	name string
+	once sync.Once
+	q    chan []byte
+}
+
+// NewAudioWebSocket creates a new audio websocket and starts writing queued frames.
+// It must be closed once done.
+func NewAudioWebSocket(c *websocket.Conn, name string) (w *AudioWebSocket) {
+	w = &AudioWebSocket{
+		c:    c,
+		done: make(chan struct{}),
+		name: name,
+		q:    make(chan []byte, audioWebSocketQueueSize),
+	}
+	c.SetReadLimit(audioWebSocketMaxMessageSize)
+	go w.write()
+	return
+}
+
+// write writes queued frames until the websocket is closed
+func (w *AudioWebSocket) write() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case b := <-w.q:
+			w.c.SetWriteDeadline(time.Now().Add(audioWebSocketWriteTimeout))
+			if err := w.c.WriteMessage(websocket.BinaryMessage, b); err != nil {
+				astilog.Error(errors.Wrapf(err, "astibrain: writing audio frame to %s failed", w.name))
+				w.Close()
+				return
+			}
+		}
+	}
+}
+
+// Write queues a frame and returns false if it has been dropped
+func (w *AudioWebSocket) Write(f AudioFrame) (ok bool) {
+	// Marshal
+	b, err := f.MarshalBinary()
+	if err != nil {
+		astilog.Error(errors.Wrap(err, "astibrain: marshaling audio frame failed"))
+		return
+	}
+
+	// Closed
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+
+	// Queue
+	select {
+	case w.q <- b:
+		ok = true
+	default:
+		astilog.Debugf("astibrain: audio websocket %s queue is full, dropping frame #%d of %s", w.name, f.Sequence, f.Name)
+	}
+	return
+}
+
+// Read reads frames until the websocket is closed and executes fn for each of them.
+// Frames that can't be unmarshaled are ignored.
+func (w *AudioWebSocket) Read(fn func(f AudioFrame)) (err error) {
+	for {
+		// Read message
+		var t int
+		var b []byte
+		if t, b, err = w.c.ReadMessage(); err != nil {
+			select {
+			case <-w.done:
+				err = nil
+			default:
+				err = errors.Wrapf(err, "astibrain: reading audio websocket %s failed", w.name)
+			}
+			return
+		}
+
+		// Only binary messages are audio frames
+		if t != websocket.BinaryMessage {
+			continue
+		}
+
+		// Unmarshal
+		var f AudioFrame
+		if err := f.UnmarshalBinary(b); err != nil {
+			astilog.Error(errors.Wrapf(err, "astibrain: unmarshaling audio frame from %s failed", w.name))
+			continue
+		}
+		fn(f)
+	}
+}
+
+// Done returns a channel closed once the websocket is closed
+func (w *AudioWebSocket) Done() <-chan struct{} {
+	return w.done
+}
+
+// Close implements the io.Closer interface
+func (w *AudioWebSocket) Close() (err error) {
+	w.once.Do(func() {
+		close(w.done)
+		if err = w.c.Close(); err != nil {
+			err = errors.Wrapf(err, "astibrain: closing audio websocket %s failed", w.name)
+		}
+	})
+	return
+}
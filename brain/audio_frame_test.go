@@ -0,0 +1,82 @@
+package astibrain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioFrame(t *testing.T) {
+	// Round trip
+	f := AudioFrame{
+		Data:     encodePCM([]int32{1, -2, 3, -4}, 16),
+		Format:   AudioFormat{BitDepth: 16, NumChannels: 2, SampleRate: 44100},
+		Name:     "Hearing",
+		Sequence: 1 << 20,
+	}
+	b, err := f.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, audioFrameHeaderSize+len(f.Name)+len(f.Data), len(b))
+	var o AudioFrame
+	assert.NoError(t, o.UnmarshalBinary(b))
+	assert.Equal(t, f, o)
+
+	// Invalid frames
+	for _, v := range [][]byte{
+		nil,
+		{2, 0, 16, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+		b[:audioFrameHeaderSize],
+		b[:len(b)-1],
+		{1, 0, 12, 1, 0, 0, 0, 0, 0, 0, 0, 0},
+	} {
+		assert.Error(t, o.UnmarshalBinary(v))
+	}
+
+	// Name is too long
+	_, err = AudioFrame{Name: strings.Repeat("a", 256)}.MarshalBinary()
+	assert.Error(t, err)
+}
+
+func TestAudioWebSocket(t *testing.T) {
+	// Create server echoing frames
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			return
+		}
+		w := NewAudioWebSocket(c, "server")
+		defer w.Close()
+		w.Read(func(f AudioFrame) { w.Write(f) })
+	}))
+	defer s.Close()
+
+	// Dial
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http"), nil)
+	assert.NoError(t, err)
+	w := NewAudioWebSocket(c, "client")
+
+	// Read
+	fs := make(chan AudioFrame)
+	done := make(chan error)
+	go func() { done <- w.Read(func(f AudioFrame) { fs <- f }) }()
+
+	// Write
+	f := AudioFrame{Data: []byte{1, 2}, Format: AudioFormat{BitDepth: 16, NumChannels: 1, SampleRate: 16000}, Name: "a", Sequence: 3}
+	assert.True(t, w.Write(f))
+	select {
+	case o := <-fs:
+		assert.Equal(t, f, o)
+	case <-time.After(time.Second):
+		t.Fatal("frame was not received in time")
+	}
+
+	// Close
+	assert.NoError(t, w.Close())
+	assert.NoError(t, <-done)
+	assert.False(t, w.Write(f))
+}
@@ -0,0 +1,185 @@
+package astibrain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// Audio input constants
+const (
+	audioInputMaxBufferDuration = 10 * time.Second
+	audioInputReadTimeout       = 100 * time.Millisecond
+)
+
+// AudioInput is a sample reader fed with audio sent through Bob such as a browser microphone.
+// It implements the astihearing.SampleReader and astihearing.SamplesReader interfaces.
+type AudioInput struct {
+	c    chan struct{} // Notifies readers that samples have been written
+	f    AudioFormat
+	m    sync.Mutex // Locks q
+	max  int
+	name string
+	q    []int32
+}
+
+// audioInputTimeoutError is returned when no sample has been received in time so that readers can check their context
+type audioInputTimeoutError struct{}
+
+// Error implements the error interface
+func (audioInputTimeoutError) Error() string {
+	return "astibrain: no audio input sample received in time"
+}
+
+// Timeout indicates the error is a timeout
+func (audioInputTimeoutError) Timeout() bool { return true }
+
+// NewAudioInput creates a new audio input that can be fed through Bob.
+// The sender is expected to send samples in the provided format.
+func (b *Brain) NewAudioInput(name string, f AudioFormat) (i *AudioInput, err error) {
+	// Check format
+	if f.BitDepth%8 != 0 || f.BitDepth <= 0 || f.BitDepth > 32 || f.NumChannels <= 0 || f.SampleRate <= 0 {
+		err = fmt.Errorf("astibrain: invalid audio format %+v", f)
+		return
+	}
+
+	// Create input
+	i = &AudioInput{
+		c:    make(chan struct{}, 1),
+		f:    f,
+		max:  int(audioInputMaxBufferDuration.Seconds()) * f.SampleRate * f.NumChannels,
+		name: name,
+	}
+	b.ws.setAudioInput(i)
+	return
+}
+
+// write decodes and buffers PCM data.
+// If the buffer is full, oldest samples are dropped.
+func (i *AudioInput) write(data []byte) {
+	// Decode
+	var samples = decodePCM(data, i.f.BitDepth)
+
+	// Buffer
+	i.m.Lock()
+	i.q = append(i.q, samples...)
+	if len(i.q) > i.max {
+		astilog.Warnf("astibrain: audio input %s buffer is full, dropping %d samples", i.name, len(i.q)-i.max)
+		i.q = i.q[len(i.q)-i.max:]
+	}
+	i.m.Unlock()
+
+	// Notify
+	select {
+	case i.c <- struct{}{}:
+	default:
+	}
+}
+
+// ReadSamples implements the astihearing.SamplesReader interface.
+// It returns as soon as samples are available, even if there are fewer of them than requested.
+// If no sample is received in time, an error with a Timeout() method returning true is returned.
+func (i *AudioInput) ReadSamples(samples []int32) (n int, err error) {
+	var t *time.Timer
+	for {
+		// Pop samples
+		i.m.Lock()
+		if len(i.q) > 0 {
+			n = copy(samples, i.q)
+			i.q = i.q[n:]
+			i.m.Unlock()
+			if t != nil {
+				t.Stop()
+			}
+			return
+		}
+		i.m.Unlock()
+
+		// Wait for samples
+		if t == nil {
+			t = time.NewTimer(audioInputReadTimeout)
+		}
+		select {
+		case <-i.c:
+		case <-t.C:
+			err = audioInputTimeoutError{}
+			return
+		}
+	}
+}
+
+// ReadSample implements the astihearing.SampleReader interface.
+// If no sample is received in time, an error with a Timeout() method returning true is returned.
+func (i *AudioInput) ReadSample() (s int32, err error) {
+	var b [1]int32
+	if _, err = i.ReadSamples(b[:]); err != nil {
+		return
+	}
+	s = b[0]
+	return
+}
+
+// decodePCM decodes little-endian signed PCM
+func decodePCM(data []byte, bitDepth int) (o []int32) {
+	var n = bitDepth / 8
+	o = make([]int32, len(data)/n)
+	for i := range o {
+		var v uint32
+		for j := 0; j < n; j++ {
+			v |= uint32(data[i*n+j]) << uint(8*j)
+		}
+		// Sign extend
+		o[i] = int32(v<<uint(32-bitDepth)) >> uint(32-bitDepth)
+	}
+	return
+}
+
+// setAudioInput stores an audio input
+func (ws *webSocket) setAudioInput(i *AudioInput) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	ws.inputs[i.name] = i
+}
+
+// audioInputs returns the websocket representation of the audio inputs indexed by name
+func (ws *webSocket) audioInputs() (o map[string]AudioFormat) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	o = make(map[string]AudioFormat)
+	for n, i := range ws.inputs {
+		o[n] = i.f
+	}
+	return
+}
+
+// handleAudioFrame handles audio frames received on the audio websocket, which are sent to audio inputs
+func (ws *webSocket) handleAudioFrame(f AudioFrame) {
+	if err := ws.writeAudioInput(f); err != nil {
+		astilog.Error(errors.Wrap(err, "astibrain: writing audio input failed"))
+	}
+}
+
+// writeAudioInput writes an audio frame to its audio input
+func (ws *webSocket) writeAudioInput(f AudioFrame) (err error) {
+	// Retrieve input
+	ws.m.Lock()
+	i, ok := ws.inputs[f.Name]
+	ws.m.Unlock()
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown audio input %s", f.Name)
+		return
+	}
+
+	// Check format
+	if f.Format != i.f {
+		err = fmt.Errorf("astibrain: audio frame format %+v doesn't match audio input %s format %+v", f.Format, f.Name, i.f)
+		return
+	}
+
+	// Write
+	i.write(f.Data)
+	return
+}
@@ -0,0 +1,44 @@
+package astibrain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioInput(t *testing.T) {
+	// Create input
+	b := New(Options{})
+	i, err := b.NewAudioInput("test", AudioFormat{BitDepth: 16, NumChannels: 1, SampleRate: 16000})
+	assert.NoError(t, err)
+
+	// No sample
+	_, err = i.ReadSample()
+	assert.Error(t, err)
+	assert.True(t, err.(audioInputTimeoutError).Timeout())
+
+	// Fake client sending a frame through Bob
+	f := AudioFrame{Data: encodePCM([]int32{1, -2, 32767, -32768}, 16), Format: i.f, Name: "test"}
+	assert.NoError(t, b.ws.writeAudioInput(f))
+	for _, e := range []int32{1, -2, 32767, -32768} {
+		s, err := i.ReadSample()
+		assert.NoError(t, err)
+		assert.Equal(t, e, s)
+	}
+
+	// Batch
+	assert.NoError(t, b.ws.writeAudioInput(f))
+	var ss = make([]int32, 3)
+	n, err := i.ReadSamples(ss)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{1, -2, 32767}, ss[:n])
+	n, err = i.ReadSamples(ss)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{-32768}, ss[:n])
+
+	// Unknown input
+	assert.Error(t, b.ws.writeAudioInput(AudioFrame{Format: i.f, Name: "unknown"}))
+
+	// Wrong format
+	assert.Error(t, b.ws.writeAudioInput(AudioFrame{Format: AudioFormat{BitDepth: 32, NumChannels: 1, SampleRate: 16000}, Name: "test"}))
+}
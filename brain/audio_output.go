@@ -0,0 +1,56 @@
+package astibrain
+
+import (
+	"sort"
+)
+
+// WebSocketAudioOutput is a websocket audio output payload
+type WebSocketAudioOutput struct {
+	Data []byte `json:"data"` // WAV file
+	Name string `json:"name"`
+}
+
+// AudioOutput sends audio rendered by an ability through Bob so that it's played by subscribed clients such as a
+// browser.
+type AudioOutput struct {
+	name string
+	ws   *webSocket
+}
+
+// NewAudioOutput creates a new audio output that can be played through Bob.
+func (b *Brain) NewAudioOutput(name string) (o *AudioOutput) {
+	o = &AudioOutput{
+		name: name,
+		ws:   b.ws,
+	}
+	b.ws.setAudioOutput(o)
+	return
+}
+
+// Play sends a WAV file to Bob.
+// Audio is live data: it is dropped if the brain is not connected.
+func (o *AudioOutput) Play(wav []byte) {
+	o.ws.abilities.eventEmitted(o.name)
+	o.ws.sendLive(WebsocketEventNameAudioOutput, WebSocketAudioOutput{
+		Data: wav,
+		Name: o.name,
+	})
+}
+
+// setAudioOutput stores an audio output
+func (ws *webSocket) setAudioOutput(o *AudioOutput) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	ws.outputs[o.name] = o
+}
+
+// audioOutputs returns the sorted names of the audio outputs
+func (ws *webSocket) audioOutputs() (o []string) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	for n := range ws.outputs {
+		o = append(o, n)
+	}
+	sort.Strings(o)
+	return
+}
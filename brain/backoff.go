@@ -0,0 +1,78 @@
+package astibrain
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Default backoff options
+const (
+	defaultBackoffInitialInterval = time.Second
+	defaultBackoffJitter          = 0.2
+	defaultBackoffMaxInterval     = time.Minute
+	defaultBackoffMultiplier      = 2
+)
+
+// BackoffOptions are exponential backoff options
+type BackoffOptions struct {
+	InitialInterval time.Duration `toml:"initial_interval"`
+	Jitter          *float64      `toml:"jitter"` // Randomization factor between 0 and 1, defaults to 0.2 when unset
+	MaxInterval     time.Duration `toml:"max_interval"`
+	Multiplier      float64       `toml:"multiplier"`
+}
+
+// backoff computes exponentially growing intervals with jitter
+type backoff struct {
+	interval time.Duration
+	jitter   float64
+	o        BackoffOptions
+	r        *rand.Rand
+}
+
+// newBackoff creates a new backoff
+func newBackoff(o BackoffOptions) (b *backoff) {
+	// Default options
+	if o.InitialInterval <= 0 {
+		o.InitialInterval = defaultBackoffInitialInterval
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = defaultBackoffMaxInterval
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = defaultBackoffMultiplier
+	}
+
+	// Create backoff
+	b = &backoff{
+		jitter: defaultBackoffJitter,
+		o:      o,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if o.Jitter != nil && *o.Jitter >= 0 && *o.Jitter <= 1 {
+		b.jitter = *o.Jitter
+	}
+	b.reset()
+	return
+}
+
+// reset resets the interval to its initial value
+func (b *backoff) reset() {
+	b.interval = b.o.InitialInterval
+}
+
+// next returns the next interval to wait for
+func (b *backoff) next() (d time.Duration) {
+	// Apply jitter
+	d = b.interval
+	if b.jitter > 0 {
+		delta := b.jitter * float64(d)
+		d = time.Duration(float64(d) - delta + b.r.Float64()*2*delta)
+	}
+
+	// Increase interval
+	b.interval = time.Duration(float64(b.interval) * b.o.Multiplier)
+	if b.interval > b.o.MaxInterval {
+		b.interval = b.o.MaxInterval
+	}
+	return
+}
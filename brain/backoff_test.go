@@ -0,0 +1,31 @@
+package astibrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	var jitter = func(f float64) *float64 { return &f }
+	b := newBackoff(BackoffOptions{InitialInterval: time.Second, Jitter: jitter(0), MaxInterval: 3 * time.Second, Multiplier: 2})
+	assert.Equal(t, time.Second, b.next())
+	assert.Equal(t, 2*time.Second, b.next())
+	assert.Equal(t, 3*time.Second, b.next())
+	assert.Equal(t, 3*time.Second, b.next())
+	b.reset()
+	assert.Equal(t, time.Second, b.next())
+
+	// Default jitter
+	assert.Equal(t, defaultBackoffJitter, newBackoff(BackoffOptions{}).jitter)
+	assert.Equal(t, defaultBackoffJitter, newBackoff(BackoffOptions{Jitter: jitter(2)}).jitter)
+
+	// Jitter
+	b = newBackoff(BackoffOptions{InitialInterval: time.Second, Jitter: jitter(0.5)})
+	for i := 0; i < 10; i++ {
+		b.reset()
+		d := b.next()
+		assert.True(t, d >= 500*time.Millisecond && d <= 1500*time.Millisecond)
+	}
+}
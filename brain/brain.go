@@ -2,26 +2,36 @@ package astibrain
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/asticode/go-astilog"
 	"github.com/pkg/errors"
 )
 
+// abilityStopTimeout represents the max duration to wait for an ability to stop when closing the brain
+const abilityStopTimeout = 10 * time.Second
+
 // Brain is an object handling one or more abilities
 type Brain struct {
 	abilities *abilities
 	cancel    context.CancelFunc
 	ctx       context.Context
+	m         sync.Mutex // Locks o and started
 	o         Options
+	s         *server
+	started   bool // Whether abilities have been initialized and auto started
 	ws        *webSocket
 }
 
 // Options are brain's options
 type Options struct {
-	Name      string           `toml:"name"`
-	WebSocket WebSocketOptions `toml:"websocket"`
+	Name          string           `toml:"name"`
+	Server        ServerOptions    `toml:"server"`
+	StatsInterval time.Duration    `toml:"stats_interval"`
+	WebSocket     WebSocketOptions `toml:"websocket"`
 }
 
 // New creates a new brain
@@ -34,35 +44,32 @@ func New(o Options) (b *Brain) {
 
 	// Add websocket
 	b.ws = newWebSocket(b.abilities, o.WebSocket)
+
+	// Add local server
+	if o.Server.Enabled {
+		b.s = newServer(o.Name, b.abilities, b.ws, o.Server)
+	}
 	return
 }
 
 // Close implements the io.Closer interface
 func (b *Brain) Close() (err error) {
-	// Close abilities
-	b.abilities.abilities(func(a *ability) error {
-		// Log
-		astilog.Debugf("astibrain: closing ability %s", a.name)
-
-		// Switch the ability off
-		a.off()
-
-		// Wait for the toggle to be switched off indicating that the ability is really switched off
-		for {
-			if !a.t.isOn() {
-				break
-			}
-			time.Sleep(50 * time.Millisecond)
-		}
+	// Close abilities in reverse dependency order
+	as, err := b.abilities.ordered()
+	if err != nil {
+		err = errors.Wrap(err, "astibrain: ordering abilities failed")
+		return
+	}
+	for idx := len(as) - 1; idx >= 0; idx-- {
+		b.closeAbility(as[idx])
+	}
 
-		// Close
-		if v, ok := a.r.(io.Closer); ok {
-			if err := v.Close(); err != nil {
-				astilog.Error(errors.Wrapf(err, "astibrain: closing ability %s failed", a.name))
-			}
+	// Close local server
+	if b.s != nil {
+		if err := b.s.Close(); err != nil {
+			astilog.Error(errors.Wrap(err, "astibrain: closing local server failed"))
 		}
-		return nil
-	})
+	}
 
 	// Close ws
 	astilog.Debug("astibrain: closing websocket")
@@ -73,9 +80,84 @@ func (b *Brain) Close() (err error) {
 	return
 }
 
+// closeAbility switches an ability off and closes it
+func (b *Brain) closeAbility(a *ability) {
+	// Log
+	astilog.Debugf("astibrain: closing ability %s", a.name)
+
+	// Switch the ability off
+	a.off()
+
+	// Wait for the ability to be really switched off
+	ctx, cancel := context.WithTimeout(context.Background(), abilityStopTimeout)
+	defer cancel()
+	if err := a.sm.wait(ctx, AbilityStateCrashed, AbilityStateStopped); err != nil {
+		astilog.Error(errors.Wrapf(err, "astibrain: waiting for ability %s to stop failed", a.name))
+	}
+
+	// Close
+	if v, ok := a.r.(io.Closer); ok {
+		if err := v.Close(); err != nil {
+			astilog.Error(errors.Wrapf(err, "astibrain: closing ability %s failed", a.name))
+		}
+	}
+}
+
 // Learn allows the brain to learn a new ability.
-func (b *Brain) Learn(name string, r Runner, o AbilityOptions) {
-	b.abilities.set(newAbility(name, r, b.ws, o))
+// An error is returned if its dependencies create a cycle.
+func (b *Brain) Learn(name string, r Runner, o AbilityOptions) (err error) {
+	if err = b.abilities.set(newAbility(name, r, b.abilities, b.ws, o)); err != nil {
+		err = errors.Wrapf(err, "astibrain: learning %s failed", name)
+		return
+	}
+	return
+}
+
+// AbilityState returns the current state of an ability
+func (b *Brain) AbilityState(name string) (s AbilityState, err error) {
+	// Retrieve ability
+	a, ok := b.abilities.ability(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+	return a.sm.state(), nil
+}
+
+// AbilityHealth returns the current health status of an ability
+func (b *Brain) AbilityHealth(name string) (s HealthStatus, err error) {
+	// Retrieve ability
+	a, ok := b.abilities.ability(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+	return a.healthStatus(), nil
+}
+
+// ConnectionStatus returns the status of the connection to Bob
+func (b *Brain) ConnectionStatus() ConnectionStatus {
+	return b.ws.status()
+}
+
+// WaitForAbilityState waits for an ability to reach one of the provided states.
+// An error is returned if the timeout is reached first.
+func (b *Brain) WaitForAbilityState(name string, timeout time.Duration, states ...AbilityState) (err error) {
+	// Retrieve ability
+	a, ok := b.abilities.ability(name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", name)
+		return
+	}
+
+	// Wait
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err = a.sm.wait(ctx, states...); err != nil {
+		err = errors.Wrapf(err, "astibrain: waiting for ability %s failed", name)
+		return
+	}
+	return
 }
 
 // Run runs the brain
@@ -87,29 +169,54 @@ func (b *Brain) Run(ctx context.Context) (err error) {
 	// Dial
 	go b.ws.dial(b.ctx, b.o.Name)
 
-	// Loop through abilities
-	if err = b.abilities.abilities(func(a *ability) (err error) {
-		// Initialize
+	// Send stats
+	go b.sendStats(b.ctx)
+
+	// Order abilities
+	var as []*ability
+	if as, err = b.abilities.ordered(); err != nil {
+		err = errors.Wrap(err, "astibrain: ordering abilities failed")
+		return
+	}
+
+	// Initialize abilities
+	for _, a := range as {
 		if v, ok := a.r.(Initializer); ok {
 			astilog.Debugf("astibrain: initializing %s", a.name)
 			if err = v.Init(); err != nil {
 				err = errors.Wrapf(err, "astibrain: initializing %s failed", a.name)
 				return
 			}
-
 		}
+	}
 
-		// Auto start
-		if a.o.AutoStart {
-			a.on()
+	// Auto start abilities
+	b.m.Lock()
+	for _, a := range as {
+		if a.options().AutoStart {
+			if err := b.abilities.start(a.name); err != nil {
+				astilog.Error(errors.Wrapf(err, "astibrain: auto starting %s failed", a.name))
+			}
 		}
-		return
-	}); err != nil {
-		err = errors.Wrap(err, "astibrain: initializing abilities failed")
-		return
+	}
+	b.started = true
+	b.m.Unlock()
+
+	// Run local server
+	var chanDone = make(chan error, 1)
+	if b.s != nil {
+		go func() {
+			if err := b.s.run(); err != nil {
+				chanDone <- err
+			}
+		}()
 	}
 
-	// Wait for context to be done
-	<-b.ctx.Done()
+	// Wait for context or chanDone to be done
+	select {
+	case <-b.ctx.Done():
+	case err = <-chanDone:
+		err = errors.Wrap(err, "astibrain: running local server failed")
+	}
 	return
 }
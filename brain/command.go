@@ -0,0 +1,51 @@
+package astibrain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/asticode/go-astiws"
+	"github.com/pkg/errors"
+)
+
+// Commander represents an object capable of handling commands sent through Bob
+type Commander interface {
+	HandleCommand(payload json.RawMessage) error
+}
+
+// WebSocketAbilityCommand is a websocket ability command payload
+type WebSocketAbilityCommand struct {
+	Name    string          `json:"name"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// handleAbilityCommand handles the websocket ability.command event
+func (ws *webSocket) handleAbilityCommand(c *astiws.Client, eventName string, payload json.RawMessage) (err error) {
+	// Decode payload
+	var p WebSocketAbilityCommand
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibrain: json unmarshaling ability.command payload %s failed", payload)
+		return
+	}
+
+	// Retrieve ability
+	a, ok := ws.abilities.ability(p.Name)
+	if !ok {
+		err = fmt.Errorf("astibrain: unknown ability %s", p.Name)
+		return
+	}
+
+	// Ability doesn't handle commands
+	cm, ok := a.r.(Commander)
+	if !ok {
+		err = fmt.Errorf("astibrain: ability %s doesn't handle commands", p.Name)
+		return
+	}
+
+	// Handle command
+	if err = cm.HandleCommand(p.Payload); err != nil {
+		err = errors.Wrapf(err, "astibrain: handling command of ability %s failed", p.Name)
+		return
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package astibrain
+
+import (
+	"context"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// HealthChecker represents an object capable of checking its health.
+// A nil error means the object is healthy.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthStatus represents a health status
+type HealthStatus string
+
+// Health statuses
+const (
+	HealthStatusHealthy   HealthStatus = "healthy"
+	HealthStatusUnhealthy HealthStatus = "unhealthy"
+	HealthStatusUnknown   HealthStatus = "unknown"
+)
+
+// Default health check options
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 5 * time.Second
+	defaultUnhealthyThreshold  = 3
+)
+
+// healthCheckOptions returns the health check options with defaults applied
+func (o AbilityOptions) healthCheckOptions() (interval, timeout time.Duration, threshold int) {
+	interval, timeout, threshold = o.HealthCheckInterval, o.HealthCheckTimeout, o.UnhealthyThreshold
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	if threshold <= 0 {
+		threshold = defaultUnhealthyThreshold
+	}
+	return
+}
+
+// healthStatus returns the current health status
+func (a *ability) healthStatus() HealthStatus {
+	a.mh.Lock()
+	defer a.mh.Unlock()
+	return a.health
+}
+
+// setHealth sets the health status and dispatches an event if it has changed
+func (a *ability) setHealth(s HealthStatus, err error) {
+	// Lock
+	a.mh.Lock()
+	defer a.mh.Unlock()
+
+	// Status has not changed
+	if a.health == s {
+		return
+	}
+	a.health = s
+
+	// Log
+	p := WebSocketAbilityHealth{
+		Name:   a.name,
+		Status: s,
+	}
+	if err != nil {
+		p.Error = err.Error()
+		astilog.Warn(errors.Wrapf(err, "astibrain: %s is %s", a.name, s))
+	} else {
+		astilog.Debugf("astibrain: %s is %s", a.name, s)
+	}
+
+	// Dispatch websocket event
+	a.stats.eventEmitted()
+	a.ws.send(WebsocketEventNameAbilityHealth, p)
+}
+
+// checkHealth periodically checks the ability health until the context is done.
+// It returns true if the ability has been unhealthy for more consecutive checks than the threshold and its restart
+// policy requires it to be restarted.
+func (a *ability) checkHealth(ctx context.Context, hc HealthChecker) (restart bool) {
+	// Reset health once done
+	defer a.setHealth(HealthStatusUnknown, nil)
+
+	// Loop
+	interval, timeout, threshold := a.options().healthCheckOptions()
+	var count int
+	var t = time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		// Wait for the next tick
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+
+		// Check health
+		ctxCheck, cancel := context.WithTimeout(ctx, timeout)
+		err := hc.CheckHealth(ctxCheck)
+		cancel()
+
+		// Context is done
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Healthy
+		if err == nil {
+			count = 0
+			a.setHealth(HealthStatusHealthy, nil)
+			continue
+		}
+
+		// Unhealthy
+		count++
+		a.setHealth(HealthStatusUnhealthy, err)
+		if count >= threshold && a.options().RestartPolicy == RestartPolicyOnUnhealthy {
+			astilog.Errorf("astibrain: %s has been unhealthy for %d consecutive checks", a.name, count)
+			return true
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package astibrain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockedHealthChecker is a runner whose health checks return errors in order, the last one being repeated
+type mockedHealthChecker struct {
+	block bool // Checks block until their context is done
+	errs  []error
+	m     sync.Mutex
+}
+
+func (c *mockedHealthChecker) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *mockedHealthChecker) CheckHealth(ctx context.Context) (err error) {
+	if c.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	err = c.errs[0]
+	if len(c.errs) > 1 {
+		c.errs = c.errs[1:]
+	}
+	return
+}
+
+// waitFor waits for a condition to be true
+func waitFor(t *testing.T, fn func() bool) {
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		if fn() {
+			return
+		}
+	}
+	t.Fatal("condition was not met in time")
+}
+
+func TestAbilityHealth(t *testing.T) {
+	var errFailed = errors.New("failed")
+	for _, v := range []struct {
+		c        *mockedHealthChecker
+		health   HealthStatus
+		name     string
+		policy   string
+		restarts bool
+	}{
+		{c: &mockedHealthChecker{errs: []error{nil}}, health: HealthStatusHealthy, name: "healthy", policy: RestartPolicyOnUnhealthy},
+		{c: &mockedHealthChecker{errs: []error{errFailed, nil}}, health: HealthStatusHealthy, name: "recovery", policy: RestartPolicyOnUnhealthy},
+		{c: &mockedHealthChecker{errs: []error{errFailed}}, health: HealthStatusUnhealthy, name: "threshold without restart", policy: RestartPolicyOnFailure},
+		{c: &mockedHealthChecker{errs: []error{errFailed}}, name: "threshold with restart", policy: RestartPolicyOnUnhealthy, restarts: true},
+		{c: &mockedHealthChecker{block: true}, name: "timeout", policy: RestartPolicyOnUnhealthy, restarts: true},
+	} {
+		t.Run(v.name, func(t *testing.T) {
+			// Learn
+			b := New(Options{})
+			assert.NoError(t, b.Learn("a", v.c, AbilityOptions{
+				HealthCheckInterval: 5 * time.Millisecond,
+				HealthCheckTimeout:  5 * time.Millisecond,
+				RestartPolicy:       v.policy,
+				UnhealthyThreshold:  2,
+			}))
+			a, _ := b.abilities.ability("a")
+
+			// Start
+			assert.NoError(t, b.abilities.start("a"))
+			defer b.abilities.stop("a")
+			assert.NoError(t, b.WaitForAbilityState("a", time.Second, AbilityStateRunning))
+
+			// Restart
+			if v.restarts {
+				waitFor(t, func() bool { return a.stats.toStats().Starts > 1 })
+				assert.Equal(t, 1, a.stats.toStats().Crashes)
+				return
+			}
+
+			// Health
+			waitFor(t, func() bool { return a.healthStatus() == v.health })
+			time.Sleep(30 * time.Millisecond)
+			assert.Equal(t, v.health, a.healthStatus())
+			assert.Equal(t, 1, a.stats.toStats().Starts)
+			assert.Equal(t, AbilityStateRunning, a.sm.state())
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package astibrain
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// defaultQueueSize is the default max number of events the queue can hold
+const defaultQueueSize = 1000
+
+// QueueOptions are outbound queue options
+type QueueOptions struct {
+	Path string `toml:"path"` // If set, the queue is persisted on disk so that events survive a brain restart
+	Size int    `toml:"size"`
+}
+
+// WebSocketEvent wraps events sent to Bob so that they can be replayed and deduplicated
+type WebSocketEvent struct {
+	Name     string          `json:"name"`
+	Payload  json.RawMessage `json:"payload"`
+	Sequence uint64          `json:"sequence"`
+	Session  string          `json:"session"`
+	Time     time.Time       `json:"time"`
+}
+
+// queueHeader is the first line of the queue file
+type queueHeader struct {
+	Sequence uint64 `json:"sequence"`
+	Session  string `json:"session"`
+}
+
+// queueLine is a line of the queue file following the header.
+// It is either an event or an acknowledgement that events up to a sequence have been sent.
+type queueLine struct {
+	WebSocketEvent
+	Acked uint64 `json:"acked,omitempty"`
+}
+
+// queue is a bounded FIFO of outbound events.
+// Lines are appended to the queue file and sent events are acknowledged by appending a line as well, the file being
+// rewritten only once it has grown too big.
+type queue struct {
+	es      []WebSocketEvent
+	lines   int        // Number of lines in the file after the header
+	m       sync.Mutex // Locks es, lines, seq and the file
+	mf      sync.Mutex // Serializes flushes
+	o       QueueOptions
+	seq     uint64
+	session string
+}
+
+// newQueue creates a new queue and loads its content from disk if needed
+func newQueue(o QueueOptions) (q *queue) {
+	// Default size
+	if o.Size <= 0 {
+		o.Size = defaultQueueSize
+	}
+
+	// Create queue
+	q = &queue{
+		o:       o,
+		session: newQueueSession(),
+	}
+
+	// Load
+	if len(q.o.Path) > 0 {
+		if err := q.load(); err != nil {
+			astilog.Error(errors.Wrapf(err, "astibrain: loading queue from %s failed", q.o.Path))
+		}
+	}
+	return
+}
+
+// newQueueSession creates a random session id
+func newQueueSession() string {
+	var b = make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// load loads the queue from disk
+func (q *queue) load() (err error) {
+	// Open file
+	var f *os.File
+	if f, err = os.Open(q.o.Path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	defer f.Close()
+
+	// Read header
+	var s = bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	if !s.Scan() {
+		err = s.Err()
+		return
+	}
+	var h queueHeader
+	if err = json.Unmarshal(s.Bytes(), &h); err != nil {
+		err = errors.Wrap(err, "astibrain: unmarshaling queue header failed")
+		return
+	}
+	q.seq, q.session = h.Sequence, h.Session
+
+	// Read lines
+	for s.Scan() {
+		// Unmarshal
+		var l queueLine
+		if err = json.Unmarshal(s.Bytes(), &l); err != nil {
+			err = errors.Wrap(err, "astibrain: unmarshaling queue line failed")
+			return
+		}
+		q.lines++
+
+		// Acknowledgement
+		if l.Acked > 0 {
+			q.removeUnsafe(l.Acked)
+			continue
+		}
+
+		// Event
+		q.es = append(q.es, l.WebSocketEvent)
+		if l.Sequence > q.seq {
+			q.seq = l.Sequence
+		}
+	}
+	if err = s.Err(); err != nil {
+		err = errors.Wrap(err, "astibrain: scanning queue file failed")
+		return
+	}
+
+	// Events dropped while the queue was full are still in the file
+	if len(q.es) > q.o.Size {
+		q.es = q.es[len(q.es)-q.o.Size:]
+	}
+	astilog.Debugf("astibrain: loaded %d events from queue %s", len(q.es), q.o.Path)
+	return
+}
+
+// push adds an event at the end of the queue.
+// If the queue is full, the oldest event is dropped.
+func (q *queue) push(name string, payload interface{}) (err error) {
+	// Marshal payload
+	var b []byte
+	if b, err = json.Marshal(payload); err != nil {
+		err = errors.Wrapf(err, "astibrain: marshaling payload %#v failed", payload)
+		return
+	}
+
+	// Lock
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	// Create event
+	q.seq++
+	e := WebSocketEvent{
+		Name:     name,
+		Payload:  b,
+		Sequence: q.seq,
+		Session:  q.session,
+		Time:     time.Now(),
+	}
+
+	// Queue is full
+	if len(q.es) >= q.o.Size {
+		astilog.Warnf("astibrain: queue is full, dropping event #%d", q.es[0].Sequence)
+		q.es = q.es[1:]
+	}
+
+	// Append
+	q.es = append(q.es, e)
+	return q.appendUnsafe(queueLine{WebSocketEvent: e})
+}
+
+// flush sends events in order until the queue is empty or sending fails.
+// Events are sent without holding the lock so that pushing is never blocked by the network.
+func (q *queue) flush(fn func(e WebSocketEvent) error) (err error) {
+	// Only one flush at a time
+	q.mf.Lock()
+	defer q.mf.Unlock()
+
+	// Get events
+	q.m.Lock()
+	var es = make([]WebSocketEvent, len(q.es))
+	copy(es, q.es)
+	q.m.Unlock()
+
+	// Nothing to flush
+	if len(es) == 0 {
+		return
+	}
+
+	// Send
+	var acked uint64
+	for _, e := range es {
+		if err = fn(e); err != nil {
+			break
+		}
+		acked = e.Sequence
+	}
+
+	// Nothing has been sent
+	if acked == 0 {
+		return
+	}
+
+	// Acknowledge
+	q.m.Lock()
+	defer q.m.Unlock()
+	q.removeUnsafe(acked)
+	if errAck := q.appendUnsafe(queueLine{Acked: acked}); errAck != nil {
+		astilog.Error(errors.Wrap(errAck, "astibrain: acknowledging events failed"))
+	}
+	return
+}
+
+// removeUnsafe removes events up to a sequence while making the assumption that the mutex is locked
+func (q *queue) removeUnsafe(sequence uint64) {
+	var n int
+	for n < len(q.es) && q.es[n].Sequence <= sequence {
+		n++
+	}
+	q.es = q.es[n:]
+}
+
+// len returns the number of events in the queue
+func (q *queue) len() int {
+	q.m.Lock()
+	defer q.m.Unlock()
+	return len(q.es)
+}
+
+// appendUnsafe appends a line to the queue file while making the assumption that the mutex is locked.
+// The file is rewritten instead if it doesn't exist yet or if it contains too many stale lines.
+func (q *queue) appendUnsafe(l queueLine) (err error) {
+	// Not disk-backed
+	if len(q.o.Path) == 0 {
+		return
+	}
+
+	// Rewrite
+	if q.lines == 0 || q.lines >= 2*q.o.Size {
+		return q.persistUnsafe()
+	}
+
+	// Open file
+	var f *os.File
+	if f, err = os.OpenFile(q.o.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+		err = errors.Wrapf(err, "astibrain: opening %s failed", q.o.Path)
+		return
+	}
+	defer f.Close()
+
+	// Write
+	if err = json.NewEncoder(f).Encode(l); err != nil {
+		err = errors.Wrapf(err, "astibrain: writing line to %s failed", q.o.Path)
+		return
+	}
+	q.lines++
+	return
+}
+
+// persistUnsafe rewrites the whole queue file while making the assumption that the mutex is locked
+func (q *queue) persistUnsafe() (err error) {
+	// Not disk-backed
+	if len(q.o.Path) == 0 {
+		return
+	}
+
+	// Create temporary file
+	var p = q.o.Path + ".tmp"
+	var f *os.File
+	if f, err = os.Create(p); err != nil {
+		err = errors.Wrapf(err, "astibrain: creating %s failed", p)
+		return
+	}
+
+	// Write header and events
+	var en = json.NewEncoder(f)
+	if err = en.Encode(queueHeader{Sequence: q.seq, Session: q.session}); err == nil {
+		for _, e := range q.es {
+			if err = en.Encode(queueLine{WebSocketEvent: e}); err != nil {
+				break
+			}
+		}
+	}
+	f.Close()
+	if err != nil {
+		err = errors.Wrapf(err, "astibrain: writing to %s failed", p)
+		return
+	}
+
+	// Replace file
+	if err = os.Rename(p, q.o.Path); err != nil {
+		err = errors.Wrapf(err, "astibrain: renaming %s to %s failed", p, q.o.Path)
+		return
+	}
+	q.lines = len(q.es)
+	return
+}
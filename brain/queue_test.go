@@ -0,0 +1,83 @@
+package astibrain
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	// Create queue
+	dir, err := ioutil.TempDir("", "astibrain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	q := newQueue(QueueOptions{Path: filepath.Join(dir, "queue"), Size: 3})
+	for _, n := range []string{"1", "2", "3", "4"} {
+		assert.NoError(t, q.push(n, n))
+	}
+	assert.Equal(t, 3, q.len())
+
+	// Partial flush
+	var ns []string
+	assert.Error(t, q.flush(func(e WebSocketEvent) error {
+		if len(ns) == 1 {
+			return errors.New("failed")
+		}
+		ns = append(ns, e.Name)
+		return nil
+	}))
+	assert.Equal(t, []string{"2"}, ns)
+
+	// Reload from disk
+	q2 := newQueue(QueueOptions{Path: filepath.Join(dir, "queue"), Size: 3})
+	assert.Equal(t, q.session, q2.session)
+	var ss []uint64
+	assert.NoError(t, q2.flush(func(e WebSocketEvent) error {
+		ss = append(ss, e.Sequence)
+		return nil
+	}))
+	assert.Equal(t, []uint64{3, 4}, ss)
+	assert.NoError(t, q2.push("5", "5"))
+	assert.Equal(t, uint64(5), q2.seq)
+}
+
+func TestQueueFlushDoesNotBlockPush(t *testing.T) {
+	// Create queue
+	dir, err := ioutil.TempDir("", "astibrain")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "queue")
+	q := newQueue(QueueOptions{Path: p, Size: 2})
+	assert.NoError(t, q.push("1", "1"))
+
+	// Flush blocks while sending
+	sending, release, done := make(chan bool), make(chan bool), make(chan bool)
+	go func() {
+		q.flush(func(e WebSocketEvent) error {
+			close(sending)
+			<-release
+			return nil
+		})
+		close(done)
+	}()
+	<-sending
+
+	// Push while sending
+	assert.NoError(t, q.push("2", "2"))
+	assert.NoError(t, q.push("3", "3"))
+	close(release)
+	<-done
+	assert.Equal(t, 2, q.len())
+
+	// The file is appended to and compacted once it has grown too big
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, q.push("4", "4"))
+		assert.NoError(t, q.flush(func(e WebSocketEvent) error { return nil }))
+		assert.True(t, q.lines <= 4)
+	}
+	assert.Equal(t, 0, newQueue(QueueOptions{Path: p, Size: 2}).len())
+}
@@ -0,0 +1,136 @@
+package astibrain
+
+import (
+	"reflect"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// ReloadReport represents what has been applied live when reloading options and what requires a restart
+type ReloadReport struct {
+	Applied         []string `json:"applied,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// options returns the brain options
+func (b *Brain) options() Options {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.o
+}
+
+// Reload diffs options against the running ones and applies what can be applied live without dropping the
+// connection to Bob.
+// Ability options are indexed by ability name. Abilities whose AutoStart option has been switched on are started.
+// Options that require a restart are reported and left untouched so that they keep being reported until the brain
+// is restarted.
+func (b *Brain) Reload(o Options, as map[string]AbilityOptions) (r ReloadReport) {
+	// Lock
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	// Name
+	if b.o.Name != o.Name {
+		r.RestartRequired = append(r.RestartRequired, "name")
+	}
+
+	// Local server
+	if b.s != nil {
+		var so = b.s.options()
+		if so.Password != o.Server.Password {
+			so.Password = o.Server.Password
+			r.Applied = append(r.Applied, "server.password")
+		}
+		if so.Username != o.Server.Username {
+			so.Username = o.Server.Username
+			r.Applied = append(r.Applied, "server.username")
+		}
+		b.s.setOptions(so)
+		b.o.Server.Password, b.o.Server.Username = so.Password, so.Username
+	}
+	if b.o.Server.Enabled != o.Server.Enabled {
+		r.RestartRequired = append(r.RestartRequired, "server.enabled")
+	}
+	if b.o.Server.ListenAddr != o.Server.ListenAddr {
+		r.RestartRequired = append(r.RestartRequired, "server.listen_addr")
+	}
+
+	// Stats
+	if b.o.StatsInterval != o.StatsInterval {
+		b.o.StatsInterval = o.StatsInterval
+		r.Applied = append(r.Applied, "stats_interval")
+	}
+
+	// Websocket
+	if b.o.WebSocket.URL != o.WebSocket.URL || !reflect.DeepEqual(b.o.WebSocket.FallbackURLs, o.WebSocket.FallbackURLs) {
+		// URLs are taken into account on the next reconnection so that the current connection is not dropped
+		b.o.WebSocket.URL, b.o.WebSocket.FallbackURLs = o.WebSocket.URL, o.WebSocket.FallbackURLs
+		b.ws.setURLs(o.WebSocket.URL, o.WebSocket.FallbackURLs)
+		r.Applied = append(r.Applied, "websocket.url")
+	}
+	if b.o.WebSocket.Backoff != o.WebSocket.Backoff {
+		r.RestartRequired = append(r.RestartRequired, "websocket.backoff")
+	}
+	if b.o.WebSocket.Queue != o.WebSocket.Queue {
+		r.RestartRequired = append(r.RestartRequired, "websocket.queue")
+	}
+
+	// Abilities
+	for name, ao := range as {
+		// Retrieve ability
+		a, ok := b.abilities.ability(name)
+		if !ok {
+			r.RestartRequired = append(r.RestartRequired, "abilities."+name)
+			continue
+		}
+
+		// Apply
+		if r.applyAbility(a, ao) && b.started && !a.sm.state().IsOn() {
+			if err := b.abilities.start(name); err != nil {
+				astilog.Error(errors.Wrapf(err, "astibrain: auto starting %s failed", name))
+			}
+		}
+	}
+
+	// Log
+	astilog.Infof("astibrain: reload applied %v, restart required for %v", r.Applied, r.RestartRequired)
+	return
+}
+
+// applyAbility applies ability options that can be applied live.
+// It returns true if the AutoStart option has been switched on.
+func (r *ReloadReport) applyAbility(a *ability, new AbilityOptions) (autoStart bool) {
+	// Lock
+	a.mo.Lock()
+	defer a.mo.Unlock()
+
+	// Dependencies are checked for cycles when learning abilities
+	var prefix = "abilities." + a.name
+	if !reflect.DeepEqual(a.o.Dependencies, new.Dependencies) {
+		r.RestartRequired = append(r.RestartRequired, prefix+".dependencies")
+	}
+	new.Dependencies = a.o.Dependencies
+
+	// Auto start
+	autoStart = !a.o.AutoStart && new.AutoStart
+
+	// Options read at run time. Health check options are taken into account on the next run.
+	for _, f := range []struct {
+		changed bool
+		name    string
+	}{
+		{changed: a.o.AutoStart != new.AutoStart, name: "auto_start"},
+		{changed: a.o.HealthCheckInterval != new.HealthCheckInterval, name: "health_check_interval"},
+		{changed: a.o.HealthCheckTimeout != new.HealthCheckTimeout, name: "health_check_timeout"},
+		{changed: a.o.RestartDelay != new.RestartDelay, name: "restart_delay"},
+		{changed: a.o.RestartPolicy != new.RestartPolicy, name: "restart_policy"},
+		{changed: a.o.UnhealthyThreshold != new.UnhealthyThreshold, name: "unhealthy_threshold"},
+	} {
+		if f.changed {
+			r.Applied = append(r.Applied, prefix+"."+f.name)
+		}
+	}
+	a.o = new
+	return
+}
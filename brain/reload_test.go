@@ -0,0 +1,18 @@
+package astibrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadReport_ApplyAbility(t *testing.T) {
+	a := &ability{name: "a", o: AbilityOptions{Dependencies: []string{"b"}, RestartPolicy: RestartPolicyNever}}
+	var r ReloadReport
+	assert.True(t, r.applyAbility(a, AbilityOptions{AutoStart: true, RestartDelay: time.Second, RestartPolicy: RestartPolicyNever}))
+	assert.Equal(t, []string{"abilities.a.auto_start", "abilities.a.restart_delay"}, r.Applied)
+	assert.Equal(t, []string{"abilities.a.dependencies"}, r.RestartRequired)
+	assert.Equal(t, AbilityOptions{AutoStart: true, Dependencies: []string{"b"}, RestartDelay: time.Second, RestartPolicy: RestartPolicyNever}, a.options())
+	assert.False(t, r.applyAbility(a, a.options()))
+}
@@ -0,0 +1,261 @@
+package astibrain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/http"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+// defaultServerListenAddr is the default local server listen addr
+const defaultServerListenAddr = "127.0.0.1:6971"
+
+// server represents a local server allowing to control the brain when Bob is unreachable
+type server struct {
+	abilities *abilities
+	m         sync.Mutex // Locks o
+	name      string
+	o         ServerOptions
+	s         *http.Server
+	ws        *webSocket
+}
+
+// ServerOptions are local server options
+type ServerOptions struct {
+	Enabled    bool   `toml:"enabled"`
+	ListenAddr string `toml:"listen_addr"`
+	Password   string `toml:"password"`
+	Username   string `toml:"username"`
+}
+
+// newServer creates a new local server
+func newServer(name string, abilities *abilities, ws *webSocket, o ServerOptions) (s *server) {
+	// Default listen addr
+	if len(o.ListenAddr) == 0 {
+		o.ListenAddr = defaultServerListenAddr
+	}
+
+	// Create server
+	s = &server{
+		abilities: abilities,
+		name:      name,
+		o:         o,
+		ws:        ws,
+	}
+
+	// Init router
+	var r = httprouter.New()
+
+	// Web
+	r.GET("/", s.handleStatusGET)
+
+	// API
+	r.GET("/api/status", astihttp.ChainRouterMiddlewares(s.handleAPIStatusGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/api/abilities", astihttp.ChainRouterMiddlewares(s.handleAPIAbilitiesGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/api/abilities/:name", astihttp.ChainRouterMiddlewares(s.handleAPIAbilityGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.POST("/api/abilities/:name/start", astihttp.ChainRouterMiddlewares(s.handleAPIAbilityStartPOST, astihttp.RouterMiddlewareContentType("application/json")))
+	r.POST("/api/abilities/:name/stop", astihttp.ChainRouterMiddlewares(s.handleAPIAbilityStopPOST, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/api/stats", astihttp.ChainRouterMiddlewares(s.handleAPIStatsGET, astihttp.RouterMiddlewareContentType("application/json")))
+	for _, m := range []string{http.MethodDelete, http.MethodGet, http.MethodPatch, http.MethodPost, http.MethodPut} {
+		r.Handle(m, "/api/abilities/:name/api/*path", s.handleAPIAbilityAPI)
+	}
+
+	// Chain middlewares
+	var h = astihttp.ChainMiddlewares(r, s.middlewareBasicAuth)
+
+	// Set http server
+	s.s = &http.Server{Addr: o.ListenAddr, Handler: h}
+	return
+}
+
+// options returns the local server options
+func (s *server) options() ServerOptions {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.o
+}
+
+// setOptions sets the local server options.
+// Only credentials are read at request time: the listen addr needs a restart.
+func (s *server) setOptions(o ServerOptions) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.o = o
+}
+
+// middlewareBasicAuth adds basic auth based on the current options so that credentials can be reloaded
+func (s *server) middlewareBasicAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		o := s.options()
+		astihttp.MiddlewareBasicAuth(o.Username, o.Password)(h).ServeHTTP(rw, r)
+	})
+}
+
+// Close implements the io.Closer interface
+func (s *server) Close() (err error) {
+	astilog.Debug("astibrain: shutting down local server")
+	if err = s.s.Shutdown(context.Background()); err != nil {
+		err = errors.Wrap(err, "astibrain: shutting down local server failed")
+		return
+	}
+	return
+}
+
+// run runs the server
+func (s *server) run() (err error) {
+	astilog.Infof("astibrain: running local server on %s", s.s.Addr)
+	if err = s.s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		err = errors.Wrapf(err, "astibrain: running local server on %s failed", s.s.Addr)
+		return
+	}
+	return nil
+}
+
+// APIError represents an API error.
+// It is the error format of every API: Bob's, brains' and abilities'.
+type APIError struct {
+	Message string `json:"message"`
+}
+
+// APIWriteError writes an API error
+func APIWriteError(rw http.ResponseWriter, code int, err error) {
+	rw.WriteHeader(code)
+	astilog.Error(err)
+	if err := json.NewEncoder(rw).Encode(APIError{Message: err.Error()}); err != nil {
+		astilog.Error(errors.Wrap(err, "astibrain: json encoding failed"))
+	}
+}
+
+// APIWrite writes API data
+func APIWrite(rw http.ResponseWriter, data interface{}) {
+	if err := json.NewEncoder(rw).Encode(data); err != nil {
+		APIWriteError(rw, http.StatusInternalServerError, errors.Wrap(err, "astibrain: json encoding failed"))
+		return
+	}
+}
+
+// APIStatus represents the brain status
+type APIStatus struct {
+	Abilities  map[string]WebSocketAbility `json:"abilities"`
+	Connection ConnectionStatus            `json:"connection"`
+	Name       string                      `json:"name"`
+}
+
+// handleAPIStatusGET returns the brain status.
+func (s *server) handleAPIStatusGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	APIWrite(rw, APIStatus{
+		Abilities:  s.abilities.toWebSocket(),
+		Connection: s.ws.status(),
+		Name:       s.name,
+	})
+}
+
+// handleAPIStatsGET returns the brain stats.
+func (s *server) handleAPIStatsGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	APIWrite(rw, s.abilities.stats())
+}
+
+// handleAPIAbilitiesGET returns the abilities.
+func (s *server) handleAPIAbilitiesGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	APIWrite(rw, s.abilities.toWebSocket())
+}
+
+// handleAPIAbilityGET returns an ability.
+func (s *server) handleAPIAbilityGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve ability
+	a, ok := s.abilities.ability(p.ByName("name"))
+	if !ok {
+		APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astibrain: unknown ability %s", p.ByName("name")))
+		return
+	}
+
+	// Write
+	APIWrite(rw, a.toWebSocket())
+}
+
+// handleAPIAbilityStartPOST starts an ability.
+func (s *server) handleAPIAbilityStartPOST(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if err := s.abilities.start(p.ByName("name")); err != nil {
+		APIWriteError(rw, http.StatusBadRequest, errors.Wrapf(err, "astibrain: starting ability %s failed", p.ByName("name")))
+		return
+	}
+	s.handleAPIAbilityGET(rw, r, p)
+}
+
+// handleAPIAbilityStopPOST stops an ability.
+func (s *server) handleAPIAbilityStopPOST(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if err := s.abilities.stop(p.ByName("name")); err != nil {
+		APIWriteError(rw, http.StatusBadRequest, errors.Wrapf(err, "astibrain: stopping ability %s failed", p.ByName("name")))
+		return
+	}
+	s.handleAPIAbilityGET(rw, r, p)
+}
+
+// statusTemplate is the status page template
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>{{ .Name }}</title>
+    <style>
+        body { font-family: sans-serif; margin: 30px; }
+        td, th { padding: 5px 15px; text-align: left; }
+    </style>
+</head>
+<body>
+    <h1>{{ .Name }}</h1>
+    <p>Bob: {{ .Connection.State }}{{ if .Connection.URL }} ({{ .Connection.URL }}){{ end }} since {{ .Connection.Since.Format "2006-01-02 15:04:05" }}</p>
+    <table>
+        <tr><th>Ability</th><th>State</th><th>Health</th><th></th></tr>
+        {{ range .Abilities }}
+        <tr>
+            <td>{{ .Name }}</td>
+            <td>{{ .State }}</td>
+            <td>{{ .Health }}</td>
+            <td><button onclick="send('{{ .Name }}', '{{ if .IsOn }}stop{{ else }}start{{ end }}')">{{ if .IsOn }}Stop{{ else }}Start{{ end }}</button></td>
+        </tr>
+        {{ end }}
+    </table>
+    <script>
+        function send(name, action) {
+            fetch("/api/abilities/" + encodeURIComponent(name) + "/" + action, {credentials: "same-origin", method: "POST"}).then(function(r) {
+                if (!r.ok) { r.json().then(function(d) { alert(d.message); }); return; }
+                window.location.reload();
+            });
+        }
+    </script>
+</body>
+</html>`))
+
+// handleStatusGET handles the status page.
+func (s *server) handleStatusGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Get abilities
+	var as []WebSocketAbility
+	for _, a := range s.abilities.toWebSocket() {
+		as = append(as, a)
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].Name < as[j].Name })
+
+	// Execute template
+	rw.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	if err := statusTemplate.Execute(rw, struct {
+		Abilities  []WebSocketAbility
+		Connection ConnectionStatus
+		Name       string
+	}{
+		Abilities:  as,
+		Connection: s.ws.status(),
+		Name:       s.name,
+	}); err != nil {
+		astilog.Error(errors.Wrap(err, "astibrain: executing status template failed"))
+		return
+	}
+}
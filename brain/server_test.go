@@ -0,0 +1,69 @@
+package astibrain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	// Create server
+	b := New(Options{})
+	assert.NoError(t, b.Learn("a", mockedRunner{}, AbilityOptions{}))
+	assert.NoError(t, b.Learn("b", mockedRunner{}, AbilityOptions{Dependencies: []string{"a"}}))
+	s := newServer("brain", b.abilities, b.ws, ServerOptions{})
+	assert.Equal(t, defaultServerListenAddr, s.s.Addr)
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.s.Handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	// Status
+	rec := serve(http.MethodGet, "/api/status")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var st APIStatus
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&st))
+	assert.Equal(t, "brain", st.Name)
+	assert.Equal(t, []string{"a", "b"}, sortedKeys(st.Abilities))
+
+	// Status page
+	rec = serve(http.MethodGet, "/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "<h1>brain</h1>"))
+
+	// Unknown ability
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api/abilities/c").Code)
+
+	// State changing actions are not GETs
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "/api/abilities/a/start").Code)
+
+	// Dependency is off
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/abilities/b/start").Code)
+
+	// Start
+	rec = serve(http.MethodPost, "/api/abilities/a/start")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var a WebSocketAbility
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&a))
+	assert.True(t, a.IsOn)
+	assert.NoError(t, b.WaitForAbilityState("a", time.Second, AbilityStateRunning))
+
+	// Stop
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/api/abilities/a/stop").Code)
+	assert.NoError(t, b.WaitForAbilityState("a", time.Second, AbilityStateStopped))
+}
+
+func sortedKeys(m map[string]WebSocketAbility) (o []string) {
+	for k := range m {
+		o = append(o, k)
+	}
+	sort.Strings(o)
+	return
+}
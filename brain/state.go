@@ -0,0 +1,127 @@
+package astibrain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AbilityState represents an ability state
+type AbilityState string
+
+// Ability states
+const (
+	AbilityStateCrashed    AbilityState = "crashed"
+	AbilityStateRestarting AbilityState = "restarting"
+	AbilityStateRunning    AbilityState = "running"
+	AbilityStateStarting   AbilityState = "starting"
+	AbilityStateStopped    AbilityState = "stopped"
+	AbilityStateStopping   AbilityState = "stopping"
+)
+
+// IsOn returns whether the state is considered as on
+func (s AbilityState) IsOn() bool {
+	return s == AbilityStateRestarting || s == AbilityStateRunning || s == AbilityStateStarting
+}
+
+// abilityTransitions represents the allowed transitions indexed by source state
+var abilityTransitions = map[AbilityState][]AbilityState{
+	AbilityStateCrashed:    {AbilityStateRestarting, AbilityStateStarting},
+	AbilityStateRestarting: {AbilityStateStarting, AbilityStateStopping},
+	AbilityStateRunning:    {AbilityStateCrashed, AbilityStateStopped, AbilityStateStopping},
+	AbilityStateStarting:   {AbilityStateRunning, AbilityStateStopping},
+	AbilityStateStopped:    {AbilityStateStarting},
+	AbilityStateStopping:   {AbilityStateStopped},
+}
+
+// stateMachine represents an ability state machine
+type stateMachine struct {
+	c  chan struct{} // Closed and renewed on each transition
+	fn stateMachineTransitionFunc
+	m  sync.Mutex // Locks c and s
+	mt sync.Mutex // Makes sure transition funcs are executed in order
+	s  AbilityState
+}
+
+// stateMachineTransitionFunc represents a function executed on each transition
+type stateMachineTransitionFunc func(from, to AbilityState)
+
+// newStateMachine creates a new state machine
+func newStateMachine(fn stateMachineTransitionFunc) *stateMachine {
+	return &stateMachine{
+		c:  make(chan struct{}),
+		fn: fn,
+		s:  AbilityStateStopped,
+	}
+}
+
+// state returns the current state
+func (sm *stateMachine) state() AbilityState {
+	sm.m.Lock()
+	defer sm.m.Unlock()
+	return sm.s
+}
+
+// transition transitions to a new state.
+// An error is returned if the transition is not allowed.
+func (sm *stateMachine) transition(to AbilityState) (err error) {
+	// Lock
+	sm.m.Lock()
+
+	// Check whether the transition is allowed
+	var from = sm.s
+	if !isAbilityTransitionAllowed(from, to) {
+		sm.m.Unlock()
+		err = fmt.Errorf("astibrain: transition from %s to %s is not allowed", from, to)
+		return
+	}
+
+	// Update state and wake up waiters
+	sm.s = to
+	close(sm.c)
+	sm.c = make(chan struct{})
+
+	// Execute the transition func while preserving transitions order
+	sm.mt.Lock()
+	sm.m.Unlock()
+	defer sm.mt.Unlock()
+	if sm.fn != nil {
+		sm.fn(from, to)
+	}
+	return
+}
+
+// isAbilityTransitionAllowed checks whether a transition is allowed
+func isAbilityTransitionAllowed(from, to AbilityState) bool {
+	for _, s := range abilityTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// wait waits for the state machine to reach one of the provided states or for the context to be done
+func (sm *stateMachine) wait(ctx context.Context, states ...AbilityState) (err error) {
+	for {
+		// Check state
+		sm.m.Lock()
+		var s, c = sm.s, sm.c
+		sm.m.Unlock()
+		for _, v := range states {
+			if s == v {
+				return
+			}
+		}
+
+		// Wait for the next transition
+		select {
+		case <-c:
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "astibrain: waiting for states %v failed with current state %s", states, s)
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package astibrain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateMachine(t *testing.T) {
+	var ts [][2]AbilityState
+	sm := newStateMachine(func(from, to AbilityState) { ts = append(ts, [2]AbilityState{from, to}) })
+	assert.Equal(t, AbilityStateStopped, sm.state())
+	assert.Error(t, sm.transition(AbilityStateRunning))
+	assert.NoError(t, sm.transition(AbilityStateStarting))
+	assert.NoError(t, sm.transition(AbilityStateRunning))
+	assert.NoError(t, sm.transition(AbilityStateCrashed))
+	assert.Equal(t, [][2]AbilityState{
+		{AbilityStateStopped, AbilityStateStarting},
+		{AbilityStateStarting, AbilityStateRunning},
+		{AbilityStateRunning, AbilityStateCrashed},
+	}, ts)
+
+	// Wait
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Error(t, sm.wait(ctx, AbilityStateRunning))
+	go sm.transition(AbilityStateStarting)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, sm.wait(ctx, AbilityStateStarting))
+}
@@ -0,0 +1,164 @@
+package astibrain
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultStatsInterval is the default interval at which stats are sent to Bob
+const defaultStatsInterval = 5 * time.Second
+
+// AbilityStats represents an ability's stats
+type AbilityStats struct {
+	Crashes     int           `json:"crashes"`
+	Events      uint64        `json:"events"`       // Number of websocket events emitted on behalf of the ability
+	RunDuration time.Duration `json:"run_duration"` // Total time spent in Run
+	Starts      int           `json:"starts"`
+	Uptime      time.Duration `json:"uptime"` // Time spent in the current Run
+}
+
+// RuntimeStats represents the brain's Go runtime stats
+type RuntimeStats struct {
+	Goroutines  int           `json:"goroutines"`
+	GoVersion   string        `json:"go_version"`
+	HeapAlloc   uint64        `json:"heap_alloc"`
+	HeapInuse   uint64        `json:"heap_inuse"`
+	HeapObjects uint64        `json:"heap_objects"`
+	NumCPU      int           `json:"num_cpu"`
+	NumGC       uint32        `json:"num_gc"`
+	PauseTotal  time.Duration `json:"pause_total"`
+	Sys         uint64        `json:"sys"`
+	TotalAlloc  uint64        `json:"total_alloc"`
+	Uptime      time.Duration `json:"uptime"`
+}
+
+// Stats represents the brain's stats
+type Stats struct {
+	Abilities map[string]AbilityStats `json:"abilities"` // Indexed by ability name
+	Runtime   RuntimeStats            `json:"runtime"`
+}
+
+// abilityStats collects an ability's stats
+type abilityStats struct {
+	crashes      int
+	events       uint64     // Must be accessed atomically
+	m            sync.Mutex // Locks all attributes except events
+	runDuration  time.Duration
+	runStartedAt time.Time // Zero if the ability is not in Run
+	starts       int
+}
+
+// runStarted is executed when Run is called
+func (s *abilityStats) runStarted() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.starts++
+	s.runStartedAt = time.Now()
+}
+
+// runEnded is executed when Run returns
+func (s *abilityStats) runEnded() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.runDuration += time.Since(s.runStartedAt)
+	s.runStartedAt = time.Time{}
+}
+
+// crashed is executed when the ability crashes
+func (s *abilityStats) crashed() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.crashes++
+}
+
+// eventEmitted is executed each time an event is emitted on behalf of the ability
+func (s *abilityStats) eventEmitted() {
+	atomic.AddUint64(&s.events, 1)
+}
+
+// toStats returns the exported representation of the stats
+func (s *abilityStats) toStats() (o AbilityStats) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	o = AbilityStats{
+		Crashes:     s.crashes,
+		Events:      atomic.LoadUint64(&s.events),
+		RunDuration: s.runDuration,
+		Starts:      s.starts,
+	}
+	if !s.runStartedAt.IsZero() {
+		o.Uptime = time.Since(s.runStartedAt)
+		o.RunDuration += o.Uptime
+	}
+	return
+}
+
+// startedAt is the time the brain has been started at
+var startedAt = time.Now()
+
+// runtimeStats returns the Go runtime stats
+func runtimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return RuntimeStats{
+		Goroutines:  runtime.NumGoroutine(),
+		GoVersion:   runtime.Version(),
+		HeapAlloc:   m.HeapAlloc,
+		HeapInuse:   m.HeapInuse,
+		HeapObjects: m.HeapObjects,
+		NumCPU:      runtime.NumCPU(),
+		NumGC:       m.NumGC,
+		PauseTotal:  time.Duration(m.PauseTotalNs),
+		Sys:         m.Sys,
+		TotalAlloc:  m.TotalAlloc,
+		Uptime:      time.Since(startedAt),
+	}
+}
+
+// stats returns the stats of the pool of abilities as well as the Go runtime stats
+func (as *abilities) stats() (s Stats) {
+	s = Stats{
+		Abilities: make(map[string]AbilityStats),
+		Runtime:   runtimeStats(),
+	}
+	as.abilities(func(a *ability) error {
+		s.Abilities[a.name] = a.stats.toStats()
+		return nil
+	})
+	return
+}
+
+// eventEmitted increments the number of events emitted on behalf of an ability if it exists
+func (as *abilities) eventEmitted(name string) {
+	if a, ok := as.ability(name); ok {
+		a.stats.eventEmitted()
+	}
+}
+
+// Stats returns the brain's stats
+func (b *Brain) Stats() Stats {
+	return b.abilities.stats()
+}
+
+// sendStats sends stats to Bob periodically until the context is done
+func (b *Brain) sendStats(ctx context.Context) {
+	for {
+		// Get interval
+		// It is read at each iteration so that it can be reloaded
+		var i = b.options().StatsInterval
+		if i <= 0 {
+			i = defaultStatsInterval
+		}
+
+		// Wait
+		select {
+		case <-time.After(i):
+			b.ws.sendLive(WebsocketEventNameStats, b.Stats())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
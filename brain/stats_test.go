@@ -0,0 +1,27 @@
+package astibrain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbilityStats(t *testing.T) {
+	s := &abilityStats{}
+	s.runStarted()
+	time.Sleep(time.Millisecond)
+	o := s.toStats()
+	assert.Equal(t, 1, o.Starts)
+	assert.True(t, o.Uptime > 0)
+	assert.Equal(t, o.Uptime, o.RunDuration)
+	s.runEnded()
+	s.crashed()
+	s.eventEmitted()
+	s.eventEmitted()
+	o = s.toStats()
+	assert.Equal(t, 1, o.Crashes)
+	assert.Equal(t, uint64(2), o.Events)
+	assert.Equal(t, time.Duration(0), o.Uptime)
+	assert.True(t, o.RunDuration > 0)
+}
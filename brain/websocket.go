@@ -2,10 +2,9 @@ package astibrain
 
 import (
 	"context"
-	"time"
-
 	"encoding/json"
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/asticode/go-astilog"
 	"github.com/asticode/go-astiws"
@@ -14,24 +13,64 @@ import (
 
 // Websocket event names
 const (
-	WebsocketEventNameAbilityCrashed = "ability.crashed"
-	WebsocketEventNameAbilityStart   = "ability.start"
-	WebsocketEventNameAbilityStarted = "ability.started"
-	WebsocketEventNameAbilityStop    = "ability.stop"
-	WebsocketEventNameAbilityStopped = "ability.stopped"
-	WebsocketEventNameRegister       = "register"
+	WebsocketEventNameAbilityAPIRequest   = "ability.api.request"
+	WebsocketEventNameAbilityAPIResponse  = "ability.api.response"
+	WebsocketEventNameAbilityCommand      = "ability.command"
+	WebsocketEventNameAbilityCrashed      = "ability.crashed"
+	WebsocketEventNameAbilityHealth       = "ability.health"
+	WebsocketEventNameAbilityStart        = "ability.start"
+	WebsocketEventNameAbilityStop         = "ability.stop"
+	WebsocketEventNameAbilityTransitioned = "ability.transitioned"
+	WebsocketEventNameAudioOutput         = "audio.output"
+	WebsocketEventNameAudioStart          = "audio.start"
+	WebsocketEventNameAudioStop           = "audio.stop"
+	WebsocketEventNameRegister            = "register"
+	WebsocketEventNameStats               = "stats"
+)
+
+// webSocketMaxMessageSize is the websocket max message size, it needs to be big enough for audio outputs.
+// Audio frames are exchanged through a dedicated websocket.
+const webSocketMaxMessageSize = 1 << 20
+
+// ConnectionState represents a websocket connection state
+type ConnectionState string
+
+// Connection states
+const (
+	ConnectionStateBackoff      ConnectionState = "backoff"
+	ConnectionStateConnected    ConnectionState = "connected"
+	ConnectionStateConnecting   ConnectionState = "connecting"
+	ConnectionStateDisconnected ConnectionState = "disconnected"
 )
 
+// ConnectionStatus represents a websocket connection status
+type ConnectionStatus struct {
+	Since time.Time       `json:"since"`
+	State ConnectionState `json:"state"`
+	URL   string          `json:"url"`
+}
+
 // webSocket represents a websocket wrapper
 type webSocket struct {
 	abilities *abilities
+	as        *AudioWebSocket
+	audio     map[string]*AudioStream
 	c         *astiws.Client
+	flushes   chan struct{}
+	inputs    map[string]*AudioInput
+	m         sync.Mutex // Locks as, audio, inputs, o, outputs and s
 	o         WebSocketOptions
+	outputs   map[string]*AudioOutput
+	q         *queue
+	s         ConnectionStatus
 }
 
 // WebSocketOptions are websocket options
 type WebSocketOptions struct {
-	URL string `toml:"url"`
+	Backoff      BackoffOptions `toml:"backoff"`
+	FallbackURLs []string       `toml:"fallback_urls"` // URLs to rotate through when URL is unreachable
+	Queue        QueueOptions   `toml:"queue"`
+	URL          string         `toml:"url"`
 }
 
 // newWebSocket creates a new websocket wrapper
@@ -39,11 +78,26 @@ func newWebSocket(abilities *abilities, o WebSocketOptions) (ws *webSocket) {
 	// Create websocket
 	ws = &webSocket{
 		abilities: abilities,
-		c:         astiws.NewClient(4096),
+		audio:     make(map[string]*AudioStream),
+		c:         astiws.NewClient(webSocketMaxMessageSize),
+		flushes:   make(chan struct{}, 1),
+		inputs:    make(map[string]*AudioInput),
+		outputs:   make(map[string]*AudioOutput),
 		o:         o,
+		q:         newQueue(o.Queue),
+		s: ConnectionStatus{
+			Since: time.Now(),
+			State: ConnectionStateDisconnected,
+		},
 	}
 
-	// TODO Add listeners
+	// Add listeners
+	ws.c.AddListener(WebsocketEventNameAbilityAPIRequest, ws.handleAbilityAPIRequest)
+	ws.c.AddListener(WebsocketEventNameAbilityCommand, ws.handleAbilityCommand)
+	ws.c.AddListener(WebsocketEventNameAbilityStart, ws.handleAbilityStart)
+	ws.c.AddListener(WebsocketEventNameAbilityStop, ws.handleAbilityStop)
+	ws.c.AddListener(WebsocketEventNameAudioStart, ws.handleAudioStart)
+	ws.c.AddListener(WebsocketEventNameAudioStop, ws.handleAudioStop)
 	return
 }
 
@@ -58,10 +112,32 @@ func (ws *webSocket) Close() (err error) {
 	return
 }
 
+// urls returns the URLs to rotate through
+func (ws *webSocket) urls() []string {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	return append([]string{ws.o.URL}, ws.o.FallbackURLs...)
+}
+
+// setURLs sets the URLs to rotate through, they are taken into account on the next reconnection
+func (ws *webSocket) setURLs(url string, fallbackURLs []string) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	ws.o.URL = url
+	ws.o.FallbackURLs = fallbackURLs
+}
+
 // dial dials the websocket
 func (ws *webSocket) dial(ctx context.Context, name string) {
+	// Make sure the status is updated once done
+	defer ws.setStatus(ConnectionStateDisconnected, "")
+
+	// Send queued events in the background so that senders never wait on the network
+	go ws.sendQueued(ctx)
+
 	// Infinite loop to handle reconnect
-	const sleepError = 5 * time.Second
+	var b = newBackoff(ws.o.Backoff)
+	var idx int
 	for {
 		// Check context error
 		if ctx.Err() != nil {
@@ -69,57 +145,141 @@ func (ws *webSocket) dial(ctx context.Context, name string) {
 		}
 
 		// Dial
-		if err := ws.c.Dial(ws.o.URL); err != nil {
-			astilog.Error(errors.Wrap(err, "astibrain: dialing websocket failed"))
-			time.Sleep(sleepError)
+		var urls = ws.urls()
+		var url = urls[idx%len(urls)]
+		ws.setStatus(ConnectionStateConnecting, url)
+		if err := ws.c.Dial(url); err != nil {
+			astilog.Error(errors.Wrapf(err, "astibrain: dialing websocket %s failed", url))
+			idx++
+			if !ws.wait(ctx, b, url) {
+				return
+			}
 			continue
 		}
 
 		// Register
 		if err := ws.sendRegister(name); err != nil {
 			astilog.Error(errors.Wrap(err, "astibrain: sending register websocket event failed"))
-			time.Sleep(sleepError)
+			if !ws.wait(ctx, b, url) {
+				return
+			}
 			continue
 		}
 
+		// Replay events queued while disconnected
+		b.reset()
+		ws.setStatus(ConnectionStateConnected, url)
+		if n := ws.q.len(); n > 0 {
+			astilog.Infof("astibrain: replaying %d queued websocket events", n)
+		}
+		ws.requestFlush()
+
+		// Dial the audio websocket for as long as the websocket is connected
+		audioCtx, audioCancel := context.WithCancel(ctx)
+		go ws.dialAudio(audioCtx, url, name)
+
 		// Read
 		if err := ws.c.Read(); err != nil {
 			astilog.Error(errors.Wrap(err, "astibrain: reading websocket failed"))
-			time.Sleep(sleepError)
-			continue
 		}
+		audioCancel()
+
+		// Switch audio streams off until Bob subscribes again
+		ws.switchAudioStreamsOff()
+		if !ws.wait(ctx, b, url) {
+			return
+		}
+	}
+}
+
+// wait waits for the next backoff interval.
+// It returns false if the context is done first.
+func (ws *webSocket) wait(ctx context.Context, b *backoff, url string) bool {
+	// Update status
+	ws.setStatus(ConnectionStateBackoff, url)
+
+	// Wait
+	d := b.next()
+	astilog.Debugf("astibrain: waiting %s before reconnecting", d)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
+// setStatus sets the connection status
+func (ws *webSocket) setStatus(s ConnectionState, url string) {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	if ws.s.State == s && ws.s.URL == url {
+		return
+	}
+	ws.s = ConnectionStatus{
+		Since: time.Now(),
+		State: s,
+		URL:   url,
+	}
+}
+
+// status returns the connection status
+func (ws *webSocket) status() ConnectionStatus {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+	return ws.s
+}
+
 // WebSocketRegister is a websocket register payload
 type WebSocketRegister struct {
-	Abilities map[string]WebSocketAbility `json:"abilities"`
-	Name      string                      `json:"name"`
+	Abilities    map[string]WebSocketAbility `json:"abilities"`
+	AudioInputs  map[string]AudioFormat      `json:"audio_inputs,omitempty"`
+	AudioOutputs []string                    `json:"audio_outputs,omitempty"`
+	Name         string                      `json:"name"`
 }
 
 // WebSocketAbility is a websocket ability
 type WebSocketAbility struct {
-	IsOn bool   `json:"is_on"`
-	Name string `json:"name"`
+	API         bool         `json:"api,omitempty"`          // Set if the ability exposes an API
+	AudioFormat *AudioFormat `json:"audio_format,omitempty"` // Set if the ability can stream audio
+	Health      HealthStatus `json:"health"`
+	IsOn        bool         `json:"is_on"`
+	Name        string       `json:"name"`
+	State       AbilityState `json:"state"`
+}
+
+// WebSocketAbilityHealth is a websocket ability health payload
+type WebSocketAbilityHealth struct {
+	Error  string       `json:"error,omitempty"`
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+}
+
+// WebSocketAbilityTransition is a websocket ability transition payload
+type WebSocketAbilityTransition struct {
+	From AbilityState `json:"from"`
+	Name string       `json:"name"`
+	To   AbilityState `json:"to"`
+}
+
+// WebSocketAbilityCrashed is a websocket ability crashed payload
+type WebSocketAbilityCrashed struct {
+	Error string `json:"error"`
+	Name  string `json:"name"`
 }
 
 // sendRegister sends a register event
 func (ws *webSocket) sendRegister(name string) (err error) {
 	// Create payload
 	p := WebSocketRegister{
-		Abilities: make(map[string]WebSocketAbility),
-		Name:      name,
+		Abilities:    ws.abilities.toWebSocket(),
+		AudioInputs:  ws.audioInputs(),
+		AudioOutputs: ws.audioOutputs(),
+		Name:         name,
 	}
 
-	// Loop through abilities
-	ws.abilities.abilities(func(a *ability) error {
-		p.Abilities[a.name] = WebSocketAbility{
-			IsOn: a.t.isOn(),
-			Name: a.name,
-		}
-		return nil
-	})
-
 	// Write
 	if err = ws.c.Write(WebsocketEventNameRegister, p); err != nil {
 		err = errors.Wrapf(err, "astibrain: sending register event with payload %#v failed", p)
@@ -128,10 +288,74 @@ func (ws *webSocket) sendRegister(name string) (err error) {
 	return
 }
 
-// send sends an event and mutes the error (which is still logged)
+// send queues an event and sends it right away if the websocket is connected.
+// Errors are muted (but still logged) and events that couldn't be sent are replayed on reconnect.
 func (ws *webSocket) send(eventName string, payload interface{}) {
+	// Queue
+	if err := ws.q.push(eventName, payload); err != nil {
+		astilog.Error(errors.Wrapf(err, "astibrain: queueing %s websocket event with payload %#v failed", eventName, payload))
+		return
+	}
+
+	// Flush
+	ws.requestFlush()
+}
+
+// sendLive sends an event right away if the websocket is connected and drops it otherwise.
+// It is meant for live data that is useless once stale.
+func (ws *webSocket) sendLive(eventName string, payload interface{}) {
+	if ws.status().State != ConnectionStateConnected {
+		return
+	}
 	if err := ws.c.Write(eventName, payload); err != nil {
-		astilog.Error(errors.Wrapf(err, "astibrain: sending %s websocket event with payload %#v failed", eventName, payload))
+		astilog.Error(errors.Wrapf(err, "astibrain: sending live %s websocket event failed", eventName))
+	}
+}
+
+// switchAudioStreamsOff switches all audio streams off
+func (ws *webSocket) switchAudioStreamsOff() {
+	// Get streams without keeping the lock since streams lock their own mutex before the websocket's one
+	var ss []*AudioStream
+	ws.m.Lock()
+	for _, s := range ws.audio {
+		ss = append(ss, s)
+	}
+	ws.m.Unlock()
+
+	// Switch off
+	for _, s := range ss {
+		s.setOn(false)
+	}
+}
+
+// requestFlush asks the sender to flush queued events without blocking
+func (ws *webSocket) requestFlush() {
+	select {
+	case ws.flushes <- struct{}{}:
+	default:
+	}
+}
+
+// sendQueued flushes queued events whenever requested and connected until the context is done
+func (ws *webSocket) sendQueued(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ws.flushes:
+			if ws.status().State == ConnectionStateConnected {
+				ws.flush()
+			}
+		}
+	}
+}
+
+// flush sends queued events in order
+func (ws *webSocket) flush() {
+	if err := ws.q.flush(func(e WebSocketEvent) error {
+		return ws.c.Write(e.Name, e)
+	}); err != nil {
+		astilog.Error(errors.Wrap(err, "astibrain: flushing websocket events failed, they will be replayed on reconnect"))
 	}
 }
 
@@ -144,15 +368,12 @@ func (ws *webSocket) handleAbilityStart(c *astiws.Client, eventName string, payl
 		return
 	}
 
-	// Retrieve ability
-	a, ok := ws.abilities.ability(name)
-	if !ok {
-		err = fmt.Errorf("astibrain: unknown ability %s", name)
-	}
-
 	// Start ability
-	a.on()
-	return nil
+	if err = ws.abilities.start(name); err != nil {
+		err = errors.Wrapf(err, "astibrain: starting ability %s failed", name)
+		return
+	}
+	return
 }
 
 // handleAbilityStop handles the websocket ability.stop event
@@ -164,13 +385,10 @@ func (ws *webSocket) handleAbilityStop(c *astiws.Client, eventName string, paylo
 		return
 	}
 
-	// Retrieve ability
-	a, ok := ws.abilities.ability(name)
-	if !ok {
-		err = fmt.Errorf("astibrain: unknown ability %s", name)
-	}
-
 	// Stop ability
-	a.off()
-	return nil
+	if err = ws.abilities.stop(name); err != nil {
+		err = errors.Wrapf(err, "astibrain: stopping ability %s failed", name)
+		return
+	}
+	return
 }
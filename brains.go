@@ -21,6 +21,18 @@ func (bs *brains) brain(name string) (b *brain, ok bool) {
 	return
 }
 
+// brainByKey returns a specific brain based on its key.
+func (bs *brains) brainByKey(key string) (b *brain, ok bool) {
+	bs.m.Lock()
+	defer bs.m.Unlock()
+	for _, v := range bs.b {
+		if v.key == key {
+			return v, true
+		}
+	}
+	return
+}
+
 // brains loops through brains and execute a function on each of them.
 // If an error is returned by the function, the loop is stopped.
 func (bs *brains) brains(fn func(b *brain) error) (err error) {
@@ -33,3 +45,27 @@ func (bs *brains) brains(fn func(b *brain) error) (err error) {
 	}
 	return
 }
+
+// set sets a new brain to the pool.
+func (bs *brains) set(b *brain) {
+	bs.m.Lock()
+	defer bs.m.Unlock()
+	bs.b[b.name] = b
+}
+
+// del removes a brain from the pool.
+func (bs *brains) del(name string) {
+	bs.m.Lock()
+	defer bs.m.Unlock()
+	delete(bs.b, name)
+}
+
+// toAPI returns the API representation of the brains
+func (bs *brains) toAPI() (o APIBob) {
+	o = APIBob{Brains: make(map[string]APIBrain)}
+	bs.brains(func(b *brain) error {
+		o.Brains[b.key] = b.toAPI()
+		return nil
+	})
+	return
+}
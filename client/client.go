@@ -0,0 +1,222 @@
+// Package astibobclient is a client for Bob's REST and websocket APIs.
+package astibobclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astibob"
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astibob/hearing"
+	"github.com/pkg/errors"
+)
+
+// Default options
+const (
+	defaultRetryInterval = time.Second
+	defaultTimeout       = 10 * time.Second
+)
+
+// datasetTimeout is added to the timeout when exporting a dataset since every utterance is fetched from brains
+const datasetTimeout = 10 * time.Minute
+
+// Options are client options
+type Options struct {
+	Addr          string        `toml:"addr"` // Addr of Bob's clients server such as 127.0.0.1:6969
+	Password      string        `toml:"password"`
+	RetryInterval time.Duration `toml:"retry_interval"` // Interval between websocket reconnections
+	Timeout       time.Duration `toml:"timeout"`        // Timeout of HTTP requests
+	Username      string        `toml:"username"`
+}
+
+// Client is a client for Bob's REST and websocket APIs
+type Client struct {
+	c *http.Client
+	o Options
+}
+
+// New creates a new client
+func New(o Options) *Client {
+	// Default options
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = defaultRetryInterval
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+
+	// Create client
+	return &Client{
+		c: &http.Client{},
+		o: o,
+	}
+}
+
+// Bob returns Bob's API representation
+func (c *Client) Bob(ctx context.Context) (o astibob.APIBob, err error) {
+	err = c.get(ctx, "/api/bob", &o)
+	return
+}
+
+// References returns the references
+func (c *Client) References(ctx context.Context) (o astibob.APIReferences, err error) {
+	err = c.get(ctx, "/api/references", &o)
+	return
+}
+
+// Reload asks Bob to reload its configuration
+func (c *Client) Reload(ctx context.Context) (o astibob.ReloadReport, err error) {
+	err = c.get(ctx, "/api/bob/reload", &o)
+	return
+}
+
+// Stop asks Bob to stop
+func (c *Client) Stop(ctx context.Context) error {
+	return c.get(ctx, "/api/bob/stop", nil)
+}
+
+// StartAbility asks Bob to start an ability.
+// The ability is started asynchronously: an ability.transitioned event is dispatched once it has transitioned.
+func (c *Client) StartAbility(ctx context.Context, brainKey, abilityKey string) error {
+	return c.do(ctx, http.MethodPost, abilityPath(brainKey, abilityKey, "start"), nil)
+}
+
+// StopAbility asks Bob to stop an ability.
+// The ability is stopped asynchronously: an ability.transitioned event is dispatched once it has transitioned.
+func (c *Client) StopAbility(ctx context.Context, brainKey, abilityKey string) error {
+	return c.do(ctx, http.MethodPost, abilityPath(brainKey, abilityKey, "stop"), nil)
+}
+
+// RecordAudio records an ability's audio stream for the provided duration and writes it as a WAV file
+func (c *Client) RecordAudio(ctx context.Context, brainKey, abilityKey string, d time.Duration, w io.Writer) (err error) {
+	// Send
+	var resp *http.Response
+	if resp, err = c.send(ctx, http.MethodGet, abilityPath(brainKey, abilityKey, "audio/record")+"?duration="+url.QueryEscape(d.String()), d); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// Copy
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		err = errors.Wrap(err, "astibobclient: copying audio failed")
+		return
+	}
+	return
+}
+
+// Dataset exports validated utterances of all connected brains and writes them as a tar.gz archive
+func (c *Client) Dataset(ctx context.Context, o astihearing.DatasetOptions, w io.Writer) (err error) {
+	// Build query
+	var q = url.Values{}
+	if len(o.Formats) > 0 {
+		q.Set("formats", strings.Join(o.Formats, ","))
+	}
+	if o.SampleRate > 0 {
+		q.Set("sample_rate", strconv.Itoa(o.SampleRate))
+	}
+	if o.DevRatio != nil {
+		q.Set("dev_ratio", strconv.FormatFloat(*o.DevRatio, 'f', -1, 64))
+	}
+	if o.TestRatio != nil {
+		q.Set("test_ratio", strconv.FormatFloat(*o.TestRatio, 'f', -1, 64))
+	}
+
+	// Send
+	var resp *http.Response
+	if resp, err = c.send(ctx, http.MethodGet, "/api/dataset?"+q.Encode(), datasetTimeout); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// Copy
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		err = errors.Wrap(err, "astibobclient: copying dataset failed")
+		return
+	}
+	return
+}
+
+// abilityPath returns the path of an ability endpoint
+func abilityPath(brainKey, abilityKey, action string) string {
+	return "/api/brains/" + url.PathEscape(brainKey) + "/abilities/" + url.PathEscape(abilityKey) + "/" + action
+}
+
+// get sends a GET request and decodes the response into v if provided
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	return c.do(ctx, http.MethodGet, path, v)
+}
+
+// do sends a request and decodes the response into v if provided
+func (c *Client) do(ctx context.Context, method, path string, v interface{}) (err error) {
+	// Send
+	var resp *http.Response
+	if resp, err = c.send(ctx, method, path, 0); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// Decode
+	if v != nil {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			err = errors.Wrapf(err, "astibobclient: json decoding %s %s response failed", method, path)
+			return
+		}
+	}
+	return
+}
+
+// send sends a request and checks the response status code.
+// extraTimeout is added to the timeout for requests that are expected to last.
+func (c *Client) send(ctx context.Context, method, path string, extraTimeout time.Duration) (resp *http.Response, err error) {
+	// Create request
+	var req *http.Request
+	if req, err = http.NewRequest(method, "http://"+c.o.Addr+path, nil); err != nil {
+		err = errors.Wrapf(err, "astibobclient: creating %s %s request failed", method, path)
+		return
+	}
+	if len(c.o.Username) > 0 || len(c.o.Password) > 0 {
+		req.SetBasicAuth(c.o.Username, c.o.Password)
+	}
+
+	// Add timeout
+	// The context is cancelled once the body is closed
+	ctx, cancel := context.WithTimeout(ctx, c.o.Timeout+extraTimeout)
+	req = req.WithContext(ctx)
+
+	// Send
+	if resp, err = c.c.Do(req); err != nil {
+		cancel()
+		err = errors.Wrapf(err, "astibobclient: sending %s %s failed", method, path)
+		return
+	}
+	resp.Body = &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}
+
+	// Invalid status code
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var e astibrain.APIError
+		json.NewDecoder(resp.Body).Decode(&e)
+		err = fmt.Errorf("astibobclient: %s %s returned status code %d with message %s", method, path, resp.StatusCode, e.Message)
+		return
+	}
+	return
+}
+
+// cancelReadCloser cancels a context once closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements the io.Closer interface
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,47 @@
+package astibobclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astibob"
+	"github.com/asticode/go-astibob/brain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	var paths []string
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Check credentials
+		if u, p, ok := r.BasicAuth(); !ok || u != "u" || p != "p" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Handle
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/bob":
+			json.NewEncoder(rw).Encode(astibob.APIBob{Brains: map[string]astibob.APIBrain{"b": {Key: "b", Name: "B"}}})
+		case "POST /api/brains/b/abilities/a/start":
+			rw.WriteHeader(http.StatusNoContent)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(rw).Encode(astibrain.APIError{Message: "not found"})
+		}
+	}))
+	defer s.Close()
+
+	c := New(Options{Addr: strings.TrimPrefix(s.URL, "http://"), Password: "p", Username: "u"})
+	b, err := c.Bob(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, astibob.APIBob{Brains: map[string]astibob.APIBrain{"b": {Key: "b", Name: "B"}}}, b)
+	assert.NoError(t, c.StartAbility(context.Background(), "b", "a"))
+	err = c.StopAbility(context.Background(), "b", "a")
+	assert.EqualError(t, err, "astibobclient: POST /api/brains/b/abilities/a/stop returned status code 404 with message not found")
+	assert.Equal(t, []string{"GET /api/bob", "POST /api/brains/b/abilities/a/start", "POST /api/brains/b/abilities/a/stop"}, paths)
+}
@@ -0,0 +1,136 @@
+package astibobclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/asticode/go-astibob"
+	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astiws"
+	"github.com/pkg/errors"
+)
+
+// webSocketMaxMessageSize is the websocket max message size
+const webSocketMaxMessageSize = 1 << 20
+
+// eventPayloads indexes the payload types of the events the client listens to by event name
+var eventPayloads = map[string]reflect.Type{
+	astibob.ClientsWebsocketEventNameAbilityCrashed:      reflect.TypeOf(astibob.APIAbilityCrashed{}),
+	astibob.ClientsWebsocketEventNameAbilityHealth:       reflect.TypeOf(astibob.APIAbilityHealth{}),
+	astibob.ClientsWebsocketEventNameAbilityTransitioned: reflect.TypeOf(astibob.APIAbilityTransition{}),
+	astibob.ClientsWebsocketEventNameBrainDisconnected:   reflect.TypeOf(astibob.APIBrain{}),
+	astibob.ClientsWebsocketEventNameBrainRegistered:     reflect.TypeOf(astibob.APIBrain{}),
+	astibob.ClientsWebsocketEventNameBrainStats:          reflect.TypeOf(astibob.APIBrainStats{}),
+}
+
+// ErrorHandler handles errors occurring while listening
+type ErrorHandler func(err error)
+
+// Listen connects to Bob's clients websocket and executes the handler on each event until the context is done.
+// Payloads have the same types as the ones of the events dispatched by Bob to its Go subscribers.
+// It reconnects automatically when the connection is lost.
+// Errors, such as a failed connection or a payload that can't be decoded, are passed to the error handler. They are
+// logged if it is nil.
+func (c *Client) Listen(ctx context.Context, h astibob.EventHandler, eh ErrorHandler) {
+	// Default error handler
+	if eh == nil {
+		eh = func(err error) { astilog.Error(err) }
+	}
+
+	for {
+		// Listen
+		if err := c.listen(ctx, h, eh); err != nil && ctx.Err() == nil {
+			eh(errors.Wrap(err, "astibobclient: listening failed"))
+		}
+
+		// Wait before reconnecting
+		select {
+		case <-time.After(c.o.RetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listen connects to Bob's clients websocket and reads events until the connection is lost or the context is done
+func (c *Client) listen(ctx context.Context, h astibob.EventHandler, eh ErrorHandler) (err error) {
+	// Get references
+	var r astibob.APIReferences
+	if r, err = c.References(ctx); err != nil {
+		err = errors.Wrap(err, "astibobclient: getting references failed")
+		return
+	}
+
+	// Add credentials
+	// They're sent in a header since websocket urls can't contain userinfo
+	var hs http.Header
+	if len(c.o.Username) > 0 || len(c.o.Password) > 0 {
+		hs = http.Header{}
+		hs.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.o.Username+":"+c.o.Password)))
+	}
+
+	// Create websocket client
+	var ws = astiws.NewClient(webSocketMaxMessageSize)
+	defer ws.Close()
+
+	// Add listeners
+	for n, t := range eventPayloads {
+		t := t
+		ws.AddListener(n, func(_ *astiws.Client, eventName string, payload json.RawMessage) (err error) {
+			// Decode payload
+			var v = reflect.New(t)
+			if err := json.Unmarshal(payload, v.Interface()); err != nil {
+				eh(errors.Wrapf(err, "astibobclient: json unmarshaling %s payload failed", eventName))
+				return nil
+			}
+
+			// Handle
+			h(astibob.Event{Name: eventName, Payload: v.Elem().Interface()})
+			return
+		})
+	}
+
+	// Dial
+	if err = ws.DialWithHeaders(r.WsURL, hs); err != nil {
+		err = errors.Wrapf(err, "astibobclient: dialing %s failed", r.WsURL)
+		return
+	}
+
+	// Get ping period
+	var pingPeriod = time.Duration(r.WsPingPeriod) * time.Second
+	if pingPeriod <= 0 {
+		pingPeriod = astiws.PingPeriod
+	}
+
+	// Ping periodically and close the websocket once the context is done
+	var chanDone = make(chan struct{})
+	defer close(chanDone)
+	go func() {
+		var t = time.NewTicker(pingPeriod)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := ws.Write(astibob.ClientsWebsocketEventNamePing, nil); err != nil {
+					astilog.Error(errors.Wrap(err, "astibobclient: sending ping failed"))
+				}
+			case <-ctx.Done():
+				ws.Close()
+				return
+			case <-chanDone:
+				return
+			}
+		}
+	}()
+
+	// Read
+	if err = ws.Read(); err != nil {
+		err = errors.Wrap(err, "astibobclient: reading websocket failed")
+		return
+	}
+	return
+}
@@ -0,0 +1,152 @@
+package astibobclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astibob"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Listen(t *testing.T) {
+	// Each connection receives its messages and is then closed
+	var conns = [][]string{
+		{
+			`{"event_name":"brain.registered","payload":{"key":"b","name":"B"}}`,
+			`{"event_name":"brain.stats","payload":"invalid"}`,
+		},
+		{
+			`{"event_name":"brain.disconnected","payload":{"key":"b","name":"B"}}`,
+		},
+	}
+	var m sync.Mutex
+	var u = websocket.Upgrader{}
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/references":
+			json.NewEncoder(rw).Encode(astibob.APIReferences{WsURL: strings.Replace(s.URL, "http://", "ws://", 1) + "/websocket"})
+		case "/websocket":
+			// Get messages
+			m.Lock()
+			if len(conns) == 0 {
+				m.Unlock()
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			ms := conns[0]
+			conns = conns[1:]
+			m.Unlock()
+
+			// Upgrade
+			c, err := u.Upgrade(rw, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+
+			// Write
+			for _, msg := range ms {
+				c.WriteMessage(websocket.TextMessage, []byte(msg))
+			}
+		}
+	}))
+	defer s.Close()
+
+	// Listen
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var es = make(chan astibob.Event, 10)
+	var errs = make(chan error, 10)
+	var done = make(chan struct{})
+	c := New(Options{Addr: strings.TrimPrefix(s.URL, "http://"), RetryInterval: time.Millisecond})
+	go func() {
+		c.Listen(ctx, func(e astibob.Event) { es <- e }, func(err error) { errs <- err })
+		close(done)
+	}()
+
+	// Events are decoded, invalid payloads are reported and connections are reestablished
+	for _, e := range []astibob.Event{
+		{Name: astibob.ClientsWebsocketEventNameBrainRegistered, Payload: astibob.APIBrain{Key: "b", Name: "B"}},
+		{Name: astibob.ClientsWebsocketEventNameBrainDisconnected, Payload: astibob.APIBrain{Key: "b", Name: "B"}},
+	} {
+		select {
+		case v := <-es:
+			assert.Equal(t, e, v)
+		case <-time.After(time.Second):
+			t.Fatalf("%s event was not received", e.Name)
+		}
+	}
+	select {
+	case err := <-errs:
+		assert.True(t, strings.HasPrefix(err.Error(), "astibobclient: json unmarshaling brain.stats payload failed"))
+	case <-time.After(time.Second):
+		t.Fatal("decoding error was not reported")
+	}
+
+	// Lost connections are reported
+	select {
+	case err := <-errs:
+		assert.True(t, strings.HasPrefix(err.Error(), "astibobclient: listening failed"))
+	case <-time.After(time.Second):
+		t.Fatal("connection error was not reported")
+	}
+
+	// Stop
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listening didn't stop")
+	}
+}
+
+func TestClient_ListenBasicAuth(t *testing.T) {
+	// Every route requires credentials
+	var u = websocket.Upgrader{}
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		// Check credentials
+		if u, p, ok := r.BasicAuth(); !ok || u != "u" || p != "p" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Handle
+		switch r.URL.Path {
+		case "/api/references":
+			json.NewEncoder(rw).Encode(astibob.APIReferences{WsURL: strings.Replace(s.URL, "http://", "ws://", 1) + "/websocket"})
+		case "/websocket":
+			c, err := u.Upgrade(rw, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			c.WriteMessage(websocket.TextMessage, []byte(`{"event_name":"brain.registered","payload":{"key":"b","name":"B"}}`))
+			c.ReadMessage()
+		}
+	}))
+	defer s.Close()
+
+	// Listen
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var es = make(chan astibob.Event, 10)
+	c := New(Options{Addr: strings.TrimPrefix(s.URL, "http://"), Password: "p", RetryInterval: time.Millisecond, Username: "u"})
+	go c.Listen(ctx, func(e astibob.Event) { es <- e }, func(err error) {})
+
+	// Event is received
+	select {
+	case e := <-es:
+		assert.Equal(t, astibob.Event{Name: astibob.ClientsWebsocketEventNameBrainRegistered, Payload: astibob.APIBrain{Key: "b", Name: "B"}}, e)
+	case <-time.After(time.Second):
+		t.Fatal("event was not received")
+	}
+}
@@ -0,0 +1,174 @@
+package astibob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astibob/hearing"
+	"github.com/asticode/go-astilog"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+// brainsDatasetSource is a dataset source fetching utterances from the abilities of connected brains exposing an API
+type brainsDatasetSource struct {
+	abilities   map[string]brainsDatasetAbility // Indexed by brain and utterance id
+	apiRequests *abilityAPIRequests
+	brains      *brains
+	ctx         context.Context
+}
+
+// brainsDatasetAbility represents the ability an utterance has been fetched from
+type brainsDatasetAbility struct {
+	a *ability
+	b *brain
+}
+
+// newBrainsDatasetSource creates a new brains dataset source
+func newBrainsDatasetSource(ctx context.Context, brains *brains, apiRequests *abilityAPIRequests) *brainsDatasetSource {
+	return &brainsDatasetSource{
+		abilities:   make(map[string]brainsDatasetAbility),
+		apiRequests: apiRequests,
+		brains:      brains,
+		ctx:         ctx,
+	}
+}
+
+// do sends a GET request to an ability API and checks its status code, which is returned along the error
+func (s *brainsDatasetSource) do(a brainsDatasetAbility, path, query string) (b []byte, statusCode int, err error) {
+	// Send
+	if err = s.apiRequests.do(s.ctx, a.b, astibrain.WebSocketAbilityAPIRequest{
+		Method: http.MethodGet,
+		Name:   a.a.name,
+		Path:   path,
+		Query:  query,
+	}, func(r astibrain.WebSocketAbilityAPIResponse) error {
+		if statusCode == 0 {
+			statusCode = r.StatusCode
+		}
+		b = append(b, r.Body...)
+		return nil
+	}); err != nil {
+		err = errors.Wrapf(err, "astibob: sending GET %s to ability %s of brain %s failed", path, a.a.name, a.b.name)
+		return
+	}
+
+	// Invalid status code
+	if statusCode != http.StatusOK {
+		return nil, statusCode, fmt.Errorf("astibob: GET %s to ability %s of brain %s returned status code %d", path, a.a.name, a.b.name, statusCode)
+	}
+	return
+}
+
+// DatasetUtterances implements the astihearing.DatasetSource interface
+func (s *brainsDatasetSource) DatasetUtterances() (o []astihearing.StoredUtterance, err error) {
+	// Get abilities exposing an API without keeping the locks
+	var as []brainsDatasetAbility
+	s.brains.brains(func(b *brain) error {
+		return b.abilities(func(a *ability) error {
+			if a.toAPI().API {
+				as = append(as, brainsDatasetAbility{a: a, b: b})
+			}
+			return nil
+		})
+	})
+
+	// Loop through abilities
+	for _, a := range as {
+		// Get utterances
+		var b []byte
+		var statusCode int
+		if b, statusCode, err = s.do(a, "/utterances", "status="+astihearing.StoredUtteranceStatusValidated); err != nil {
+			// Abilities exposing an API that are not hearing are skipped
+			if statusCode == http.StatusNotFound {
+				astilog.Debug(errors.Wrap(err, "astibob: getting utterances failed"))
+				err = nil
+				continue
+			}
+			err = errors.Wrapf(err, "astibob: getting utterances of ability %s of brain %s failed", a.a.name, a.b.name)
+			return
+		}
+
+		// Unmarshal
+		var us []astihearing.StoredUtterance
+		if err = json.Unmarshal(b, &us); err != nil {
+			err = errors.Wrapf(err, "astibob: unmarshaling utterances of ability %s of brain %s failed", a.a.name, a.b.name)
+			return
+		}
+
+		// Index
+		for _, u := range us {
+			s.abilities[u.Brain+"/"+u.ID] = a
+			o = append(o, u)
+		}
+	}
+	return
+}
+
+// DatasetWAV implements the astihearing.DatasetSource interface
+func (s *brainsDatasetSource) DatasetWAV(u astihearing.StoredUtterance) ([]byte, error) {
+	a, ok := s.abilities[u.Brain+"/"+u.ID]
+	if !ok {
+		return nil, fmt.Errorf("astibob: unknown utterance %s of brain %s", u.ID, u.Brain)
+	}
+	b, _, err := s.do(a, "/utterances/"+u.ID+"/wav", "")
+	return b, err
+}
+
+// handleAPIDatasetGET exports validated utterances of all connected brains as a tar.gz dataset.
+// Options are set with the "formats" (comma separated), "sample_rate", "dev_ratio" and "test_ratio" query parameters.
+func (s *clientsServer) handleAPIDatasetGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Parse options
+	var err error
+	var o astihearing.DatasetOptions
+	q := r.URL.Query()
+	if v := q.Get("formats"); len(v) > 0 {
+		o.Formats = strings.Split(v, ",")
+	}
+	if v := q.Get("sample_rate"); len(v) > 0 {
+		if o.SampleRate, err = strconv.Atoi(v); err != nil {
+			rw.Header().Set("Content-Type", "application/json")
+			astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrapf(err, "astibob: parsing sample rate %s failed", v))
+			return
+		}
+	}
+	for k, ptr := range map[string]**float64{"dev_ratio": &o.DevRatio, "test_ratio": &o.TestRatio} {
+		if v := q.Get(k); len(v) > 0 {
+			var f float64
+			if f, err = strconv.ParseFloat(v, 64); err != nil {
+				rw.Header().Set("Content-Type", "application/json")
+				astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrapf(err, "astibob: parsing %s %s failed", k, v))
+				return
+			}
+			*ptr = &f
+		}
+	}
+
+	// Validate options
+	if err = o.Validate(); err != nil {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrap(err, "astibob: validating options failed"))
+		return
+	}
+
+	// Export
+	// Headers can't be changed once the archive has started being written, therefore errors are only logged
+	rw.Header().Set("Content-Type", "application/gzip")
+	rw.Header().Set("Content-Disposition", "attachment; filename=\"dataset.tar.gz\"")
+	w := astihearing.NewTarGzDatasetWriter(rw)
+	var rp astihearing.DatasetReport
+	if rp, err = astihearing.ExportDataset(newBrainsDatasetSource(r.Context(), s.brains, s.apiRequests), w, o); err != nil {
+		astilog.Error(errors.Wrap(err, "astibob: exporting dataset failed"))
+		return
+	}
+	if err = w.Close(); err != nil {
+		astilog.Error(errors.Wrap(err, "astibob: closing dataset writer failed"))
+		return
+	}
+	astilog.Infof("astibob: exported dataset of %s with splits %v", rp.Duration, rp.Splits)
+}
@@ -0,0 +1,65 @@
+package astibob
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astibob/hearing"
+	"github.com/asticode/go-astiws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrainsDatasetSource(t *testing.T) {
+	// Brain answers utterances requests with the status code of the ability
+	rs := newAbilityAPIRequests()
+	var statusCodes = map[string]int{"hearing": http.StatusOK, "other": http.StatusNotFound}
+	m := astiws.NewManager(webSocketMaxMessageSize)
+	s := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		m.ServeHTTP(rw, r, func(c *astiws.Client) {
+			c.AddListener(astibrain.WebsocketEventNameAbilityAPIRequest, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+				var r astibrain.WebSocketAbilityAPIRequest
+				json.Unmarshal(payload, &r)
+				o := astibrain.WebSocketAbilityAPIResponse{ID: r.ID, StatusCode: statusCodes[r.Name]}
+				if o.StatusCode == http.StatusOK {
+					o.Body, _ = json.Marshal([]astihearing.StoredUtterance{{Brain: "brain", ID: "1"}})
+				}
+				rs.respond(o)
+				return nil
+			})
+		})
+	}))
+	defer s.Close()
+	defer m.Close()
+
+	// Connect to brain
+	ws := astiws.NewClient(webSocketMaxMessageSize)
+	defer ws.Close()
+	if !assert.NoError(t, ws.Dial(strings.Replace(s.URL, "http://", "ws://", 1))) {
+		return
+	}
+	go ws.Read()
+	b := newBrain("brain")
+	b.ws = ws
+	bs := newBrains()
+	bs.set(b)
+	for n := range statusCodes {
+		a := newAbility(n, astibrain.AbilityStateRunning, astibrain.HealthStatusUnknown)
+		a.api = true
+		b.set(a)
+	}
+
+	// Abilities that are not hearing are skipped
+	us, err := newBrainsDatasetSource(context.Background(), bs, rs).DatasetUtterances()
+	assert.NoError(t, err)
+	assert.Equal(t, []astihearing.StoredUtterance{{Brain: "brain", ID: "1"}}, us)
+
+	// Other errors fail
+	statusCodes["other"] = http.StatusInternalServerError
+	_, err = newBrainsDatasetSource(context.Background(), bs, rs).DatasetUtterances()
+	assert.Error(t, err)
+}
@@ -0,0 +1,207 @@
+// Package astiflags derives command-line flags and environment variables from the toml tags of a configuration.
+//
+// A field tagged `toml:"url"` nested in a field tagged `toml:"websocket"` nested in a field tagged `toml:"brain"`
+// is settable with the -brain.websocket.url flag and the <PREFIX>_BRAIN_WEBSOCKET_URL environment variable.
+//
+// Values are set directly in the configuration: environment variables are read when binding and flags are read
+// when the flag set is parsed, therefore flags take precedence over environment variables.
+//
+// Binding fails on fields whose type is not supported, unless they are tagged `flag:"-"`.
+package astiflags
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Bind registers a flag for every field of v, which must be a pointer to a struct, and sets the fields whose
+// environment variable is set.
+// It must be called before the flag set is parsed.
+func Bind(fs *flag.FlagSet, envPrefix string, v interface{}) (err error) {
+	// Check v
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		err = fmt.Errorf("astiflags: %T is not a pointer to a struct", v)
+		return
+	}
+
+	// Bind
+	if err = bindStruct(fs, envPrefix, nil, rv.Elem()); err != nil {
+		err = errors.Wrap(err, "astiflags: binding struct failed")
+		return
+	}
+	return
+}
+
+// bindStruct binds the fields of a struct
+func bindStruct(fs *flag.FlagSet, envPrefix string, path []string, v reflect.Value) (err error) {
+	for i := 0; i < v.NumField(); i++ {
+		// Get name
+		var f = v.Type().Field(i)
+		var name = strings.Split(f.Tag.Get("toml"), ",")[0]
+		if name == "-" || f.Tag.Get("flag") == "-" || len(f.PkgPath) > 0 {
+			continue
+		} else if len(name) == 0 {
+			name = strings.ToLower(f.Name)
+		}
+		var p = append(append([]string{}, path...), name)
+
+		// Nested struct
+		var fv = v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			if err = bindStruct(fs, envPrefix, p, fv); err != nil {
+				return
+			}
+			continue
+		}
+
+		// Unsupported type
+		var val = &value{v: fv}
+		if !val.supported() {
+			err = fmt.Errorf("astiflags: type %s of %s is not supported, it should be tagged `flag:\"-\"`", fv.Type(), strings.Join(p, "."))
+			return
+		}
+
+		// Environment variable
+		var env = envName(envPrefix, p)
+		if s, ok := os.LookupEnv(env); ok {
+			if err = val.Set(s); err != nil {
+				err = errors.Wrapf(err, "astiflags: setting %s from environment variable %s failed", strings.Join(p, "."), env)
+				return
+			}
+		}
+
+		// Flag
+		fs.Var(val, strings.Join(p, "."), fmt.Sprintf("%s (env %s)", fv.Type(), env))
+	}
+	return
+}
+
+// envName returns the environment variable name of a path
+func envName(prefix string, path []string) string {
+	var ps []string
+	if len(prefix) > 0 {
+		ps = append(ps, prefix)
+	}
+	ps = append(ps, path...)
+	return strings.ToUpper(strings.Replace(strings.Join(ps, "_"), "-", "_", -1))
+}
+
+// durationType is the time.Duration type
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// value is a flag.Value setting a struct field
+type value struct {
+	v reflect.Value
+}
+
+// supported returns whether the field type is supported.
+// Pointers are supported if the type they point to is, which allows distinguishing unset values from zero values.
+func (v *value) supported() bool {
+	switch v.v.Kind() {
+	case reflect.Ptr:
+		return v.v.Type().Elem().Kind() != reflect.Ptr && (&value{v: reflect.New(v.v.Type().Elem()).Elem()}).supported()
+	case reflect.Bool, reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.String, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return v.v.Type().Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// IsBoolFlag implements the flag boolFlag interface so that bool flags don't need a value
+func (v *value) IsBoolFlag() bool {
+	if !v.v.IsValid() {
+		return false
+	}
+	if v.v.Kind() == reflect.Ptr {
+		return v.v.Type().Elem().Kind() == reflect.Bool
+	}
+	return v.v.Kind() == reflect.Bool
+}
+
+// String implements the flag.Value interface
+func (v *value) String() string {
+	if !v.v.IsValid() {
+		return ""
+	}
+	if v.v.Kind() == reflect.Ptr {
+		if v.v.IsNil() {
+			return ""
+		}
+		return (&value{v: v.v.Elem()}).String()
+	}
+	if v.v.Type() == durationType {
+		return time.Duration(v.v.Int()).String()
+	}
+	if v.v.Kind() == reflect.Slice {
+		var ss []string
+		for i := 0; i < v.v.Len(); i++ {
+			ss = append(ss, v.v.Index(i).String())
+		}
+		return strings.Join(ss, ",")
+	}
+	return fmt.Sprintf("%v", v.v.Interface())
+}
+
+// Set implements the flag.Value interface
+// Slices are comma separated.
+func (v *value) Set(s string) (err error) {
+	switch v.v.Kind() {
+	case reflect.Ptr:
+		var p = reflect.New(v.v.Type().Elem())
+		if err = (&value{v: p.Elem()}).Set(s); err != nil {
+			return
+		}
+		v.v.Set(p)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err != nil {
+			return
+		}
+		v.v.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(s, v.v.Type().Bits()); err != nil {
+			return
+		}
+		v.v.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if v.v.Type() == durationType {
+			var d time.Duration
+			if d, err = time.ParseDuration(s); err != nil {
+				return
+			}
+			i = int64(d)
+		} else if i, err = strconv.ParseInt(s, 10, v.v.Type().Bits()); err != nil {
+			return
+		}
+		v.v.SetInt(i)
+	case reflect.String:
+		v.v.SetString(s)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(s, 10, v.v.Type().Bits()); err != nil {
+			return
+		}
+		v.v.SetUint(u)
+	case reflect.Slice:
+		var ss []string
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); len(p) > 0 {
+				ss = append(ss, p)
+			}
+		}
+		v.v.Set(reflect.ValueOf(ss).Convert(v.v.Type()))
+	}
+	return
+}
@@ -0,0 +1,50 @@
+package astiflags
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfiguration struct {
+	Bool       bool              `toml:"bool"`
+	Ignored    map[string]string `flag:"-" toml:"ignored"`
+	Nested     testNested
+	String     string `toml:"string"`
+	unexported string
+}
+
+type testNested struct {
+	Duration time.Duration `toml:"duration"`
+	Float    float64       `toml:"float"`
+	Int      int           `toml:"int"`
+	Pointer  *float64      `toml:"pointer"`
+	Strings  []string      `toml:"strings"`
+}
+
+func TestBind(t *testing.T) {
+	os.Setenv("TEST_NESTED_INT", "2")
+	os.Setenv("TEST_STRING", "env")
+	defer os.Unsetenv("TEST_NESTED_INT")
+	defer os.Unsetenv("TEST_STRING")
+	var c testConfiguration
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	assert.NoError(t, Bind(fs, "test", &c))
+	assert.Equal(t, testConfiguration{Nested: testNested{Int: 2}, String: "env"}, c)
+	assert.NoError(t, fs.Parse([]string{"-bool", "-nested.duration", "2s", "-nested.float", "1.5", "-nested.strings", "a, b", "-string", "flag"}))
+	assert.Equal(t, testConfiguration{Bool: true, Nested: testNested{Duration: 2 * time.Second, Float: 1.5, Int: 2, Strings: []string{"a", "b"}}, String: "flag"}, c)
+	assert.NoError(t, fs.Parse([]string{"-nested.pointer", "0"}))
+	if assert.NotNil(t, c.Nested.Pointer) {
+		assert.Equal(t, 0.0, *c.Nested.Pointer)
+	}
+	assert.Error(t, Bind(fs, "test", c))
+
+	// Unsupported type
+	var u struct {
+		Map map[string]string `toml:"map"`
+	}
+	assert.Error(t, Bind(flag.NewFlagSet("test", flag.ContinueOnError), "test", &u))
+}
@@ -0,0 +1,247 @@
+package astihearing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/asticode/go-astibob/brain"
+	"github.com/asticode/go-astitools/http"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+// APIHandler implements the astibrain.APIHandler interface
+func (h *Hearing) APIHandler() http.Handler {
+	var r = httprouter.New()
+	r.GET("/utterances", astihttp.ChainRouterMiddlewares(h.handleUtterancesGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/utterances/:id", astihttp.ChainRouterMiddlewares(h.handleUtteranceGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.PATCH("/utterances/:id", astihttp.ChainRouterMiddlewares(h.handleUtterancePATCH, astihttp.RouterMiddlewareContentType("application/json")))
+	r.DELETE("/utterances/:id", astihttp.ChainRouterMiddlewares(h.handleUtteranceDELETE, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/utterances/:id/wav", h.handleUtteranceWAVGET)
+	r.POST("/wake_word/enroll", h.handleWakeWordEnrollPOST)
+	r.GET("/wake_word/samples", astihttp.ChainRouterMiddlewares(h.handleWakeWordSamplesGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.POST("/wake_word/samples", astihttp.ChainRouterMiddlewares(h.handleWakeWordSamplesPOST, astihttp.RouterMiddlewareContentType("application/json")))
+	r.DELETE("/wake_word/samples/:id", astihttp.ChainRouterMiddlewares(h.handleWakeWordSampleDELETE, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/wake_word/samples/:id/wav", h.handleWakeWordSampleWAVGET)
+	return r
+}
+
+// storedUtterance retrieves the stored utterance targeted by a request and writes an error if it doesn't exist
+func (h *Hearing) storedUtterance(rw http.ResponseWriter, p httprouter.Params) (s *store, u StoredUtterance, ok bool) {
+	// No store
+	if s = h.store(); s == nil {
+		astibrain.APIWriteError(rw, http.StatusServiceUnavailable, errors.New("astihearing: store is not initialized"))
+		return
+	}
+
+	// Retrieve
+	if u, ok = s.get(p.ByName("id")); !ok {
+		astibrain.APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astihearing: unknown utterance %s", p.ByName("id")))
+		return
+	}
+	return
+}
+
+// handleUtterancesGET returns the stored utterances, optionally filtered by status.
+func (h *Hearing) handleUtterancesGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// No store
+	var o = []StoredUtterance{}
+	s := h.store()
+	if s == nil {
+		astibrain.APIWrite(rw, o)
+		return
+	}
+
+	// Filter
+	status := r.URL.Query().Get("status")
+	for _, u := range s.list() {
+		if len(status) == 0 || u.Status == status {
+			o = append(o, u)
+		}
+	}
+	astibrain.APIWrite(rw, o)
+}
+
+// handleUtteranceGET returns a stored utterance.
+func (h *Hearing) handleUtteranceGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	if _, u, ok := h.storedUtterance(rw, p); ok {
+		astibrain.APIWrite(rw, u)
+	}
+}
+
+// handleUtteranceWAVGET returns a stored utterance wav file.
+func (h *Hearing) handleUtteranceWAVGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve utterance
+	rw.Header().Set("Content-Type", "application/json")
+	s, u, ok := h.storedUtterance(rw, p)
+	if !ok {
+		return
+	}
+
+	// Serve file
+	rw.Header().Set("Content-Type", "audio/wav")
+	http.ServeFile(rw, r, s.wavPath(u.ID))
+}
+
+// APIUtteranceUpdate represents a stored utterance update, nil fields are left untouched
+type APIUtteranceUpdate struct {
+	Status     *string `json:"status,omitempty"`
+	Transcript *string `json:"transcript,omitempty"`
+}
+
+// handleUtterancePATCH updates a stored utterance's transcript and status.
+func (h *Hearing) handleUtterancePATCH(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve utterance
+	s, u, ok := h.storedUtterance(rw, p)
+	if !ok {
+		return
+	}
+
+	// Decode body
+	var b APIUtteranceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrap(err, "astihearing: json decoding body failed"))
+		return
+	}
+
+	// Check status
+	if b.Status != nil {
+		switch *b.Status {
+		case StoredUtteranceStatusFailed, StoredUtteranceStatusJunk, StoredUtteranceStatusLowConfidence, StoredUtteranceStatusValidated:
+		default:
+			astibrain.APIWriteError(rw, http.StatusBadRequest, fmt.Errorf("astihearing: invalid status %s", *b.Status))
+			return
+		}
+	}
+
+	// Update
+	var err error
+	if u, err = s.update(u.ID, func(i *StoredUtterance) error {
+		if b.Status != nil {
+			i.Status = *b.Status
+		}
+		if b.Transcript != nil {
+			i.Transcript = strings.TrimSpace(*b.Transcript)
+		}
+		return nil
+	}); err != nil {
+		astibrain.APIWriteError(rw, http.StatusInternalServerError, errors.Wrapf(err, "astihearing: updating utterance %s failed", u.ID))
+		return
+	}
+	astibrain.APIWrite(rw, u)
+}
+
+// handleUtteranceDELETE deletes a stored utterance.
+func (h *Hearing) handleUtteranceDELETE(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve utterance
+	s, u, ok := h.storedUtterance(rw, p)
+	if !ok {
+		return
+	}
+
+	// Delete
+	if err := s.delete(u.ID); err != nil {
+		astibrain.APIWriteError(rw, http.StatusInternalServerError, errors.Wrapf(err, "astihearing: deleting utterance %s failed", u.ID))
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// wakeWordOrError retrieves the wake word and writes an error if it is not initialized
+func (h *Hearing) wakeWordOrError(rw http.ResponseWriter) (ww *wakeWord, ok bool) {
+	if ww = h.wakeWord(); ww == nil {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusServiceUnavailable, errors.New("astihearing: wake word is not initialized"))
+		return
+	}
+	return ww, true
+}
+
+// wakeWordSampleOrError retrieves the wake word and checks the sample targeted by a request exists
+func (h *Hearing) wakeWordSampleOrError(rw http.ResponseWriter, p httprouter.Params) (ww *wakeWord, ok bool) {
+	if ww, ok = h.wakeWordOrError(rw); !ok {
+		return
+	}
+	if ok = ww.has(p.ByName("id")); !ok {
+		rw.Header().Set("Content-Type", "application/json")
+		astibrain.APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astihearing: unknown wake word sample %s", p.ByName("id")))
+		return
+	}
+	return
+}
+
+// handleWakeWordEnrollPOST enrolls the next utterance as a wake word sample.
+func (h *Hearing) handleWakeWordEnrollPOST(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ww, ok := h.wakeWordOrError(rw)
+	if !ok {
+		return
+	}
+	ww.enrollNext()
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// handleWakeWordSamplesGET returns the wake word samples.
+func (h *Hearing) handleWakeWordSamplesGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ww, ok := h.wakeWordOrError(rw)
+	if !ok {
+		return
+	}
+	astibrain.APIWrite(rw, ww.list())
+}
+
+// handleWakeWordSamplesPOST enrolls the wav file sent in the body as a wake word sample.
+func (h *Hearing) handleWakeWordSamplesPOST(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve wake word
+	ww, ok := h.wakeWordOrError(rw)
+	if !ok {
+		return
+	}
+
+	// Read body
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrap(err, "astihearing: reading body failed"))
+		return
+	}
+
+	// Read wav
+	var u Utterance
+	if u, err = wavToUtterance(b); err != nil {
+		astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrap(err, "astihearing: reading wav failed"))
+		return
+	}
+
+	// Enroll
+	var s WakeWordSample
+	if s, err = ww.add(u); err != nil {
+		astibrain.APIWriteError(rw, http.StatusInternalServerError, errors.Wrap(err, "astihearing: enrolling wake word sample failed"))
+		return
+	}
+	astibrain.APIWrite(rw, s)
+}
+
+// handleWakeWordSampleWAVGET returns a wake word sample wav file.
+func (h *Hearing) handleWakeWordSampleWAVGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ww, ok := h.wakeWordSampleOrError(rw, p)
+	if !ok {
+		return
+	}
+	rw.Header().Set("Content-Type", "audio/wav")
+	http.ServeFile(rw, r, ww.wavPath(p.ByName("id")))
+}
+
+// handleWakeWordSampleDELETE deletes a wake word sample.
+func (h *Hearing) handleWakeWordSampleDELETE(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	ww, ok := h.wakeWordSampleOrError(rw, p)
+	if !ok {
+		return
+	}
+	if err := ww.delete(p.ByName("id")); err != nil {
+		astibrain.APIWriteError(rw, http.StatusInternalServerError, errors.Wrapf(err, "astihearing: deleting wake word sample %s failed", p.ByName("id")))
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,104 @@
+package astihearing
+
+import (
+	"fmt"
+)
+
+// Channel modes
+const (
+	ChannelModeDownmix = "downmix"
+	ChannelModeSelect  = "select"
+	ChannelModeSplit   = "split"
+)
+
+// ChannelsOptions represents options describing how channels of interleaved samples are handled.
+// In downmix mode, which is the default, channels are averaged into a mono stream. In select mode, only one channel
+// is kept. In split mode, each channel is split in utterances independently.
+type ChannelsOptions struct {
+	Channel int    `toml:"channel"` // Channel kept in select mode, starting at 0
+	Mode    string `toml:"mode"`
+}
+
+// validate validates the options
+func (o ChannelsOptions) validate(numChannels int) (err error) {
+	switch o.Mode {
+	case "", ChannelModeDownmix, ChannelModeSplit:
+	case ChannelModeSelect:
+		if o.Channel < 0 || o.Channel >= numChannels {
+			err = fmt.Errorf("astihearing: channel %d is out of range, there are %d channels", o.Channel, numChannels)
+			return
+		}
+	default:
+		err = fmt.Errorf("astihearing: unknown channel mode %s", o.Mode)
+	}
+	return
+}
+
+// numChannels returns the number of channels once handled
+func (o ChannelsOptions) numChannels(in int) int {
+	if o.Mode == ChannelModeSplit {
+		return in
+	}
+	return 1
+}
+
+// deinterleaver handles channels of interleaved samples.
+// Buffers are reused across calls, therefore samples returned must not be retained.
+type deinterleaver struct {
+	channels    [][]int32
+	mono        []int32
+	numChannels int
+	o           ChannelsOptions
+}
+
+// newDeinterleaver creates a new deinterleaver
+func newDeinterleaver(numChannels int, o ChannelsOptions) *deinterleaver {
+	return &deinterleaver{
+		channels:    make([][]int32, numChannels),
+		numChannels: numChannels,
+		o:           o,
+	}
+}
+
+// toMono returns the selected channel or the downmix of all channels
+func (d *deinterleaver) toMono(samples []int32) []int32 {
+	// Nothing to do
+	if d.numChannels == 1 {
+		return samples
+	}
+
+	// Loop through frames
+	d.mono = d.mono[:0]
+	for i := 0; i+d.numChannels <= len(samples); i += d.numChannels {
+		if d.o.Mode == ChannelModeSelect {
+			d.mono = append(d.mono, samples[i+d.o.Channel])
+			continue
+		}
+		var s int64
+		for c := 0; c < d.numChannels; c++ {
+			s += int64(samples[i+c])
+		}
+		d.mono = append(d.mono, int32(s/int64(d.numChannels)))
+	}
+	return d.mono
+}
+
+// split returns the samples of each channel
+func (d *deinterleaver) split(samples []int32) [][]int32 {
+	// Nothing to do
+	if d.numChannels == 1 {
+		d.channels[0] = samples
+		return d.channels
+	}
+
+	// Loop through frames
+	for c := range d.channels {
+		d.channels[c] = d.channels[c][:0]
+	}
+	for i := 0; i+d.numChannels <= len(samples); i += d.numChannels {
+		for c := range d.channels {
+			d.channels[c] = append(d.channels[c], samples[i+c])
+		}
+	}
+	return d.channels
+}
@@ -0,0 +1,27 @@
+package astihearing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeinterleaver(t *testing.T) {
+	// Validate
+	assert.NoError(t, ChannelsOptions{}.validate(2))
+	assert.NoError(t, ChannelsOptions{Channel: 1, Mode: ChannelModeSelect}.validate(2))
+	assert.Error(t, ChannelsOptions{Channel: 2, Mode: ChannelModeSelect}.validate(2))
+	assert.Error(t, ChannelsOptions{Mode: "invalid"}.validate(2))
+
+	// Downmix
+	var samples = []int32{1, 3, -2, -4, 5, 7}
+	assert.Equal(t, []int32{2, -3, 6}, newDeinterleaver(2, ChannelsOptions{}).toMono(samples))
+
+	// Select
+	assert.Equal(t, []int32{3, -4, 7}, newDeinterleaver(2, ChannelsOptions{Channel: 1, Mode: ChannelModeSelect}).toMono(samples))
+
+	// Split
+	d := newDeinterleaver(2, ChannelsOptions{Mode: ChannelModeSplit})
+	assert.Equal(t, [][]int32{{1, -2, 5}, {3, -4, 7}}, d.split(samples))
+	assert.Equal(t, [][]int32{{1}, {3}}, d.split(samples[:2]))
+}
@@ -0,0 +1,437 @@
+package astihearing
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Dataset formats
+const (
+	DatasetFormatCommonVoice = "commonvoice"
+	DatasetFormatDeepSpeech  = "deepspeech"
+	DatasetFormatKaldi       = "kaldi"
+)
+
+// Dataset splits
+const (
+	datasetSplitDev   = "dev"
+	datasetSplitTest  = "test"
+	datasetSplitTrain = "train"
+)
+
+// datasetSplits are the dataset splits in the order they're written
+var datasetSplits = []string{datasetSplitTrain, datasetSplitDev, datasetSplitTest}
+
+// DatasetOptions represents dataset export options.
+// Utterances are assigned to a split based on a hash of their brain and id so that an utterance stays in the same
+// split across exports. Ratios use their default value when nil, and a ratio of 0 leaves its split empty.
+type DatasetOptions struct {
+	DevRatio   *float64 `toml:"dev_ratio"`
+	Formats    []string `toml:"formats"`
+	SampleRate int      `toml:"sample_rate"`
+	TestRatio  *float64 `toml:"test_ratio"`
+}
+
+// Default dataset options
+const (
+	defaultDatasetDevRatio   = 0.1
+	defaultDatasetSampleRate = 16000
+	defaultDatasetTestRatio  = 0.1
+)
+
+// withDefaults returns options with default values
+func (o DatasetOptions) withDefaults() DatasetOptions {
+	if o.DevRatio == nil {
+		v := float64(defaultDatasetDevRatio)
+		o.DevRatio = &v
+	}
+	if len(o.Formats) == 0 {
+		o.Formats = []string{DatasetFormatCommonVoice, DatasetFormatDeepSpeech, DatasetFormatKaldi}
+	}
+	if o.SampleRate <= 0 {
+		o.SampleRate = defaultDatasetSampleRate
+	}
+	if o.TestRatio == nil {
+		v := float64(defaultDatasetTestRatio)
+		o.TestRatio = &v
+	}
+	return o
+}
+
+// Validate checks that options, once default values are applied, are valid
+func (o DatasetOptions) Validate() (err error) {
+	o = o.withDefaults()
+	if *o.DevRatio < 0 || *o.TestRatio < 0 {
+		err = fmt.Errorf("astihearing: dev ratio %f and test ratio %f can't be negative", *o.DevRatio, *o.TestRatio)
+		return
+	} else if *o.DevRatio+*o.TestRatio >= 1 {
+		err = fmt.Errorf("astihearing: dev ratio %f and test ratio %f leave no utterance for training", *o.DevRatio, *o.TestRatio)
+		return
+	}
+	for _, f := range o.Formats {
+		switch f {
+		case DatasetFormatCommonVoice, DatasetFormatDeepSpeech, DatasetFormatKaldi:
+		default:
+			err = fmt.Errorf("astihearing: unknown dataset format %s", f)
+			return
+		}
+	}
+	return
+}
+
+// DatasetSource represents an object providing labeled utterances
+type DatasetSource interface {
+	DatasetUtterances() ([]StoredUtterance, error)
+	DatasetWAV(u StoredUtterance) ([]byte, error)
+}
+
+// DatasetWriter represents an object capable of writing dataset files
+type DatasetWriter interface {
+	WriteFile(path string, data []byte) error
+}
+
+// DatasetReport represents what has been exported
+type DatasetReport struct {
+	Duration time.Duration  `json:"duration"`
+	Splits   map[string]int `json:"splits"` // Number of utterances indexed by split
+}
+
+// datasetItem represents an utterance written in a dataset
+type datasetItem struct {
+	id         string // Kaldi requires utterance ids to be prefixed with the speaker id
+	path       string // Relative to the dataset root
+	size       int
+	speaker    string
+	transcript string
+}
+
+// regexpDatasetID represents the characters replaced in dataset ids
+var regexpDatasetID = regexp.MustCompile("[^\\w]+")
+
+// ExportDataset exports validated utterances of a source into a dataset.
+// WAV files are written in clips/, DeepSpeech CSVs and Common Voice TSVs at the root and Kaldi directories in kaldi/.
+// Paths are relative to the dataset root, Kaldi scripts are expected to be run from there.
+func ExportDataset(s DatasetSource, w DatasetWriter, o DatasetOptions) (r DatasetReport, err error) {
+	// Check options
+	o = o.withDefaults()
+	if err = o.Validate(); err != nil {
+		return
+	}
+
+	// Get utterances
+	var us []StoredUtterance
+	if us, err = s.DatasetUtterances(); err != nil {
+		err = errors.Wrap(err, "astihearing: getting dataset utterances failed")
+		return
+	}
+
+	// Loop through utterances
+	r.Splits = make(map[string]int)
+	var items = make(map[string][]datasetItem)
+	for _, u := range us {
+		// Only validated utterances with a transcript are exported
+		if u.Status != StoredUtteranceStatusValidated || len(strings.TrimSpace(u.Transcript)) == 0 {
+			continue
+		}
+
+		// Create item
+		speaker := regexpDatasetID.ReplaceAllString(u.Brain, "_")
+		i := datasetItem{
+			id:         speaker + "-" + regexpDatasetID.ReplaceAllString(u.ID, "_"),
+			speaker:    speaker,
+			transcript: strings.Join(strings.Fields(u.Transcript), " "),
+		}
+		i.path = "clips/" + i.id + ".wav"
+
+		// Get wav
+		var b []byte
+		if b, err = datasetWAV(s, u, o.SampleRate); err != nil {
+			err = errors.Wrapf(err, "astihearing: getting wav of utterance %s of brain %s failed", u.ID, u.Brain)
+			return
+		}
+		i.size = len(b)
+
+		// Write wav
+		if err = w.WriteFile(i.path, b); err != nil {
+			err = errors.Wrapf(err, "astihearing: writing %s failed", i.path)
+			return
+		}
+
+		// Add item
+		split := datasetSplit(u, o)
+		items[split] = append(items[split], i)
+		r.Duration += u.Duration
+		r.Splits[split]++
+	}
+
+	// Loop through formats
+	for _, f := range o.Formats {
+		for _, split := range datasetSplits {
+			// Kaldi requires sorted files
+			is := items[split]
+			sort.Slice(is, func(i, j int) bool { return is[i].id < is[j].id })
+
+			// Switch on format
+			var fs map[string][]byte
+			switch f {
+			case DatasetFormatCommonVoice:
+				fs, err = datasetCommonVoice(split, is)
+			case DatasetFormatDeepSpeech:
+				fs, err = datasetDeepSpeech(split, is)
+			case DatasetFormatKaldi:
+				fs = datasetKaldi(split, is)
+			}
+			if err != nil {
+				err = errors.Wrapf(err, "astihearing: creating %s %s files failed", f, split)
+				return
+			}
+
+			// Write files
+			for p, b := range fs {
+				if err = w.WriteFile(p, b); err != nil {
+					err = errors.Wrapf(err, "astihearing: writing %s failed", p)
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// datasetSplit returns the split of an utterance
+func datasetSplit(u StoredUtterance, o DatasetOptions) string {
+	h := fnv.New32a()
+	h.Write([]byte(u.Brain + "/" + u.ID))
+	v := float64(h.Sum32()) / float64(1<<32)
+	if v < *o.TestRatio {
+		return datasetSplitTest
+	} else if v < *o.TestRatio+*o.DevRatio {
+		return datasetSplitDev
+	}
+	return datasetSplitTrain
+}
+
+// datasetWAV returns the wav of an utterance resampled to the dataset sample rate
+func datasetWAV(s DatasetSource, u StoredUtterance, sampleRate int) (o []byte, err error) {
+	// Get wav
+	var b []byte
+	if b, err = s.DatasetWAV(u); err != nil {
+		err = errors.Wrap(err, "astihearing: getting wav failed")
+		return
+	}
+
+	// Read wav
+	var v = Utterance{}
+	if v.Samples, v.BitDepth, v.NumChannels, v.SampleRate, err = readWAV(bytes.NewReader(b)); err != nil {
+		err = errors.Wrap(err, "astihearing: reading wav failed")
+		return
+	}
+
+	// Resample
+	v.Samples = resample(v.Samples, v.BitDepth, v.NumChannels, v.SampleRate, sampleRate)
+	v.SampleRate = sampleRate
+
+	// Write wav
+	buf := &bytes.Buffer{}
+	if err = writeWAV(buf, v); err != nil {
+		err = errors.Wrap(err, "astihearing: writing wav failed")
+		return
+	}
+	return buf.Bytes(), nil
+}
+
+// datasetDeepSpeech creates DeepSpeech CSV files
+func datasetDeepSpeech(split string, is []datasetItem) (fs map[string][]byte, err error) {
+	var rs = [][]string{{"wav_filename", "wav_filesize", "transcript"}}
+	for _, i := range is {
+		rs = append(rs, []string{i.path, strconv.Itoa(i.size), strings.ToLower(i.transcript)})
+	}
+	return datasetCSV(split+".csv", ',', rs)
+}
+
+// datasetCommonVoice creates Common Voice TSV files
+func datasetCommonVoice(split string, is []datasetItem) (fs map[string][]byte, err error) {
+	var rs = [][]string{{"client_id", "path", "sentence", "up_votes", "down_votes", "age", "gender", "accent"}}
+	for _, i := range is {
+		rs = append(rs, []string{i.speaker, strings.TrimPrefix(i.path, "clips/"), i.transcript, "1", "0", "", "", ""})
+	}
+	return datasetCSV(split+".tsv", '\t', rs)
+}
+
+// datasetCSV creates a CSV file
+func datasetCSV(path string, comma rune, rs [][]string) (fs map[string][]byte, err error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Comma = comma
+	if err = w.WriteAll(rs); err != nil {
+		err = errors.Wrap(err, "astihearing: writing csv failed")
+		return
+	}
+	return map[string][]byte{path: buf.Bytes()}, nil
+}
+
+// datasetKaldi creates Kaldi data directory files
+func datasetKaldi(split string, is []datasetItem) map[string][]byte {
+	var scp, text, utt2spk = &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	for _, i := range is {
+		fmt.Fprintf(scp, "%s %s\n", i.id, i.path)
+		fmt.Fprintf(text, "%s %s\n", i.id, i.transcript)
+		fmt.Fprintf(utt2spk, "%s %s\n", i.id, i.speaker)
+	}
+	return map[string][]byte{
+		"kaldi/" + split + "/text":    text.Bytes(),
+		"kaldi/" + split + "/utt2spk": utt2spk.Bytes(),
+		"kaldi/" + split + "/wav.scp": scp.Bytes(),
+	}
+}
+
+// dirDatasetSource is a dataset source reading stores directories
+type dirDatasetSource struct {
+	ss    []*store
+	paths map[string]string // Wav paths indexed by brain and id
+}
+
+// NewDirDatasetSource creates a dataset source reading utterances stores directories, which are the "utterances"
+// directories located in hearing working directories
+func NewDirDatasetSource(dirs ...string) (s DatasetSource, err error) {
+	var v = &dirDatasetSource{paths: make(map[string]string)}
+	for _, dir := range dirs {
+		// Stat
+		if _, err = os.Stat(filepath.Join(dir, storeIndexName)); err != nil {
+			err = errors.Wrapf(err, "astihearing: stating index in %s failed", dir)
+			return
+		}
+
+		// Create store
+		var st *store
+		if st, err = newStore(dir); err != nil {
+			err = errors.Wrapf(err, "astihearing: creating store for %s failed", dir)
+			return
+		}
+		v.ss = append(v.ss, st)
+	}
+	return v, nil
+}
+
+// DatasetUtterances implements the DatasetSource interface
+func (s *dirDatasetSource) DatasetUtterances() (o []StoredUtterance, err error) {
+	for _, st := range s.ss {
+		for _, u := range st.list() {
+			s.paths[u.Brain+"/"+u.ID] = st.wavPath(u.ID)
+			o = append(o, u)
+		}
+	}
+	return
+}
+
+// DatasetWAV implements the DatasetSource interface
+func (s *dirDatasetSource) DatasetWAV(u StoredUtterance) (b []byte, err error) {
+	p, ok := s.paths[u.Brain+"/"+u.ID]
+	if !ok {
+		err = fmt.Errorf("astihearing: unknown utterance %s of brain %s", u.ID, u.Brain)
+		return
+	}
+	if b, err = ioutil.ReadFile(p); err != nil {
+		err = errors.Wrapf(err, "astihearing: reading %s failed", p)
+		return
+	}
+	return
+}
+
+// dirDatasetWriter is a dataset writer writing files in a directory
+type dirDatasetWriter struct {
+	dir string
+}
+
+// NewDirDatasetWriter creates a dataset writer writing files in a directory
+func NewDirDatasetWriter(dir string) DatasetWriter {
+	return &dirDatasetWriter{dir: dir}
+}
+
+// WriteFile implements the DatasetWriter interface
+func (w *dirDatasetWriter) WriteFile(path string, data []byte) (err error) {
+	// Path must stay in the directory
+	if c := filepath.Clean(filepath.FromSlash(path)); filepath.IsAbs(c) || c == ".." || strings.HasPrefix(c, ".."+string(filepath.Separator)) {
+		err = fmt.Errorf("astihearing: path %s is outside of the dataset", path)
+		return
+	}
+
+	// Create directory
+	p := filepath.Join(w.dir, filepath.FromSlash(path))
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		err = errors.Wrapf(err, "astihearing: mkdirall %s failed", filepath.Dir(p))
+		return
+	}
+
+	// Write
+	if err = ioutil.WriteFile(p, data, 0644); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing %s failed", p)
+		return
+	}
+	return
+}
+
+// TarGzDatasetWriter is a dataset writer writing files in a tar.gz archive
+type TarGzDatasetWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+// NewTarGzDatasetWriter creates a dataset writer writing files in a tar.gz archive.
+// It needs to be closed for the archive to be complete.
+func NewTarGzDatasetWriter(w io.Writer) *TarGzDatasetWriter {
+	gw := gzip.NewWriter(w)
+	return &TarGzDatasetWriter{
+		gw: gw,
+		tw: tar.NewWriter(gw),
+	}
+}
+
+// WriteFile implements the DatasetWriter interface
+func (w *TarGzDatasetWriter) WriteFile(path string, data []byte) (err error) {
+	// Write header
+	if err = w.tw.WriteHeader(&tar.Header{
+		Mode:    0644,
+		ModTime: time.Now(),
+		Name:    path,
+		Size:    int64(len(data)),
+	}); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing tar header of %s failed", path)
+		return
+	}
+
+	// Write data
+	if _, err = w.tw.Write(data); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing tar data of %s failed", path)
+		return
+	}
+	return
+}
+
+// Close implements the io.Closer interface
+func (w *TarGzDatasetWriter) Close() (err error) {
+	if err = w.tw.Close(); err != nil {
+		err = errors.Wrap(err, "astihearing: closing tar writer failed")
+		return
+	}
+	if err = w.gw.Close(); err != nil {
+		err = errors.Wrap(err, "astihearing: closing gzip writer failed")
+		return
+	}
+	return
+}
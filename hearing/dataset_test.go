@@ -0,0 +1,92 @@
+package astihearing
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportDataset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astihearing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Create store
+	s, err := newStore(filepath.Join(dir, "store"))
+	assert.NoError(t, err)
+	var ids []string
+	for idx := 0; idx < 20; idx++ {
+		u := Utterance{BitDepth: 32, NumChannels: 1, SampleRate: 32000, Samples: make([]int32, 3200), Start: time.Unix(int64(idx), 0)}
+		u.End = u.Start.Add(100 * time.Millisecond)
+		i, err := s.add(u, StoredUtterance{Brain: "my brain", Status: StoredUtteranceStatusFailed})
+		assert.NoError(t, err)
+		ids = append(ids, i.ID)
+	}
+	for idx, id := range ids {
+		_, err = s.update(id, func(i *StoredUtterance) error {
+			if idx > 0 {
+				i.Status = StoredUtteranceStatusValidated
+				i.Transcript = "  Hello   Bob "
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+
+	// Invalid options
+	src, err := NewDirDatasetSource(filepath.Join(dir, "store"))
+	assert.NoError(t, err)
+	out := filepath.Join(dir, "out")
+	_, err = ExportDataset(src, NewDirDatasetWriter(out), DatasetOptions{DevRatio: ratio(0.5), TestRatio: ratio(0.5)})
+	assert.Error(t, err)
+	_, err = ExportDataset(src, NewDirDatasetWriter(out), DatasetOptions{DevRatio: ratio(-0.1)})
+	assert.Error(t, err)
+	_, err = ExportDataset(src, NewDirDatasetWriter(out), DatasetOptions{Formats: []string{"invalid"}})
+	assert.Error(t, err)
+
+	// Export
+	r, err := ExportDataset(src, NewDirDatasetWriter(out), DatasetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 19, r.Splits["train"]+r.Splits["dev"]+r.Splits["test"])
+	assert.Equal(t, 1900*time.Millisecond, r.Duration)
+
+	// WAV is resampled
+	fi, err := os.Stat(filepath.Join(out, "clips", "my_brain-"+ids[1]+".wav"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(44+1600*2), fi.Size())
+
+	// Formats
+	b, err := ioutil.ReadFile(filepath.Join(out, "train.csv"))
+	assert.NoError(t, err)
+	ls := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Equal(t, r.Splits["train"]+1, len(ls))
+	assert.Equal(t, "wav_filename,wav_filesize,transcript", ls[0])
+	assert.Equal(t, "clips/my_brain-"+ids[1]+".wav,3244,hello bob", ls[1])
+	b, err = ioutil.ReadFile(filepath.Join(out, "train.tsv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "my_brain\tmy_brain-"+ids[1]+".wav\tHello Bob\t1\t0\t\t\t", strings.Split(string(b), "\n")[1])
+	b, err = ioutil.ReadFile(filepath.Join(out, "kaldi", "train", "utt2spk"))
+	assert.NoError(t, err)
+	assert.Equal(t, "my_brain-"+ids[1]+" my_brain", strings.Split(string(b), "\n")[0])
+	_, err = os.Stat(filepath.Join(out, "kaldi", "test", "wav.scp"))
+	assert.NoError(t, err)
+
+	// Paths must stay in the dataset
+	assert.Error(t, NewDirDatasetWriter(out).WriteFile("../file", nil))
+
+	// Zero ratios leave their split empty
+	r, err = ExportDataset(src, NewDirDatasetWriter(filepath.Join(dir, "out_train")), DatasetOptions{DevRatio: ratio(0), TestRatio: ratio(0)})
+	assert.NoError(t, err)
+	assert.Equal(t, 19, r.Splits["train"])
+	assert.Equal(t, 0, r.Splits["dev"]+r.Splits["test"])
+}
+
+// ratio returns a pointer to a ratio
+func ratio(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,255 @@
+package astihearing
+
+import (
+	"math"
+	"time"
+)
+
+// Filter represents an object capable of transforming chunks of interleaved samples.
+// Filters are stateful, are fed chunks in order and may modify samples in place.
+type Filter interface {
+	Filter(samples []int32) []int32
+}
+
+// FilterFunc represents a function implementing the Filter interface
+type FilterFunc func(samples []int32) []int32
+
+// Filter implements the Filter interface
+func (f FilterFunc) Filter(samples []int32) []int32 {
+	return f(samples)
+}
+
+// PreprocessingOptions represents preprocessing options.
+// Filters are applied in this order: DC offset removal, high-pass filter, resampling, gain normalization and noise
+// gate. Zero values disable filters.
+type PreprocessingOptions struct {
+	DCRemoval          bool          `toml:"dc_removal"`
+	HighPassFrequency  float64       `toml:"high_pass_frequency"`  // In Hz
+	MaxGain            float64       `toml:"max_gain"`             // In dB, defaults to 20
+	NoiseGateHold      time.Duration `toml:"noise_gate_hold"`      // Defaults to 200ms
+	NoiseGateThreshold float64       `toml:"noise_gate_threshold"` // In dBFS, must be negative
+	SampleRate         int           `toml:"sample_rate"`          // Sample rate samples are resampled to
+	TargetLevel        float64       `toml:"target_level"`         // RMS level in dBFS gain normalization aims at, must be negative
+}
+
+// Default preprocessing options
+const (
+	defaultPreprocessingMaxGain       = 20
+	defaultPreprocessingNoiseGateHold = 200 * time.Millisecond
+)
+
+// preprocessor applies the filter chain described by preprocessing options followed by custom filters
+type preprocessor struct {
+	fs []Filter
+}
+
+// newPreprocessor creates a new preprocessor
+func newPreprocessor(bitDepth, numChannels, sampleRate int, o PreprocessingOptions, custom []Filter) (p *preprocessor) {
+	// Default options
+	if o.MaxGain <= 0 {
+		o.MaxGain = defaultPreprocessingMaxGain
+	}
+	if o.NoiseGateHold <= 0 {
+		o.NoiseGateHold = defaultPreprocessingNoiseGateHold
+	}
+
+	// Create filters
+	p = &preprocessor{}
+	var max = math.Pow(2, float64(bitDepth-1))
+	if o.DCRemoval {
+		p.fs = append(p.fs, newDCRemoval(max, numChannels))
+	}
+	if o.HighPassFrequency > 0 {
+		p.fs = append(p.fs, newHighPass(max, numChannels, sampleRate, o.HighPassFrequency))
+	}
+	if o.SampleRate > 0 && o.SampleRate != sampleRate {
+		p.fs = append(p.fs, newResampler(max, numChannels, sampleRate, o.SampleRate))
+		sampleRate = o.SampleRate
+	}
+	if o.TargetLevel < 0 {
+		p.fs = append(p.fs, newGainNormalizer(max, sampleRate, o.TargetLevel, o.MaxGain))
+	}
+	if o.NoiseGateThreshold < 0 {
+		p.fs = append(p.fs, newNoiseGate(max, numChannels, sampleRate, o.NoiseGateThreshold, o.NoiseGateHold))
+	}
+	p.fs = append(p.fs, custom...)
+	return
+}
+
+// process applies the filters
+func (p *preprocessor) process(samples []int32) []int32 {
+	for _, f := range p.fs {
+		samples = f.Filter(samples)
+	}
+	return samples
+}
+
+// clip converts a float sample to an int32 sample, saturating if needed
+func clip(v, max float64) int32 {
+	if v > max-1 {
+		return int32(max - 1)
+	} else if v < -max {
+		return int32(-max)
+	}
+	return int32(v)
+}
+
+// dcRemoval removes the DC offset with a one pole high-pass filter: y[n] = x[n] - x[n-1] + r * y[n-1]
+type dcRemoval struct {
+	max  float64
+	x, y []float64 // Previous input and output indexed by channel
+}
+
+// dcRemovalPole is the pole of the DC removal filter, its cutoff frequency is around 30Hz at 44.1kHz
+const dcRemovalPole = 0.995
+
+// newDCRemoval creates a new DC removal filter
+func newDCRemoval(max float64, numChannels int) *dcRemoval {
+	return &dcRemoval{
+		max: max,
+		x:   make([]float64, numChannels),
+		y:   make([]float64, numChannels),
+	}
+}
+
+// Filter implements the Filter interface
+func (f *dcRemoval) Filter(samples []int32) []int32 {
+	for i, s := range samples {
+		c := i % len(f.x)
+		x := float64(s)
+		f.y[c] = x - f.x[c] + dcRemovalPole*f.y[c]
+		f.x[c] = x
+		samples[i] = clip(f.y[c], f.max)
+	}
+	return samples
+}
+
+// highPass is a second order Butterworth high-pass biquad filter
+type highPass struct {
+	a1, a2, b0, b1, b2 float64
+	max                float64
+	x1, x2, y1, y2     []float64 // Previous inputs and outputs indexed by channel
+}
+
+// newHighPass creates a new high-pass filter based on the audio EQ cookbook
+func newHighPass(max float64, numChannels, sampleRate int, frequency float64) *highPass {
+	w := 2 * math.Pi * frequency / float64(sampleRate)
+	alpha := math.Sin(w) / (2 * math.Sqrt2 / 2)
+	a0 := 1 + alpha
+	return &highPass{
+		a1:  -2 * math.Cos(w) / a0,
+		a2:  (1 - alpha) / a0,
+		b0:  (1 + math.Cos(w)) / 2 / a0,
+		b1:  -(1 + math.Cos(w)) / a0,
+		b2:  (1 + math.Cos(w)) / 2 / a0,
+		max: max,
+		x1:  make([]float64, numChannels),
+		x2:  make([]float64, numChannels),
+		y1:  make([]float64, numChannels),
+		y2:  make([]float64, numChannels),
+	}
+}
+
+// Filter implements the Filter interface
+func (f *highPass) Filter(samples []int32) []int32 {
+	for i, s := range samples {
+		c := i % len(f.x1)
+		x := float64(s)
+		y := f.b0*x + f.b1*f.x1[c] + f.b2*f.x2[c] - f.a1*f.y1[c] - f.a2*f.y2[c]
+		f.x2[c], f.x1[c] = f.x1[c], x
+		f.y2[c], f.y1[c] = f.y1[c], y
+		samples[i] = clip(y, f.max)
+	}
+	return samples
+}
+
+// gainNormalizer adapts the gain so that the RMS level gets close to a target level
+type gainNormalizer struct {
+	gain      float64
+	max       float64
+	maxGain   float64 // Linear
+	power     float64 // Smoothed mean square, normalized
+	smoothing float64 // Smoothing factor per sample
+	target    float64 // Linear
+}
+
+// gainNormalizerTimeConstant is the time constant of the level estimation
+const gainNormalizerTimeConstant = time.Second
+
+// newGainNormalizer creates a new gain normalizer
+func newGainNormalizer(max float64, sampleRate int, targetLevel, maxGain float64) *gainNormalizer {
+	return &gainNormalizer{
+		gain:      1,
+		max:       max,
+		maxGain:   math.Pow(10, maxGain/20),
+		smoothing: 1 / (gainNormalizerTimeConstant.Seconds() * float64(sampleRate)),
+		target:    math.Pow(10, targetLevel/20),
+	}
+}
+
+// Filter implements the Filter interface
+func (f *gainNormalizer) Filter(samples []int32) []int32 {
+	for i, s := range samples {
+		// Update level
+		x := float64(s) / f.max
+		f.power += f.smoothing * (x*x - f.power)
+
+		// Update gain
+		if f.power > 0 {
+			f.gain = math.Min(f.target/math.Sqrt(f.power), f.maxGain)
+		}
+
+		// Apply gain
+		samples[i] = clip(float64(s)*f.gain, f.max)
+	}
+	return samples
+}
+
+// noiseGate mutes blocks whose level is below a threshold, and keeps the gate open for a while once it has opened
+type noiseGate struct {
+	hold      int // In samples
+	max       float64
+	remaining int // Number of samples the gate stays open
+	threshold float64
+}
+
+// newNoiseGate creates a new noise gate
+func newNoiseGate(max float64, numChannels, sampleRate int, threshold float64, hold time.Duration) *noiseGate {
+	return &noiseGate{
+		hold:      int(hold.Seconds()*float64(sampleRate)) * numChannels,
+		max:       max,
+		threshold: threshold,
+	}
+}
+
+// Filter implements the Filter interface
+func (f *noiseGate) Filter(samples []int32) []int32 {
+	// Get level
+	var sum float64
+	for _, s := range samples {
+		x := float64(s) / f.max
+		sum += x * x
+	}
+	level := float64(minEnergyDB)
+	if len(samples) > 0 && sum > 0 {
+		level = 10 * math.Log10(sum/float64(len(samples)))
+	}
+
+	// Open gate
+	if level >= f.threshold {
+		f.remaining = f.hold
+		return samples
+	}
+
+	// Gate is held open
+	if f.remaining > 0 {
+		f.remaining -= len(samples)
+		return samples
+	}
+
+	// Mute
+	for i := range samples {
+		samples[i] = 0
+	}
+	return samples
+}
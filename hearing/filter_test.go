@@ -0,0 +1,104 @@
+package astihearing
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreprocessor(t *testing.T) {
+	// DC removal
+	p := newPreprocessor(32, 1, 44100, PreprocessingOptions{DCRemoval: true}, nil)
+	var ss []int32
+	for i := 0; i < 10; i++ {
+		ss = p.process(sine(1<<20, 1000, 44100, 4410, 1<<24))
+	}
+	var mean float64
+	for _, s := range ss {
+		mean += float64(s)
+	}
+	assert.InDelta(t, 0, mean/float64(len(ss)), 1<<12)
+
+	// High-pass
+	p = newPreprocessor(32, 1, 16000, PreprocessingOptions{HighPassFrequency: 300}, nil)
+	p.process(sine(1<<24, 50, 16000, 16000, 0))
+	assert.True(t, peak(p.process(sine(1<<24, 50, 16000, 1600, 0))) < 1<<20)
+	p.process(sine(1<<24, 2000, 16000, 16000, 0))
+	assert.True(t, peak(p.process(sine(1<<24, 2000, 16000, 1600, 0))) > 15<<20)
+
+	// Resampling in chunks yields the same samples as resampling at once
+	in := sine(1<<24, 440, 44100, 2*44100, 0)
+	r := newResampler(math.Pow(2, 31), 2, 44100, 16000)
+	var o []int32
+	for i := 0; i < len(in); i += 512 {
+		j := i + 512
+		if j > len(in) {
+			j = len(in)
+		}
+		o = append(o, r.Filter(append([]int32{}, in[i:j]...))...)
+	}
+	o = append(o, r.flush()...)
+	assert.True(t, reflect.DeepEqual(resample(in, 32, 2, 44100, 16000), o))
+	assert.Len(t, o, 2*16000)
+
+	// Resampling keeps frequencies below the target Nyquist frequency and removes the ones above
+	o = resample(sine(1<<24, 1000, 44100, 44100, 0), 32, 1, 44100, 16000)
+	assert.InDelta(t, 1<<24, peak(o[1000:15000]), 1<<18)
+	o = resample(sine(1<<24, 10000, 44100, 44100, 0), 32, 1, 44100, 16000)
+	assert.True(t, peak(o[1000:15000]) < 1<<15)
+
+	// Filters saturate at the bit depth
+	p = newPreprocessor(16, 1, 16000, PreprocessingOptions{HighPassFrequency: 300}, nil)
+	assert.True(t, peak(p.process(sine(1<<15-1, 2000, 16000, 1600, 0))) <= 1<<15)
+
+	// Gain normalization
+	p = newPreprocessor(32, 1, 16000, PreprocessingOptions{TargetLevel: -20}, nil)
+	for i := 0; i < 5; i++ {
+		o = p.process(sine(1<<26, 440, 16000, 16000, 0))
+	}
+	assert.InDelta(t, -20, 20*math.Log10(rms(o)/math.Pow(2, 31)), 1)
+
+	// Noise gate
+	p = newPreprocessor(32, 1, 16000, PreprocessingOptions{NoiseGateHold: 10 * time.Millisecond, NoiseGateThreshold: -40}, nil)
+	assert.Equal(t, int32(0), peak(p.process(sine(1<<20, 440, 16000, 512, 0))))
+	assert.NotEqual(t, int32(0), peak(p.process(sine(1<<28, 440, 16000, 512, 0))))
+	assert.NotEqual(t, int32(0), peak(p.process(sine(1<<20, 440, 16000, 512, 0))))
+	assert.Equal(t, int32(0), peak(p.process(sine(1<<20, 440, 16000, 512, 0))))
+
+	// Custom filters
+	p = newPreprocessor(32, 1, 16000, PreprocessingOptions{}, []Filter{FilterFunc(func(samples []int32) []int32 {
+		return samples[:1]
+	})})
+	assert.Equal(t, []int32{1}, p.process([]int32{1, 2}))
+}
+
+func sine(amplitude, frequency float64, sampleRate, n int, offset int32) (o []int32) {
+	o = make([]int32, n)
+	for i := range o {
+		o[i] = int32(amplitude*math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate))) + offset
+	}
+	return
+}
+
+func peak(samples []int32) (p int32) {
+	for _, s := range samples {
+		if s < 0 {
+			s = -s
+		}
+		if s > p {
+			p = s
+		}
+	}
+	return
+}
+
+func rms(samples []int32) float64 {
+	var sum float64
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
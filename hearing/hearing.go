@@ -2,7 +2,13 @@ package astihearing
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/asticode/go-astilog"
 	"github.com/pkg/errors"
@@ -11,15 +17,94 @@ import (
 // Hearing represents an object capable of parsing an audio reader, split it in valuable chunks and execute a speech to
 // text analysis on each of them.
 type Hearing struct {
-	o Options
-	r SampleReader
+	filters            []Filter
+	lastSampleAt       int64      // Unix nano timestamp, must be accessed atomically
+	m                  sync.Mutex // Locks filters, s, samplesFuncs, stt, transcriptionFuncs, utteranceFuncs, ww and wakeWordFuncs
+	o                  Options
+	r                  SamplesReader
+	s                  *store
+	samplesFuncs       []SamplesFunc
+	stt                SpeechToText
+	transcriptionFuncs []TranscriptionFunc
+	utteranceFuncs     []UtteranceFunc
+	wakeWordFuncs      []WakeWordFunc
+	ww                 *wakeWord
 }
 
-// SampleReader represents a sample reader
+// SamplesFunc represents a function executed on each chunk of samples read
+type SamplesFunc func(samples []int32)
+
+// samplesChunkSize is the number of samples per channel read before being processed
+const samplesChunkSize = 512
+
+// utterancesBufferSize is the number of utterances waiting for speech to text before new ones are dropped
+const utterancesBufferSize = 16
+
+// maxSampleDelay represents the max delay without any sample being read before hearing is considered unhealthy
+const maxSampleDelay = 5 * time.Second
+
+// SampleReader represents a reader returning one sample per call
 type SampleReader interface {
 	ReadSample() (int32, error)
 }
 
+// SamplesReader represents a reader filling a caller provided slice with interleaved samples.
+// It returns the number of samples read which may be lower than the slice length. Samples read are valid even when
+// an error is returned.
+type SamplesReader interface {
+	ReadSamples(samples []int32) (n int, err error)
+}
+
+// ContextSamplesReader represents a samples reader whose reads can be interrupted by a context.
+// Hearing uses it instead of the SamplesReader interface when implemented.
+type ContextSamplesReader interface {
+	ReadSamplesContext(ctx context.Context, samples []int32) (n int, err error)
+}
+
+// SampleReaderAdapter adapts a SampleReader to the SamplesReader interface.
+// It forwards Start and Stop calls to the SampleReader if it implements the Starter interface.
+type SampleReaderAdapter struct {
+	r SampleReader
+}
+
+// NewSampleReaderAdapter creates a new sample reader adapter
+func NewSampleReaderAdapter(r SampleReader) *SampleReaderAdapter {
+	return &SampleReaderAdapter{r: r}
+}
+
+// ReadSamples implements the SamplesReader interface
+func (a *SampleReaderAdapter) ReadSamples(samples []int32) (n int, err error) {
+	for n < len(samples) {
+		if samples[n], err = a.r.ReadSample(); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
+
+// Start implements the Starter interface
+func (a *SampleReaderAdapter) Start() error {
+	if v, ok := a.r.(Starter); ok {
+		return v.Start()
+	}
+	return nil
+}
+
+// Stop implements the Starter interface
+func (a *SampleReaderAdapter) Stop() error {
+	if v, ok := a.r.(Starter); ok {
+		return v.Stop()
+	}
+	return nil
+}
+
+// timeouter represents an error that may be a timeout.
+// Readers waiting for samples that may never come should return such an error regularly.
+type timeouter interface {
+	Timeout() bool
+}
+
 // Starter represents an object capable of starting and stopping itself
 type Starter interface {
 	Start() error
@@ -27,21 +112,282 @@ type Starter interface {
 }
 
 // Options represents hearing options.
+// BitDepth, NumChannels and SampleRate describe the samples delivered by the reader.
 type Options struct {
-	WorkingDirectory string `toml:"working_directory"`
+	// Name of the brain hearing belongs to, stored alongside utterances
+	Brain            string               `toml:"-"`
+	BitDepth         int                  `toml:"bit_depth"`
+	Channels         ChannelsOptions      `toml:"channels"`
+	NumChannels      int                  `toml:"num_channels"`
+	Preprocessing    PreprocessingOptions `toml:"preprocessing"`
+	SampleRate       int                  `toml:"sample_rate"`
+	SpeechToText     SpeechToTextOptions  `toml:"speech_to_text"`
+	VAD              VADOptions           `toml:"vad"`
+	WakeWord         WakeWordOptions      `toml:"wake_word"`
+	WorkingDirectory string               `toml:"working_directory"`
 }
 
+// Default options
+const (
+	defaultBitDepth    = 32
+	defaultNumChannels = 1
+	defaultSampleRate  = 16000
+)
+
 // New creates a new hearing.
-func New(r SampleReader, o Options) *Hearing {
+func New(r SamplesReader, o Options) *Hearing {
+	// Default options
+	if o.BitDepth <= 0 {
+		o.BitDepth = defaultBitDepth
+	}
+	if o.NumChannels <= 0 {
+		o.NumChannels = defaultNumChannels
+	}
+	if o.SampleRate <= 0 {
+		o.SampleRate = defaultSampleRate
+	}
 	return &Hearing{
 		o: o,
 		r: r,
 	}
 }
 
+// SampleRate returns the sample rate of samples once preprocessed, which is the rate used by samples funcs,
+// utterances and speech to text
+func (h *Hearing) SampleRate() int {
+	if h.o.Preprocessing.SampleRate > 0 {
+		return h.o.Preprocessing.SampleRate
+	}
+	return h.o.SampleRate
+}
+
+// NumChannels returns the number of channels of samples once channels have been handled, which is the number of
+// channels used by samples funcs. Utterances are always mono.
+func (h *Hearing) NumChannels() int {
+	return h.o.Channels.numChannels(h.o.NumChannels)
+}
+
+// AddFilter adds a custom filter applied to samples after the preprocessing filters.
+// It must be called before Run.
+func (h *Hearing) AddFilter(f Filter) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.filters = append(h.filters, f)
+}
+
+// OnSamples adds a function executed on each chunk of samples read.
+// Samples must not be retained after the function returns.
+func (h *Hearing) OnSamples(fn SamplesFunc) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.samplesFuncs = append(h.samplesFuncs, fn)
+}
+
+// dispatchSamples executes samples funcs
+func (h *Hearing) dispatchSamples(samples []int32) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	for _, fn := range h.samplesFuncs {
+		fn(samples)
+	}
+}
+
+// OnUtterance adds a function executed on each utterance detected in the samples read.
+// Utterances are emitted once they're done, and their samples are not reused by hearing.
+func (h *Hearing) OnUtterance(fn UtteranceFunc) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.utteranceFuncs = append(h.utteranceFuncs, fn)
+}
+
+// dispatchUtterance executes utterance funcs
+func (h *Hearing) dispatchUtterance(u Utterance) {
+	astilog.Debugf("astihearing: utterance of %s detected at %s", u.Duration(), u.Start)
+	h.m.Lock()
+	defer h.m.Unlock()
+	for _, fn := range h.utteranceFuncs {
+		fn(u)
+	}
+}
+
+// SetSpeechToText sets the speech to text used on utterances, overriding the one created based on options
+func (h *Hearing) SetSpeechToText(s SpeechToText) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.stt = s
+}
+
+// speechToText returns the speech to text
+func (h *Hearing) speechToText() SpeechToText {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.stt
+}
+
+// OnTranscription adds a function executed on each utterance successfully converted to text
+func (h *Hearing) OnTranscription(fn TranscriptionFunc) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.transcriptionFuncs = append(h.transcriptionFuncs, fn)
+}
+
+// dispatchTranscription executes transcription funcs
+func (h *Hearing) dispatchTranscription(u Utterance, t Transcription) {
+	astilog.Debugf("astihearing: utterance detected at %s transcribed as \"%s\" with confidence %.2f", u.Start, t.Text, t.Confidence)
+	h.m.Lock()
+	defer h.m.Unlock()
+	for _, fn := range h.transcriptionFuncs {
+		fn(u, t)
+	}
+}
+
+// OnWakeWord adds a function executed each time the wake word is detected
+func (h *Hearing) OnWakeWord(fn WakeWordFunc) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.wakeWordFuncs = append(h.wakeWordFuncs, fn)
+}
+
+// dispatchWakeWord executes wake word funcs
+func (h *Hearing) dispatchWakeWord(u Utterance, distance float64) {
+	astilog.Debugf("astihearing: wake word detected at %s with distance %.2f", u.Start, distance)
+	h.m.Lock()
+	defer h.m.Unlock()
+	for _, fn := range h.wakeWordFuncs {
+		fn(u, distance)
+	}
+}
+
+// wakeWord returns the wake word
+func (h *Hearing) wakeWord() *wakeWord {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.ww
+}
+
+// gate checks whether an utterance must be passed to speech to text, enrolling it as a wake word sample if requested
+func (h *Hearing) gate(u Utterance) bool {
+	// No wake word
+	ww := h.wakeWord()
+	if ww == nil {
+		return true
+	}
+
+	// Enroll
+	if ww.enrolling() {
+		s, err := ww.add(u)
+		if err != nil {
+			astilog.Error(errors.Wrapf(err, "astihearing: enrolling utterance detected at %s failed", u.Start))
+			return false
+		}
+		astilog.Infof("astihearing: utterance detected at %s enrolled as wake word sample %s", u.Start, s.ID)
+		return false
+	}
+
+	// Wake word is disabled
+	if !h.o.WakeWord.Enabled {
+		return true
+	}
+
+	// Gate
+	pass, matched, distance := ww.gate(u)
+	if matched {
+		h.dispatchWakeWord(u, distance)
+	}
+	return pass
+}
+
+// transcribe converts utterances to text until the channel is closed.
+// Utterances speech to text fails on or transcribes with a low confidence are stored for later training.
+func (h *Hearing) transcribe(ctx context.Context, c chan Utterance) {
+	for u := range c {
+		// Utterance is not passed to speech to text
+		if !h.gate(u) {
+			continue
+		}
+
+		// No speech to text
+		s := h.speechToText()
+		if s == nil {
+			continue
+		}
+
+		// Speech to text
+		var attempts int
+		var err error
+		var t Transcription
+		for attempts < h.o.SpeechToText.maxAttempts() {
+			attempts++
+			if t, err = s.SpeechToText(ctx, u); err == nil || ctx.Err() != nil {
+				break
+			}
+		}
+
+		// Speech to text failed
+		if err != nil {
+			// Hearing is being stopped
+			if ctx.Err() != nil {
+				continue
+			}
+			astilog.Error(errors.Wrapf(err, "astihearing: speech to text on utterance detected at %s failed", u.Start))
+			h.storeUtterance(u, StoredUtterance{Attempts: attempts, Error: err.Error(), Status: StoredUtteranceStatusFailed})
+			continue
+		}
+
+		// Confidence is too low
+		if t.Confidence < h.o.SpeechToText.MinConfidence {
+			astilog.Debugf("astihearing: confidence %.2f of utterance detected at %s is too low", t.Confidence, u.Start)
+			h.storeUtterance(u, StoredUtterance{Attempts: attempts, Status: StoredUtteranceStatusLowConfidence, Transcription: &t})
+			continue
+		}
+
+		// Dispatch
+		h.dispatchTranscription(u, t)
+	}
+}
+
+// storeUtterance stores an utterance on disk
+func (h *Hearing) storeUtterance(u Utterance, i StoredUtterance) {
+	// No store
+	s := h.store()
+	if s == nil {
+		return
+	}
+
+	// Add
+	i.Brain = h.o.Brain
+	if _, err := s.add(u, i); err != nil {
+		astilog.Error(errors.Wrapf(err, "astihearing: storing utterance detected at %s failed", u.Start))
+		return
+	}
+}
+
+// store returns the utterances store
+func (h *Hearing) store() *store {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.s
+}
+
 // Close implements the io.Closer interface.
-func (h *Hearing) Close() error {
-	return nil
+// It releases the store, the wake word and the speech to text, which is closed if it implements io.Closer.
+func (h *Hearing) Close() (err error) {
+	// Release
+	h.m.Lock()
+	stt := h.stt
+	h.s = nil
+	h.stt = nil
+	h.ww = nil
+	h.m.Unlock()
+
+	// Close speech to text
+	if v, ok := stt.(io.Closer); ok {
+		if err = v.Close(); err != nil {
+			err = errors.Wrap(err, "astihearing: closing speech to text failed")
+			return
+		}
+	}
+	return
 }
 
 // Init implements the astibob.Initializer interface.
@@ -52,12 +398,46 @@ func (h *Hearing) Init() (err error) {
 		err = errors.Wrapf(err, "astihearing: mkdirall %s failed", h.o.WorkingDirectory)
 		return
 	}
+
+	// Create utterances store
+	var s *store
+	if s, err = newStore(filepath.Join(h.o.WorkingDirectory, "utterances")); err != nil {
+		err = errors.Wrap(err, "astihearing: creating store failed")
+		return
+	}
+
+	// Create wake word
+	var ww *wakeWord
+	if ww, err = newWakeWord(filepath.Join(h.o.WorkingDirectory, "wake_word"), h.o.WakeWord); err != nil {
+		err = errors.Wrap(err, "astihearing: creating wake word failed")
+		return
+	}
+	h.m.Lock()
+	h.s = s
+	h.ww = ww
+	h.m.Unlock()
+
+	// Create speech to text
+	if len(h.o.SpeechToText.Backend) > 0 && h.speechToText() == nil {
+		var stt SpeechToText
+		if stt, err = NewSpeechToText(h.o.SpeechToText); err != nil {
+			err = errors.Wrap(err, "astihearing: creating speech to text failed")
+			return
+		}
+		h.SetSpeechToText(stt)
+	}
 	return
 }
 
 // Run implements the astibob.Ability interface
 // TODO Fix when running after having switched it off
 func (h *Hearing) Run(ctx context.Context) (err error) {
+	// Validate channels options
+	if err = h.o.Channels.validate(h.o.NumChannels); err != nil {
+		err = errors.Wrap(err, "astihearing: validating channels options failed")
+		return
+	}
+
 	// Start and stop the reader
 	if v, ok := h.r.(Starter); ok {
 		// Start the reader
@@ -76,8 +456,82 @@ func (h *Hearing) Run(ctx context.Context) (err error) {
 		}()
 	}
 
+	// Convert utterances to text in the background so that reading is not blocked
+	var wg = &sync.WaitGroup{}
+	var utterances = make(chan Utterance, utterancesBufferSize)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.transcribe(ctx, utterances)
+	}()
+
+	// Create preprocessor
+	// Filters are stateful, therefore they're created on each run
+	h.m.Lock()
+	p := newPreprocessor(h.o.BitDepth, h.o.NumChannels, h.o.SampleRate, h.o.Preprocessing, h.filters)
+	h.m.Unlock()
+
+	// Create voice activity detectors
+	// There's one detector per channel in split mode, and a single mono one otherwise.
+	// Utterance timestamps are relative to the moment the reader has been started
+	var vs = make([]*vad, h.o.Channels.numChannels(h.o.NumChannels))
+	for idx := range vs {
+		var channel = idx
+		if h.o.Channels.Mode == ChannelModeSelect {
+			channel = h.o.Channels.Channel
+		}
+		vs[idx] = newVAD(h.o.BitDepth, 1, h.SampleRate(), h.o.VAD, func(u Utterance) {
+			// Dispatch
+			u.Channel = channel
+			h.dispatchUtterance(u)
+
+			// Queue for speech to text
+			select {
+			case utterances <- u:
+			default:
+				astilog.Warnf("astihearing: speech to text is too slow, dropping utterance detected at %s", u.Start)
+			}
+		})
+	}
+
+	// Flush the last utterances and wait for speech to text to be done
+	defer func() {
+		for _, v := range vs {
+			v.flush()
+		}
+		close(utterances)
+		wg.Wait()
+	}()
+
+	// Preprocess samples, handle channels, dispatch samples and split them in utterances
+	d := newDeinterleaver(h.o.NumChannels, h.o.Channels)
+	process := func(chunk []int32) {
+		// Preprocess
+		ss := p.process(chunk)
+		if len(ss) == 0 {
+			return
+		}
+
+		// Channels are split
+		if h.o.Channels.Mode == ChannelModeSplit {
+			h.dispatchSamples(ss)
+			for c, cs := range d.split(ss) {
+				vs[c].write(cs)
+			}
+			return
+		}
+
+		// Channels are mixed
+		m := d.toMono(ss)
+		h.dispatchSamples(m)
+		vs[0].write(m)
+	}
+
 	// Read
-	var s int32
+	// Chunks are filled across reads so that they always contain whole frames
+	var chunk = make([]int32, samplesChunkSize*h.o.NumChannels)
+	var n, c int
+	atomic.StoreInt64(&h.lastSampleAt, time.Now().UnixNano())
 	for {
 		// Check context
 		if err = ctx.Err(); err != nil {
@@ -85,17 +539,50 @@ func (h *Hearing) Run(ctx context.Context) (err error) {
 			return
 		}
 
-		// Read sample
-		if s, err = h.r.ReadSample(); err != nil {
-			err = errors.Wrap(err, "astihearing: reading sample failed")
+		// Read samples
+		if v, ok := h.r.(ContextSamplesReader); ok {
+			c, err = v.ReadSamplesContext(ctx, chunk[n:])
+		} else {
+			c, err = h.r.ReadSamples(chunk[n:])
+		}
+		if c > 0 {
+			atomic.StoreInt64(&h.lastSampleAt, time.Now().UnixNano())
+			if n += c; n == len(chunk) {
+				process(chunk)
+				n = 0
+			}
+		}
+
+		// Check error
+		if err != nil {
+			// Reader has no sample available yet, we give the context a chance to be checked
+			if v, ok := err.(timeouter); ok && v.Timeout() {
+				continue
+			}
+
+			// Process remaining samples so that none of them is lost
+			if n > 0 {
+				process(chunk[:n])
+			}
+
+			// Reader has no more samples: last utterances are flushed and speech to text is waited for when returning
+			if errors.Cause(err) == io.EOF {
+				astilog.Debug("astihearing: reader has no more samples")
+				err = nil
+				return
+			}
+			err = errors.Wrap(err, "astihearing: reading samples failed")
 			return
 		}
-		_ = s
+	}
+}
 
-		// TODO Split in smart chunks depending on audio level
-		// TODO Speech to text
-		// TODO If success, send in channel
-		// TODO If failure, store on disk for future use
+// CheckHealth implements the astibrain.HealthChecker interface.
+// Hearing is healthy as long as the sample reader is still delivering data.
+func (h *Hearing) CheckHealth(ctx context.Context) (err error) {
+	if d := time.Since(time.Unix(0, atomic.LoadInt64(&h.lastSampleAt))); d > maxSampleDelay {
+		err = fmt.Errorf("astihearing: no sample has been read for %s", d)
+		return
 	}
 	return
 }
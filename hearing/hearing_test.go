@@ -0,0 +1,171 @@
+package astihearing
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockedSampleReader struct {
+	samples []int32
+}
+
+func (r *mockedSampleReader) ReadSample() (s int32, err error) {
+	if len(r.samples) == 0 {
+		err = io.EOF
+		return
+	}
+	s = r.samples[0]
+	r.samples = r.samples[1:]
+	return
+}
+
+func TestHearingRun(t *testing.T) {
+	// Reader
+	var samples = make([]int32, 3*samplesChunkSize/2)
+	for i := range samples {
+		samples[i] = int32(i)
+	}
+	r := NewSampleReaderAdapter(&mockedSampleReader{samples: samples})
+
+	// Adapter
+	var b = make([]int32, 2)
+	n, err := r.ReadSamples(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int32{0, 1}, b)
+
+	// Run
+	h := New(r, Options{})
+	var o []int32
+	var sizes []int
+	h.OnSamples(func(samples []int32) {
+		o = append(o, samples...)
+		sizes = append(sizes, len(samples))
+	})
+	assert.NoError(t, h.Run(context.Background()))
+	assert.Equal(t, []int{samplesChunkSize, samplesChunkSize/2 - 2}, sizes)
+	assert.Equal(t, samples[2:], o)
+}
+
+type mockedClosingSpeechToText struct {
+	closed bool
+}
+
+func (s *mockedClosingSpeechToText) SpeechToText(ctx context.Context, u Utterance) (Transcription, error) {
+	return Transcription{}, nil
+}
+
+func (s *mockedClosingSpeechToText) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestHearingClose(t *testing.T) {
+	h := New(NewSampleReaderAdapter(&mockedSampleReader{}), Options{})
+	s := &mockedClosingSpeechToText{}
+	h.SetSpeechToText(s)
+	assert.NoError(t, h.Close())
+	assert.True(t, s.closed)
+	assert.Nil(t, h.speechToText())
+}
+
+type mockedSamplesReader struct {
+	samples []int32
+}
+
+func (r *mockedSamplesReader) ReadSamples(samples []int32) (n int, err error) {
+	if len(r.samples) == 0 {
+		err = io.EOF
+		return
+	}
+	n = copy(samples, r.samples)
+	r.samples = r.samples[n:]
+	return
+}
+
+type mockedSpeechToText struct {
+	m  sync.Mutex
+	us []Utterance
+}
+
+func (s *mockedSpeechToText) SpeechToText(ctx context.Context, u Utterance) (Transcription, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.us = append(s.us, u)
+	return Transcription{Confidence: 1}, nil
+}
+
+func (s *mockedSpeechToText) utterances() []Utterance {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.us
+}
+
+func TestHearingRunEOF(t *testing.T) {
+	// Input ends while speech is going on
+	h := New(&mockedSamplesReader{samples: append(vadSamples(time.Second, 0), vadSamples(time.Second, 0.5)...)}, Options{BitDepth: 16, NumChannels: 1, SampleRate: 16000})
+	s := &mockedSpeechToText{}
+	h.SetSpeechToText(s)
+
+	// Last utterance is flushed and transcribed before returning
+	assert.NoError(t, h.Run(context.Background()))
+	assert.Len(t, s.utterances(), 1)
+}
+
+func TestHearingRunSplitWakeWord(t *testing.T) {
+	// Create hearing
+	dir, err := ioutil.TempDir("", "astihearing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	h := New(&mockedSamplesReader{}, Options{
+		BitDepth:         16,
+		Channels:         ChannelsOptions{Mode: ChannelModeSplit},
+		NumChannels:      2,
+		SampleRate:       16000,
+		WakeWord:         WakeWordOptions{Enabled: true},
+		WorkingDirectory: dir,
+	})
+	assert.NoError(t, h.Init())
+	defer h.Close()
+	s := &mockedSpeechToText{}
+	h.SetSpeechToText(s)
+
+	// Utterances are surrounded by the VAD's pre roll and hangover
+	heard := func(u Utterance) Utterance {
+		u.Samples = append(append(vadSamples(100*time.Millisecond, 0), u.Samples...), vadSamples(300*time.Millisecond, 0)...)
+		return u
+	}
+	wake, command := heard(chirp(300, 3000, 0.1)), heard(chirp(3000, 300, 0.1))
+
+	// Enroll wake word
+	ww := h.wakeWord()
+	_, err = ww.add(heard(chirp(300, 3000, 0)))
+	assert.NoError(t, err)
+	ww.o.Threshold = (ww.match(wake) + ww.match(command)) / 2
+
+	// Both channels hear the wake word followed by a command
+	var mono = vadSamples(time.Second, 0)
+	mono = append(mono, chirp(300, 3000, 0.1).Samples...)
+	mono = append(mono, vadSamples(1500*time.Millisecond, 0)...)
+	mono = append(mono, chirp(3000, 300, 0.1).Samples...)
+	mono = append(mono, vadSamples(1500*time.Millisecond, 0)...)
+	r := &mockedSamplesReader{}
+	for _, v := range mono {
+		r.samples = append(r.samples, v, v)
+	}
+	h.r = r
+	// Each channel's command is transcribed
+	assert.NoError(t, h.Run(context.Background()))
+	us := s.utterances()
+	if assert.Len(t, us, 2) {
+		assert.Equal(t, 0, us[0].Channel)
+		assert.Equal(t, 1, us[1].Channel)
+	}
+}
@@ -0,0 +1,203 @@
+package astihearing
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// MFCC parameters
+const (
+	mfccFrameDuration   = 0.025 // In seconds
+	mfccHopDuration     = 0.01  // In seconds
+	mfccNumCoefficients = 13
+	mfccNumFilters      = 26
+	mfccPreEmphasis     = 0.97
+	mfccSampleRate      = 16000
+)
+
+// mfcc computes the mel frequency cepstral coefficients of an utterance.
+// The utterance is downmixed and resampled first so that features of utterances with different formats can be
+// compared, the first coefficient is dropped so that features don't depend on the level, and cepstral mean
+// normalization is applied so that they depend less on the microphone.
+func mfcc(u Utterance) (o [][]float64) {
+	// Get mono samples
+	var numChannels = u.NumChannels
+	if numChannels <= 0 {
+		numChannels = 1
+	}
+	var samples = make([]int32, len(u.Samples)/numChannels)
+	for i := range samples {
+		var s int64
+		for c := 0; c < numChannels; c++ {
+			s += int64(u.Samples[i*numChannels+c])
+		}
+		samples[i] = int32(s / int64(numChannels))
+	}
+
+	// Resample
+	samples = resample(samples, u.BitDepth, 1, u.SampleRate, mfccSampleRate)
+
+	// Normalize and pre-emphasize
+	var max = math.Pow(2, float64(u.BitDepth-1))
+	var x = make([]float64, len(samples))
+	for i, s := range samples {
+		x[i] = float64(s) / max
+		if i > 0 {
+			x[i] -= mfccPreEmphasis * float64(samples[i-1]) / max
+		}
+	}
+
+	// Create window and filter bank
+	frameSize := int(mfccFrameDuration * mfccSampleRate)
+	hopSize := int(mfccHopDuration * mfccSampleRate)
+	fftSize := 1
+	for fftSize < frameSize {
+		fftSize <<= 1
+	}
+	window := make([]float64, frameSize)
+	for i := range window {
+		window[i] = 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+	filters := melFilterBank(mfccNumFilters, fftSize, mfccSampleRate)
+
+	// Loop through frames
+	buf := make([]complex128, fftSize)
+	energies := make([]float64, mfccNumFilters)
+	for start := 0; start+frameSize <= len(x); start += hopSize {
+		// Window
+		for i := range buf {
+			buf[i] = 0
+			if i < frameSize {
+				buf[i] = complex(x[start+i]*window[i], 0)
+			}
+		}
+
+		// FFT
+		fft(buf)
+
+		// Apply filter bank on power spectrum
+		for f, filter := range filters {
+			var e float64
+			for k, w := range filter {
+				if w > 0 {
+					a := cmplx.Abs(buf[k])
+					e += w * a * a / float64(fftSize)
+				}
+			}
+			energies[f] = math.Log(math.Max(e, 1e-10))
+		}
+
+		// DCT
+		c := make([]float64, mfccNumCoefficients-1)
+		for n := 1; n < mfccNumCoefficients; n++ {
+			var v float64
+			for f, e := range energies {
+				v += e * math.Cos(math.Pi*float64(n)*(float64(f)+0.5)/float64(mfccNumFilters))
+			}
+			c[n-1] = v
+		}
+		o = append(o, c)
+	}
+
+	// Cepstral mean normalization
+	if len(o) == 0 {
+		return
+	}
+	mean := make([]float64, mfccNumCoefficients-1)
+	for _, c := range o {
+		for i, v := range c {
+			mean[i] += v / float64(len(o))
+		}
+	}
+	for _, c := range o {
+		for i := range c {
+			c[i] -= mean[i]
+		}
+	}
+	return
+}
+
+// melFilterBank creates triangular filters evenly spaced on the mel scale, indexed by FFT bin
+func melFilterBank(numFilters, fftSize, sampleRate int) (o [][]float64) {
+	// Get filter edges
+	hzToMel := func(f float64) float64 { return 2595 * math.Log10(1+f/700) }
+	melToHz := func(m float64) float64 { return 700 * (math.Pow(10, m/2595) - 1) }
+	maxMel := hzToMel(float64(sampleRate) / 2)
+	bins := make([]int, numFilters+2)
+	for i := range bins {
+		bins[i] = int(math.Floor(float64(fftSize+1) * melToHz(maxMel*float64(i)/float64(numFilters+1)) / float64(sampleRate)))
+	}
+
+	// Create filters
+	for f := 1; f <= numFilters; f++ {
+		filter := make([]float64, fftSize/2+1)
+		for k := bins[f-1]; k < bins[f]; k++ {
+			filter[k] = float64(k-bins[f-1]) / float64(bins[f]-bins[f-1])
+		}
+		for k := bins[f]; k < bins[f+1] && k < len(filter); k++ {
+			filter[k] = float64(bins[f+1]-k) / float64(bins[f+1]-bins[f])
+		}
+		o = append(o, filter)
+	}
+	return
+}
+
+// fft computes an in place radix-2 fast Fourier transform. The length of x must be a power of 2.
+func fft(x []complex128) {
+	// Bit reversal permutation
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	// Butterflies
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], wk*x[start+k+size/2]
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// dtw returns the dynamic time warping distance between two sequences of features, normalized by the sum of their
+// lengths so that distances of sequences of different lengths can be compared
+func dtw(a, b [][]float64) float64 {
+	// Empty sequence
+	if len(a) == 0 || len(b) == 0 {
+		return math.Inf(1)
+	}
+
+	// Only keep 2 rows of the cost matrix
+	prev := make([]float64, len(b)+1)
+	cur := make([]float64, len(b)+1)
+	for j := range prev {
+		prev[j] = math.Inf(1)
+	}
+	prev[0] = 0
+	for i := 1; i <= len(a); i++ {
+		cur[0] = math.Inf(1)
+		for j := 1; j <= len(b); j++ {
+			// Euclidean distance
+			var d float64
+			for k := range a[i-1] {
+				v := a[i-1][k] - b[j-1][k]
+				d += v * v
+			}
+			cur[j] = math.Sqrt(d) + math.Min(prev[j-1], math.Min(prev[j], cur[j-1]))
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)] / float64(len(a)+len(b))
+}
@@ -0,0 +1,376 @@
+package astihearing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// File formats
+const (
+	FileFormatPCM = "pcm"
+	FileFormatWAV = "wav"
+)
+
+// stdinPath is the path designating stdin
+const stdinPath = "-"
+
+// PCMFormat represents the format of raw PCM samples
+type PCMFormat struct {
+	BitDepth    int `toml:"bit_depth"`
+	NumChannels int `toml:"num_channels"`
+	SampleRate  int `toml:"sample_rate"`
+}
+
+// validate validates the format
+func (f PCMFormat) validate() error {
+	if f.BitDepth%8 != 0 || f.BitDepth <= 0 || f.BitDepth > 32 || f.NumChannels <= 0 || f.SampleRate <= 0 {
+		return fmt.Errorf("astihearing: invalid pcm format %+v", f)
+	}
+	return nil
+}
+
+// ReaderOptions represents PCM reader options
+type ReaderOptions struct {
+	// Samples are read again from the beginning once done, which requires the underlying reader to be an io.Seeker
+	Loop bool `toml:"loop"`
+	// Samples are delivered at the pace they would be captured at instead of as fast as possible
+	RealTime bool `toml:"real_time"`
+}
+
+// FileReaderOptions represents file reader options
+type FileReaderOptions struct {
+	// Defaults to wav if the path has a .wav extension, pcm otherwise
+	Format string `toml:"format"`
+	Loop   bool   `toml:"loop"`
+	// Format of raw PCM files, wav files carry their own
+	PCM      PCMFormat `toml:"pcm"`
+	Path     string    `toml:"path"` // "-" reads stdin
+	RealTime bool      `toml:"real_time"`
+}
+
+// PCMReader reads little endian signed PCM samples from a reader such as a raw PCM file, the data chunk of a wav
+// file or stdin.
+// It implements the SampleReader, SamplesReader and Starter interfaces.
+type PCMReader struct {
+	b         []byte
+	c         io.Closer
+	f         PCMFormat
+	lapRead   int64 // Number of bytes read since the last loop
+	o         ReaderOptions
+	offset    int64 // Offset of the first sample, used to loop
+	pending   []byte
+	r         io.Reader
+	read      int64 // Number of samples delivered since start, used to pace
+	remaining int64 // Number of bytes left to read, negative when unknown
+	size      int64 // Number of data bytes, negative when unknown
+	startedAt time.Time
+}
+
+// NewPCMReader creates a new raw PCM reader
+func NewPCMReader(r io.Reader, f PCMFormat, o ReaderOptions) (p *PCMReader, err error) {
+	// Validate format
+	if err = f.validate(); err != nil {
+		return
+	}
+
+	// Create reader
+	p = &PCMReader{
+		f:         f,
+		o:         o,
+		r:         r,
+		remaining: -1,
+		size:      -1,
+	}
+
+	// Get offset
+	if err = p.seekable(); err != nil {
+		err = errors.Wrap(err, "astihearing: checking reader is seekable failed")
+		return
+	}
+	return
+}
+
+// NewWAVReader creates a new PCM reader reading the data chunk of a wav file
+func NewWAVReader(r io.Reader, o ReaderOptions) (p *PCMReader, err error) {
+	// Read header
+	var f PCMFormat
+	var dataSize uint32
+	if f.BitDepth, f.NumChannels, f.SampleRate, dataSize, err = readWAVHeader(r); err != nil {
+		err = errors.Wrap(err, "astihearing: reading wav header failed")
+		return
+	}
+
+	// Create reader
+	if p, err = NewPCMReader(r, f, o); err != nil {
+		err = errors.Wrap(err, "astihearing: creating pcm reader failed")
+		return
+	}
+
+	// Streamed wav files don't know their size in advance and usually set it to 0 or the max value
+	if dataSize > 0 && dataSize < 0xffffffff {
+		p.size = int64(dataSize)
+		p.remaining = p.size
+	}
+	return
+}
+
+// NewFileReader creates a new PCM reader based on file reader options.
+// The reader must be closed once done.
+func NewFileReader(o FileReaderOptions) (p *PCMReader, err error) {
+	// Get format
+	var format = o.Format
+	if len(format) == 0 {
+		format = FileFormatPCM
+		if strings.ToLower(filepath.Ext(o.Path)) == ".wav" {
+			format = FileFormatWAV
+		}
+	}
+
+	// Open file
+	var f *os.File
+	if o.Path == stdinPath {
+		f = os.Stdin
+	} else if f, err = os.Open(o.Path); err != nil {
+		err = errors.Wrapf(err, "astihearing: opening %s failed", o.Path)
+		return
+	}
+
+	// Create reader
+	var ro = ReaderOptions{
+		Loop:     o.Loop,
+		RealTime: o.RealTime,
+	}
+	switch format {
+	case FileFormatPCM:
+		p, err = NewPCMReader(f, o.PCM, ro)
+	case FileFormatWAV:
+		p, err = NewWAVReader(f, ro)
+	default:
+		err = fmt.Errorf("astihearing: unknown file format %s", format)
+	}
+	if err != nil {
+		if f != os.Stdin {
+			f.Close()
+		}
+		err = errors.Wrapf(err, "astihearing: creating reader for %s failed", o.Path)
+		return
+	}
+
+	// Stdin is not closed
+	if f != os.Stdin {
+		p.c = f
+	}
+	return
+}
+
+// seekable stores the offset of the first sample if looping is enabled
+func (p *PCMReader) seekable() (err error) {
+	// No need to seek
+	if !p.o.Loop {
+		return
+	}
+
+	// Reader can't seek
+	s, ok := p.r.(io.Seeker)
+	if !ok {
+		err = errors.New("astihearing: looping requires an io.Seeker")
+		return
+	}
+
+	// Get offset
+	if p.offset, err = s.Seek(0, io.SeekCurrent); err != nil {
+		err = errors.Wrap(err, "astihearing: getting offset failed")
+		return
+	}
+	return
+}
+
+// Format returns the format of the samples
+func (p *PCMReader) Format() PCMFormat {
+	return p.f
+}
+
+// Close implements the io.Closer interface
+func (p *PCMReader) Close() (err error) {
+	if p.c != nil {
+		if err = p.c.Close(); err != nil {
+			err = errors.Wrap(err, "astihearing: closing reader failed")
+			return
+		}
+	}
+	return
+}
+
+// Start implements the Starter interface.
+// It resets pacing.
+func (p *PCMReader) Start() error {
+	p.read = 0
+	p.startedAt = time.Now()
+	return nil
+}
+
+// Stop implements the Starter interface
+func (p *PCMReader) Stop() error {
+	return nil
+}
+
+// ReadSamples implements the SamplesReader interface.
+// It returns io.EOF once all samples have been read, unless looping is enabled.
+func (p *PCMReader) ReadSamples(samples []int32) (n int, err error) {
+	return p.ReadSamplesContext(context.Background(), samples)
+}
+
+// ReadSamplesContext implements the ContextSamplesReader interface.
+// Pacing stops as soon as the context is done.
+func (p *PCMReader) ReadSamplesContext(ctx context.Context, samples []int32) (n int, err error) {
+	// Read bytes
+	var width = p.f.BitDepth / 8
+	var b []byte
+	if b, err = p.readBytes(len(samples)*width, width); err != nil {
+		return
+	}
+
+	// Decode samples
+	// Bytes of an incomplete sample are kept for next read
+	n = len(b) / width
+	if err = decodeSamples(samples[:n], b, p.f.BitDepth); err != nil {
+		err = errors.Wrap(err, "astihearing: decoding samples failed")
+		return
+	}
+	p.pending = append(p.pending[:0], b[n*width:]...)
+
+	// Pace
+	if err = p.pace(ctx, n); err != nil {
+		err = errors.Wrap(err, "astihearing: pacing failed")
+		return
+	}
+	return
+}
+
+// readBytes reads at most n bytes, including pending bytes, and loops if needed
+func (p *PCMReader) readBytes(n, width int) (b []byte, err error) {
+	// Prepare buffer
+	if cap(p.b) < n {
+		p.b = make([]byte, n)
+	}
+	b = p.b[:n]
+	var m = copy(b, p.pending)
+
+	// Limit to data
+	if p.remaining >= 0 && int64(n-m) > p.remaining {
+		b = b[:m+int(p.remaining)]
+	}
+
+	// Read
+	var c int
+	if m < len(b) {
+		c, err = p.r.Read(b[m:])
+		p.lapRead += int64(c)
+		if p.remaining >= 0 {
+			p.remaining -= int64(c)
+		}
+		if err == nil && p.remaining == 0 {
+			err = io.EOF
+		}
+	} else if p.remaining == 0 {
+		err = io.EOF
+	}
+	b = b[:m+c]
+
+	// Handle EOF
+	if err == io.EOF {
+		// Return what has been read so far, EOF will be returned on next read
+		if len(b) >= width {
+			err = nil
+			return
+		}
+
+		// Bytes of an incomplete sample are dropped
+		b = b[:0]
+		p.pending = p.pending[:0]
+
+		// Loop
+		if p.o.Loop {
+			if err = p.loop(); err != nil {
+				err = errors.Wrap(err, "astihearing: looping failed")
+				return
+			}
+			return
+		}
+		return
+	} else if err != nil {
+		err = errors.Wrap(err, "astihearing: reading failed")
+		return
+	}
+	return
+}
+
+// loop seeks back to the first sample
+func (p *PCMReader) loop() (err error) {
+	// Empty data would loop forever
+	if p.lapRead == 0 {
+		err = io.EOF
+		return
+	}
+
+	// Seek
+	astilog.Debug("astihearing: looping pcm reader")
+	if _, err = p.r.(io.Seeker).Seek(p.offset, io.SeekStart); err != nil {
+		err = errors.Wrap(err, "astihearing: seeking failed")
+		return
+	}
+	p.lapRead = 0
+	p.remaining = p.size
+	return
+}
+
+// pace blocks until n more samples would have been captured in real time or until the context is done
+func (p *PCMReader) pace(ctx context.Context, n int) (err error) {
+	// No need to pace
+	if !p.o.RealTime {
+		return
+	}
+
+	// Reader has not been started
+	if p.startedAt.IsZero() {
+		p.startedAt = time.Now()
+	}
+
+	// Get delay
+	p.read += int64(n)
+	d := time.Until(p.startedAt.Add(time.Duration(p.read) * time.Second / time.Duration(p.f.NumChannels*p.f.SampleRate)))
+	if d <= 0 {
+		return
+	}
+
+	// Sleep
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "astihearing: context error")
+	}
+	return
+}
+
+// ReadSample implements the SampleReader interface
+func (p *PCMReader) ReadSample() (s int32, err error) {
+	var b [1]int32
+	for {
+		var n int
+		if n, err = p.ReadSamples(b[:]); err != nil || n > 0 {
+			break
+		}
+	}
+	s = b[0]
+	return
+}
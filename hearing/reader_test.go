@@ -0,0 +1,90 @@
+package astihearing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPCMReader(t *testing.T) {
+	// Create wav file
+	dir, err := ioutil.TempDir("", "astihearing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var buf = &bytes.Buffer{}
+	assert.NoError(t, writeWAV(buf, Utterance{BitDepth: 16, NumChannels: 1, SampleRate: 1000, Samples: []int32{1, -2, 3, -4, 5}}))
+	var path = filepath.Join(dir, "test.wav")
+	assert.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	// Wav file
+	r, err := NewFileReader(FileReaderOptions{Path: path})
+	assert.NoError(t, err)
+	assert.Equal(t, PCMFormat{BitDepth: 16, NumChannels: 1, SampleRate: 1000}, r.Format())
+	var ss = make([]int32, 3)
+	n, err := r.ReadSamples(ss)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{1, -2, 3}, ss[:n])
+	n, err = r.ReadSamples(ss)
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{-4, 5}, ss[:n])
+	_, err = r.ReadSamples(ss)
+	assert.Equal(t, io.EOF, errors.Cause(err))
+	assert.NoError(t, r.Close())
+
+	// Loop
+	r, err = NewFileReader(FileReaderOptions{Loop: true, Path: path})
+	assert.NoError(t, err)
+	var o []int32
+	for len(o) < 12 {
+		n, err = r.ReadSamples(ss)
+		assert.NoError(t, err)
+		o = append(o, ss[:n]...)
+	}
+	assert.Equal(t, []int32{1, -2, 3, -4, 5, 1, -2, 3, -4, 5, 1, -2}, o[:12])
+	assert.NoError(t, r.Close())
+
+	// Raw PCM with a sample split across reads and an incomplete trailing sample
+	r, err = NewPCMReader(io.MultiReader(bytes.NewReader([]byte{1, 0, 0xfe}), bytes.NewReader([]byte{0xff, 3})), PCMFormat{BitDepth: 16, NumChannels: 1, SampleRate: 1000}, ReaderOptions{})
+	assert.NoError(t, err)
+	o = []int32{}
+	for {
+		if n, err = r.ReadSamples(ss); err != nil {
+			break
+		}
+		o = append(o, ss[:n]...)
+	}
+	assert.Equal(t, io.EOF, errors.Cause(err))
+	assert.Equal(t, []int32{1, -2}, o)
+
+	// Looping requires a seeker
+	_, err = NewPCMReader(&bytes.Buffer{}, PCMFormat{BitDepth: 16, NumChannels: 1, SampleRate: 1000}, ReaderOptions{Loop: true})
+	assert.Error(t, err)
+
+	// Real time
+	r, err = NewPCMReader(bytes.NewReader(make([]byte, 200)), PCMFormat{BitDepth: 16, NumChannels: 2, SampleRate: 1000}, ReaderOptions{RealTime: true})
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+	var start = time.Now()
+	_, err = r.ReadSamples(make([]int32, 100))
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// Real time pacing stops with the context
+	r, err = NewPCMReader(bytes.NewReader(make([]byte, 4000)), PCMFormat{BitDepth: 16, NumChannels: 1, SampleRate: 1000}, ReaderOptions{RealTime: true})
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = r.ReadSamplesContext(ctx, make([]int32, 2000))
+	assert.Equal(t, context.DeadlineExceeded, errors.Cause(err))
+	assert.True(t, time.Since(start) < time.Second)
+}
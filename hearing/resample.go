@@ -0,0 +1,139 @@
+package astihearing
+
+import (
+	"math"
+)
+
+// Resampler constants
+const (
+	// Fraction of the Nyquist frequency of the lowest sample rate that is kept, the rest being the transition band
+	resamplerRolloff = 0.9
+	// Number of zero crossings of the sinc on each side of the kernel, the more the sharper the transition band
+	resamplerZeroCrossings = 16
+)
+
+// resample resamples interleaved samples from a sample rate to another
+func resample(samples []int32, bitDepth, numChannels, from, to int) []int32 {
+	// Nothing to do
+	if from == to || from <= 0 || to <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	// Resample
+	r := newResampler(math.Pow(2, float64(bitDepth-1)), numChannels, from, to)
+	return append(r.Filter(samples), r.flush()...)
+}
+
+// resampler resamples a stream of interleaved samples with a Blackman windowed-sinc kernel whose cutoff frequency is
+// below the Nyquist frequency of both sample rates, which prevents aliasing when downsampling.
+// Unlike other filters, it changes the number of samples and delays them by half the kernel width.
+// Positions are computed from frame counts rather than accumulated so that they don't depend on how the stream is
+// chunked.
+type resampler struct {
+	buf         []float64 // Interleaved input frames still needed
+	cutoff      float64   // In cycles per input frame
+	from, to    int64
+	halfWidth   int // In input frames
+	max         float64
+	numChannels int
+	offset      int64     // Index of the first frame of buf in the input
+	out         int64     // Number of output frames
+	weights     []float64 // Kernel weights of the current output frame
+}
+
+// newResampler creates a new resampler
+func newResampler(max float64, numChannels, from, to int) (r *resampler) {
+	r = &resampler{
+		cutoff:      resamplerRolloff * 0.5 * math.Min(1, float64(to)/float64(from)),
+		from:        int64(from),
+		max:         max,
+		numChannels: numChannels,
+		to:          int64(to),
+	}
+	r.halfWidth = int(math.Ceil(resamplerZeroCrossings / (2 * r.cutoff)))
+	r.weights = make([]float64, 2*r.halfWidth)
+
+	// Input is preceded by silence so that the first output frame is aligned with the first input frame
+	r.buf = make([]float64, r.halfWidth*numChannels)
+	r.offset = -int64(r.halfWidth)
+	return
+}
+
+// Filter implements the Filter interface
+func (r *resampler) Filter(samples []int32) []int32 {
+	for _, s := range samples {
+		r.buf = append(r.buf, float64(s))
+	}
+	return r.process(math.Inf(1))
+}
+
+// flush returns the output frames delayed by the kernel, as if the input was followed by silence
+func (r *resampler) flush() []int32 {
+	end := float64(r.offset + int64(len(r.buf)/r.numChannels))
+	r.buf = append(r.buf, make([]float64, r.halfWidth*r.numChannels)...)
+	return r.process(end)
+}
+
+// position returns the position of the next output frame in the input, split in its integer and fractional parts
+func (r *resampler) position() (int64, float64) {
+	p := r.out * r.from
+	return p / r.to, float64(p%r.to) / float64(r.to)
+}
+
+// process computes the output frames positioned before end in the input whose kernel only needs available input
+// frames
+func (r *resampler) process(end float64) (o []int32) {
+	// Loop through output frames
+	n := len(r.buf) / r.numChannels
+	for {
+		// Get position
+		j, f := r.position()
+		if float64(j)+f >= end || int(j-r.offset)+r.halfWidth >= n {
+			break
+		}
+
+		// Compute weights
+		first := int(j-r.offset) - r.halfWidth + 1
+		for k := range r.weights {
+			d := f + float64(r.halfWidth-1-k)
+			r.weights[k] = 2 * r.cutoff * sinc(2*r.cutoff*d) * blackman(d/float64(r.halfWidth))
+		}
+
+		// Convolve
+		for c := 0; c < r.numChannels; c++ {
+			var v float64
+			for k, w := range r.weights {
+				v += w * r.buf[(first+k)*r.numChannels+c]
+			}
+			o = append(o, clip(v, r.max))
+		}
+		r.out++
+	}
+
+	// Remove input frames that are not needed anymore
+	j, _ := r.position()
+	if k := int(j-r.offset) - r.halfWidth + 1; k > 0 {
+		if k > n {
+			k = n
+		}
+		r.buf = append(r.buf[:0], r.buf[k*r.numChannels:]...)
+		r.offset += int64(k)
+	}
+	return
+}
+
+// sinc returns the normalized sinc
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman returns the Blackman window for x between -1 and 1
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x)
+}
@@ -0,0 +1,250 @@
+package astihearing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Stored utterance statuses
+// Failed and low confidence utterances are set by hearing, junk and validated ones by operators labeling them.
+const (
+	StoredUtteranceStatusFailed        = "failed"
+	StoredUtteranceStatusJunk          = "junk"
+	StoredUtteranceStatusLowConfidence = "low_confidence"
+	StoredUtteranceStatusValidated     = "validated"
+)
+
+// StoredUtterance represents an utterance stored on disk for later training
+type StoredUtterance struct {
+	Attempts      int            `json:"attempts"`
+	Brain         string         `json:"brain"`
+	Channel       int            `json:"channel"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Duration      time.Duration  `json:"duration"`
+	Error         string         `json:"error,omitempty"`
+	ID            string         `json:"id"`
+	Start         time.Time      `json:"start"`
+	Status        string         `json:"status"`
+	Transcript    string         `json:"transcript,omitempty"` // Set by operators labeling the utterance
+	Transcription *Transcription `json:"transcription,omitempty"`
+}
+
+// store stores utterances as wav files in a directory, and indexes them in a json file
+type store struct {
+	dir   string
+	items map[string]*StoredUtterance
+	m     sync.Mutex // Locks items and the index file
+}
+
+// storeIndexName is the name of the index file
+const storeIndexName = "index.json"
+
+// newStore creates a new store and loads its index
+func newStore(dir string) (s *store, err error) {
+	// Create store
+	s = &store{
+		dir:   dir,
+		items: make(map[string]*StoredUtterance),
+	}
+
+	// Create directory
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = errors.Wrapf(err, "astihearing: mkdirall %s failed", dir)
+		return
+	}
+
+	// Read index
+	var b []byte
+	p := filepath.Join(dir, storeIndexName)
+	if b, err = ioutil.ReadFile(p); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = errors.Wrapf(err, "astihearing: reading %s failed", p)
+		return
+	}
+
+	// Unmarshal
+	var items []*StoredUtterance
+	if err = json.Unmarshal(b, &items); err != nil {
+		err = errors.Wrapf(err, "astihearing: unmarshaling %s failed", p)
+		return
+	}
+
+	// Index
+	for _, i := range items {
+		s.items[i.ID] = i
+	}
+	return
+}
+
+// wavPath returns the path of an utterance wav file
+func (s *store) wavPath(id string) string {
+	return filepath.Join(s.dir, id+".wav")
+}
+
+// list returns the stored utterances sorted by start
+func (s *store) list() (o []StoredUtterance) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	o = []StoredUtterance{}
+	for _, i := range s.items {
+		o = append(o, *i)
+	}
+	sort.Slice(o, func(i, j int) bool {
+		if o[i].Start.Equal(o[j].Start) {
+			return o[i].ID < o[j].ID
+		}
+		return o[i].Start.Before(o[j].Start)
+	})
+	return
+}
+
+// get returns a stored utterance
+func (s *store) get(id string) (o StoredUtterance, ok bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	var i *StoredUtterance
+	if i, ok = s.items[id]; ok {
+		o = *i
+	}
+	return
+}
+
+// add writes an utterance wav file and indexes it
+func (s *store) add(u Utterance, i StoredUtterance) (o StoredUtterance, err error) {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Update item
+	i.Channel = u.Channel
+	i.CreatedAt = time.Now()
+	i.Duration = u.Duration()
+	i.Start = u.Start
+	i.ID = strconv.FormatInt(u.Start.UnixNano(), 10)
+	for idx := 1; s.items[i.ID] != nil; idx++ {
+		i.ID = fmt.Sprintf("%d-%d", u.Start.UnixNano(), idx)
+	}
+
+	// Create file
+	var f *os.File
+	p := s.wavPath(i.ID)
+	if f, err = os.Create(p); err != nil {
+		err = errors.Wrapf(err, "astihearing: creating %s failed", p)
+		return
+	}
+	defer f.Close()
+
+	// Write wav
+	if err = writeWAV(f, u); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing wav in %s failed", p)
+		return
+	}
+
+	// Index
+	s.items[i.ID] = &i
+	if err = s.save(); err != nil {
+		delete(s.items, i.ID)
+		err = errors.Wrap(err, "astihearing: saving index failed")
+		return
+	}
+	return i, nil
+}
+
+// update updates a stored utterance
+func (s *store) update(id string, fn func(i *StoredUtterance) error) (o StoredUtterance, err error) {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Retrieve item
+	i, ok := s.items[id]
+	if !ok {
+		err = fmt.Errorf("astihearing: unknown utterance %s", id)
+		return
+	}
+
+	// Update a copy so that the item is left untouched on error
+	v := *i
+	if err = fn(&v); err != nil {
+		return
+	}
+
+	// Save index
+	s.items[id] = &v
+	if err = s.save(); err != nil {
+		s.items[id] = i
+		err = errors.Wrap(err, "astihearing: saving index failed")
+		return
+	}
+	return v, nil
+}
+
+// delete deletes a stored utterance
+func (s *store) delete(id string) (err error) {
+	// Lock
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// Retrieve item
+	i, ok := s.items[id]
+	if !ok {
+		err = fmt.Errorf("astihearing: unknown utterance %s", id)
+		return
+	}
+
+	// Update index
+	delete(s.items, id)
+	if err = s.save(); err != nil {
+		s.items[id] = i
+		err = errors.Wrap(err, "astihearing: saving index failed")
+		return
+	}
+
+	// Remove file
+	if err = os.Remove(s.wavPath(id)); err != nil && !os.IsNotExist(err) {
+		err = errors.Wrapf(err, "astihearing: removing %s failed", s.wavPath(id))
+		return
+	}
+	return nil
+}
+
+// save writes the index atomically. It assumes the lock is held.
+func (s *store) save() (err error) {
+	// Marshal
+	var items []*StoredUtterance
+	for _, i := range s.items {
+		items = append(items, i)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	var b []byte
+	if b, err = json.MarshalIndent(items, "", "  "); err != nil {
+		err = errors.Wrap(err, "astihearing: marshaling index failed")
+		return
+	}
+
+	// Write in a temporary file
+	p := filepath.Join(s.dir, storeIndexName)
+	if err = ioutil.WriteFile(p+".tmp", b, 0644); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing %s failed", p+".tmp")
+		return
+	}
+
+	// Rename
+	if err = os.Rename(p+".tmp", p); err != nil {
+		err = errors.Wrapf(err, "astihearing: renaming %s to %s failed", p+".tmp", p)
+		return
+	}
+	return
+}
@@ -0,0 +1,69 @@
+package astihearing
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astihearing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Add
+	s, err := newStore(dir)
+	assert.NoError(t, err)
+	u := Utterance{BitDepth: 16, NumChannels: 2, SampleRate: 16000, Samples: make([]int32, 3200), Start: time.Unix(10, 0)}
+	u.End = u.Start.Add(100 * time.Millisecond)
+	i1, err := s.add(u, StoredUtterance{Attempts: 2, Brain: "brain", Status: StoredUtteranceStatusFailed})
+	assert.NoError(t, err)
+	assert.Equal(t, "10000000000", i1.ID)
+	assert.Equal(t, 100*time.Millisecond, i1.Duration)
+	i2, err := s.add(u, StoredUtterance{Status: StoredUtteranceStatusLowConfidence})
+	assert.NoError(t, err)
+	assert.Equal(t, "10000000000-1", i2.ID)
+	fi, err := os.Stat(s.wavPath(i1.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(44+1600*2), fi.Size())
+
+	// Index is reloaded
+	s, err = newStore(dir)
+	assert.NoError(t, err)
+	l := s.list()
+	assert.Len(t, l, 2)
+	assert.Equal(t, i1.ID, l[0].ID)
+	assert.Equal(t, 2, l[0].Attempts)
+	assert.Equal(t, "brain", l[0].Brain)
+
+	// API
+	h := &Hearing{s: s}
+	rw := httptest.NewRecorder()
+	h.APIHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/utterances/"+i1.ID+"/wav", nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 44+1600*2, rw.Body.Len())
+	rw = httptest.NewRecorder()
+	h.APIHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodPatch, "/utterances/"+i2.ID, strings.NewReader(`{"status":"invalid"}`)))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	rw = httptest.NewRecorder()
+	h.APIHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodPatch, "/utterances/"+i2.ID, strings.NewReader(`{"status":"validated","transcript":" hello bob "}`)))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	i2, _ = s.get(i2.ID)
+	assert.Equal(t, StoredUtteranceStatusValidated, i2.Status)
+	assert.Equal(t, "hello bob", i2.Transcript)
+	rw = httptest.NewRecorder()
+	h.APIHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodDelete, "/utterances/"+i1.ID, nil))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	rw = httptest.NewRecorder()
+	h.APIHandler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/utterances/"+i1.ID, nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	_, err = os.Stat(s.wavPath(i1.ID))
+	assert.True(t, os.IsNotExist(err))
+	assert.Len(t, s.list(), 1)
+}
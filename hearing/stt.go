@@ -0,0 +1,181 @@
+package astihearing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// SpeechToText represents an object capable of converting an utterance to text
+type SpeechToText interface {
+	SpeechToText(ctx context.Context, u Utterance) (Transcription, error)
+}
+
+// Transcription represents the result of a speech to text analysis.
+// Confidences are between 0 and 1.
+type Transcription struct {
+	Alternatives []Alternative
+	Confidence   float64
+	Text         string
+}
+
+// Alternative represents an alternative transcription
+type Alternative struct {
+	Confidence float64
+	Text       string
+}
+
+// TranscriptionFunc represents a function executed on each transcription
+type TranscriptionFunc func(u Utterance, t Transcription)
+
+// Speech to text backends
+const (
+	SpeechToTextBackendDeepSpeech = "deepspeech"
+	SpeechToTextBackendVosk       = "vosk"
+	SpeechToTextBackendWhisper    = "whisper"
+)
+
+// SpeechToTextOptions represents speech to text options.
+// An empty backend disables speech to text.
+type SpeechToTextOptions struct {
+	// Extra arguments passed to the binary
+	Args       []string `toml:"args"`
+	Backend    string   `toml:"backend"`
+	BinaryPath string   `toml:"binary_path"`
+	// Only used by the whisper backend
+	Language string `toml:"language"`
+	// Number of times speech to text is tried on an utterance before being considered as failed
+	MaxAttempts int `toml:"max_attempts"`
+	// Transcriptions with a lower confidence are not dispatched and their utterance is stored for later training
+	MinConfidence   float64 `toml:"min_confidence"`
+	ModelPath       string  `toml:"model_path"`
+	NumAlternatives int     `toml:"num_alternatives"`
+	// Only used by the deepspeech backend
+	ScorerPath string        `toml:"scorer_path"`
+	Timeout    time.Duration `toml:"timeout"`
+}
+
+// Default speech to text options
+const (
+	defaultSpeechToTextNumAlternatives = 3
+	defaultSpeechToTextTimeout         = 30 * time.Second
+)
+
+// NewSpeechToText creates a new speech to text based on options
+func NewSpeechToText(o SpeechToTextOptions) (s SpeechToText, err error) {
+	// Default options
+	if o.NumAlternatives <= 0 {
+		o.NumAlternatives = defaultSpeechToTextNumAlternatives
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultSpeechToTextTimeout
+	}
+
+	// Check model
+	if len(o.ModelPath) == 0 {
+		err = fmt.Errorf("astihearing: no model path provided for speech to text backend %s", o.Backend)
+		return
+	}
+
+	// Switch on backend
+	switch o.Backend {
+	case SpeechToTextBackendDeepSpeech:
+		s = newDeepSpeech(o)
+	case SpeechToTextBackendVosk:
+		s = newVosk(o)
+	case SpeechToTextBackendWhisper:
+		s = newWhisper(o)
+	default:
+		err = fmt.Errorf("astihearing: unknown speech to text backend %s", o.Backend)
+		return
+	}
+	return
+}
+
+// binaryPath returns the binary path or a default value
+func (o SpeechToTextOptions) binaryPath(d string) string {
+	if len(o.BinaryPath) > 0 {
+		return o.BinaryPath
+	}
+	return d
+}
+
+// maxAttempts returns the max attempts or a default value
+func (o SpeechToTextOptions) maxAttempts() int {
+	if o.MaxAttempts > 0 {
+		return o.MaxAttempts
+	}
+	return 1
+}
+
+// withWAVFile writes an utterance in a temporary wav file and executes a function with its path
+func withWAVFile(u Utterance, fn func(path string) error) (err error) {
+	// Create temporary file
+	var f *os.File
+	if f, err = ioutil.TempFile("", "astihearing-*.wav"); err != nil {
+		err = errors.Wrap(err, "astihearing: creating temporary file failed")
+		return
+	}
+	defer os.Remove(f.Name())
+
+	// Write wav
+	if err = writeWAV(f, u); err != nil {
+		f.Close()
+		err = errors.Wrapf(err, "astihearing: writing wav in %s failed", f.Name())
+		return
+	}
+
+	// Close
+	if err = f.Close(); err != nil {
+		err = errors.Wrapf(err, "astihearing: closing %s failed", f.Name())
+		return
+	}
+
+	// Execute function
+	return fn(f.Name())
+}
+
+// execute executes a binary and returns its stdout
+func execute(ctx context.Context, timeout time.Duration, name string, args ...string) (stdout []byte, err error) {
+	// Create context
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Create command
+	var bo, be = &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = bo
+	cmd.Stderr = be
+
+	// Run
+	astilog.Debugf("astihearing: executing %s", strings.Join(cmd.Args, " "))
+	if err = cmd.Run(); err != nil {
+		err = errors.Wrapf(err, "astihearing: executing %s failed with stderr %s", strings.Join(cmd.Args, " "), bytes.TrimSpace(be.Bytes()))
+		return
+	}
+	return bo.Bytes(), nil
+}
+
+// newTranscription creates a transcription out of alternatives, the most confident one being the transcription text
+func newTranscription(as []Alternative) (t Transcription) {
+	if len(as) == 0 {
+		return
+	}
+	for idx := range as {
+		as[idx].Text = strings.TrimSpace(as[idx].Text)
+	}
+	sort.SliceStable(as, func(i, j int) bool { return as[i].Confidence > as[j].Confidence })
+	t.Confidence = as[0].Confidence
+	t.Text = as[0].Text
+	t.Alternatives = as[1:]
+	return
+}
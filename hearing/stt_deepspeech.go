@@ -0,0 +1,77 @@
+package astihearing
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// deepSpeech is a speech to text executing the DeepSpeech native client which loads the model file
+type deepSpeech struct {
+	o SpeechToTextOptions
+}
+
+// newDeepSpeech creates a new deepSpeech
+func newDeepSpeech(o SpeechToTextOptions) *deepSpeech {
+	return &deepSpeech{o: o}
+}
+
+// deepSpeechOutput represents the json output of the DeepSpeech native client
+type deepSpeechOutput struct {
+	Transcripts []struct {
+		Confidence float64 `json:"confidence"`
+		Words      []struct {
+			Word string `json:"word"`
+		} `json:"words"`
+	} `json:"transcripts"`
+}
+
+// SpeechToText implements the SpeechToText interface
+func (d *deepSpeech) SpeechToText(ctx context.Context, u Utterance) (t Transcription, err error) {
+	err = withWAVFile(u, func(path string) (err error) {
+		// Build args
+		args := []string{"--model", d.o.ModelPath, "--audio", path, "--json", "--candidate_transcripts", strconv.Itoa(d.o.NumAlternatives)}
+		if len(d.o.ScorerPath) > 0 {
+			args = append(args, "--scorer", d.o.ScorerPath)
+		}
+		args = append(args, d.o.Args...)
+
+		// Execute
+		var b []byte
+		if b, err = execute(ctx, d.o.Timeout, d.o.binaryPath("deepspeech"), args...); err != nil {
+			err = errors.Wrap(err, "astihearing: executing deepspeech failed")
+			return
+		}
+
+		// Unmarshal
+		var o deepSpeechOutput
+		if err = json.Unmarshal(b, &o); err != nil {
+			err = errors.Wrapf(err, "astihearing: unmarshaling %s failed", b)
+			return
+		}
+
+		// Loop through transcripts
+		var as []Alternative
+		for _, v := range o.Transcripts {
+			// Build text
+			var ws []string
+			for _, w := range v.Words {
+				ws = append(ws, w.Word)
+			}
+
+			// DeepSpeech confidence is a sum of log probabilities, we use the per word geometric mean instead
+			var c float64
+			if len(ws) > 0 {
+				c = math.Exp(v.Confidence / float64(len(ws)))
+			}
+			as = append(as, Alternative{Confidence: math.Min(c, 1), Text: strings.Join(ws, " ")})
+		}
+		t = newTranscription(as)
+		return
+	})
+	return
+}
@@ -0,0 +1,131 @@
+package astihearing
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBinary creates an executable printing the provided output
+func fakeBinary(t *testing.T, dir, output string) string {
+	p := filepath.Join(dir, "stt")
+	err := ioutil.WriteFile(p, []byte("#!/bin/sh\ncat <<'EOF'\n"+output+"\nEOF\n"), 0755)
+	assert.NoError(t, err)
+	return p
+}
+
+func TestSpeechToText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astihearing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	u := Utterance{BitDepth: 16, NumChannels: 1, SampleRate: 16000, Samples: make([]int32, 1600)}
+
+	// Invalid options
+	_, err = NewSpeechToText(SpeechToTextOptions{Backend: SpeechToTextBackendVosk})
+	assert.Error(t, err)
+	_, err = NewSpeechToText(SpeechToTextOptions{Backend: "invalid", ModelPath: "model"})
+	assert.Error(t, err)
+
+	// DeepSpeech
+	s, err := NewSpeechToText(SpeechToTextOptions{
+		Backend:    SpeechToTextBackendDeepSpeech,
+		BinaryPath: fakeBinary(t, dir, `{"transcripts":[{"confidence":-4,"words":[{"word":"hello"},{"word":"bob"}]},{"confidence":-2,"words":[{"word":"hello"},{"word":"rob"}]}]}`),
+		ModelPath:  "model",
+	})
+	assert.NoError(t, err)
+	tr, err := s.SpeechToText(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello rob", tr.Text)
+	assert.InDelta(t, 0.368, tr.Confidence, 0.001)
+	assert.Len(t, tr.Alternatives, 1)
+	assert.Equal(t, "hello bob", tr.Alternatives[0].Text)
+
+	// Vosk
+	v, err := NewSpeechToText(SpeechToTextOptions{
+		Backend:    SpeechToTextBackendVosk,
+		BinaryPath: fakeVoskBinary(t, dir, "vosk", `{"alternatives":[{"confidence":301.0986,"text":"hello $n"},{"confidence":300,"text":"hello rob"}]}`),
+		ModelPath:  "model",
+	})
+	assert.NoError(t, err)
+	defer v.(io.Closer).Close()
+	tr, err = v.SpeechToText(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello 1", tr.Text)
+	assert.InDelta(t, 0.75, tr.Confidence, 0.001)
+	assert.Len(t, tr.Alternatives, 1)
+	assert.InDelta(t, 0.25, tr.Alternatives[0].Confidence, 0.001)
+
+	// Vosk process is reused until closed
+	tr, err = v.SpeechToText(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello 2", tr.Text)
+	assert.NoError(t, v.(io.Closer).Close())
+	tr, err = v.SpeechToText(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello 1", tr.Text)
+
+	// Vosk without alternatives
+	s, err = NewSpeechToText(SpeechToTextOptions{
+		Backend:    SpeechToTextBackendVosk,
+		BinaryPath: fakeVoskBinary(t, dir, "vosk-words", `{"result":[{"conf":0.5},{"conf":1}],"text":"hello bob"}`),
+		ModelPath:  "model",
+	})
+	assert.NoError(t, err)
+	defer s.(io.Closer).Close()
+	tr, err = s.SpeechToText(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello bob", tr.Text)
+	assert.Equal(t, 0.75, tr.Confidence)
+
+	// Vosk timeout
+	s, err = NewSpeechToText(SpeechToTextOptions{
+		Backend:    SpeechToTextBackendVosk,
+		BinaryPath: fakeVoskBinary(t, dir, "vosk-timeout", ""),
+		ModelPath:  "model",
+		Timeout:    10 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	defer s.(io.Closer).Close()
+	_, err = s.SpeechToText(context.Background(), u)
+	assert.Error(t, err)
+
+	// Whisper
+	s, err = NewSpeechToText(SpeechToTextOptions{
+		Backend:    SpeechToTextBackendWhisper,
+		BinaryPath: fakeWhisperBinary(t, dir, `{"transcription":[{"text":" hello","tokens":[{"text":"[_BEG_]","p":0.1},{"text":" hello","p":0.8}]},{"text":" bob ","tokens":[{"text":" bob","p":0.6}]}]}`),
+		Language:   "en",
+		ModelPath:  "model",
+	})
+	assert.NoError(t, err)
+	tr, err = s.SpeechToText(context.Background(), u)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello bob", tr.Text)
+	assert.InDelta(t, 0.7, tr.Confidence, 0.001)
+	assert.Len(t, tr.Alternatives, 0)
+}
+
+// fakeVoskBinary creates an executable behaving like a vosk process: it writes the provided output for each path
+// read, $n being replaced with the number of paths read. An empty output means it never answers.
+func fakeVoskBinary(t *testing.T, dir, name, output string) string {
+	p := filepath.Join(dir, name)
+	var c = "#!/bin/sh\necho ready\nn=0\nwhile read path; do\n  n=$((n+1))\n  cat <<EOF\n" + output + "\nEOF\ndone\n"
+	if len(output) == 0 {
+		c = "#!/bin/sh\necho ready\nexec sleep 10\n"
+	}
+	assert.NoError(t, ioutil.WriteFile(p, []byte(c), 0755))
+	return p
+}
+
+// fakeWhisperBinary creates an executable writing the provided output in the json file whisper.cpp would create
+func fakeWhisperBinary(t *testing.T, dir, output string) string {
+	p := filepath.Join(dir, "whisper")
+	err := ioutil.WriteFile(p, []byte("#!/bin/sh\nwhile [ $# -gt 0 ]; do\n  if [ \"$1\" = \"-of\" ]; then of=\"$2\"; fi\n  shift\ndone\ncat > \"$of.json\" <<'EOF'\n"+output+"\nEOF\n"), 0755)
+	assert.NoError(t, err)
+	return p
+}
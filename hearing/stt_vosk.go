@@ -0,0 +1,275 @@
+package astihearing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// voskReady is the line written by the vosk process once its model is loaded
+const voskReady = "ready"
+
+// vosk is a speech to text backed by a long-running process loading a Vosk model once.
+// The process is started with the extra arguments, the model path and the number of alternatives as arguments. It
+// must write "ready" on stdout once the model is loaded, then read wav paths on stdin, one per line, and write the
+// Vosk recognizer's final result json of each of them on stdout, on a single line. hearing/vosk/vosk.py is such a
+// process.
+// The process is restarted on the next utterance if it fails or times out.
+type vosk struct {
+	m sync.Mutex // Locks p and serializes utterances
+	o SpeechToTextOptions
+	p *voskProcess
+}
+
+// newVosk creates a new vosk
+func newVosk(o SpeechToTextOptions) *vosk {
+	return &vosk{o: o}
+}
+
+// voskOutput represents a Vosk recognizer's final result, with or without alternatives
+type voskOutput struct {
+	Alternatives []struct {
+		Confidence float64 `json:"confidence"`
+		Text       string  `json:"text"`
+	} `json:"alternatives"`
+	Error  string `json:"error"`
+	Result []struct {
+		Conf float64 `json:"conf"`
+	} `json:"result"`
+	Text string `json:"text"`
+}
+
+// SpeechToText implements the SpeechToText interface
+func (v *vosk) SpeechToText(ctx context.Context, u Utterance) (t Transcription, err error) {
+	err = withWAVFile(u, func(path string) (err error) {
+		// Recognize
+		var b []byte
+		if b, err = v.recognize(ctx, path); err != nil {
+			err = errors.Wrap(err, "astihearing: recognizing with vosk failed")
+			return
+		}
+
+		// Unmarshal
+		var o voskOutput
+		if err = json.Unmarshal(b, &o); err != nil {
+			err = errors.Wrapf(err, "astihearing: unmarshaling %s failed", b)
+			return
+		} else if len(o.Error) > 0 {
+			err = fmt.Errorf("astihearing: vosk failed with error %s", o.Error)
+			return
+		}
+		t = o.transcription()
+		return
+	})
+	return
+}
+
+// transcription converts the output to a transcription
+func (o voskOutput) transcription() Transcription {
+	// No alternatives
+	if len(o.Alternatives) == 0 {
+		// Confidence is the mean of words confidence
+		var c float64
+		for _, r := range o.Result {
+			c += r.Conf
+		}
+		if len(o.Result) > 0 {
+			c /= float64(len(o.Result))
+		}
+		return newTranscription([]Alternative{{Confidence: c, Text: o.Text}})
+	}
+
+	// Vosk alternatives confidences are log likelihoods: they're turned into probabilities with a softmax
+	var max = math.Inf(-1)
+	for _, a := range o.Alternatives {
+		max = math.Max(max, a.Confidence)
+	}
+	var sum float64
+	var as []Alternative
+	for _, a := range o.Alternatives {
+		c := math.Exp(a.Confidence - max)
+		sum += c
+		as = append(as, Alternative{Confidence: c, Text: a.Text})
+	}
+	for idx := range as {
+		as[idx].Confidence /= sum
+	}
+	return newTranscription(as)
+}
+
+// recognize sends a wav path to the process and returns its output
+func (v *vosk) recognize(ctx context.Context, path string) (b []byte, err error) {
+	// Lock
+	v.m.Lock()
+	defer v.m.Unlock()
+
+	// Start process
+	if v.p == nil {
+		if v.p, err = startVoskProcess(ctx, v.o); err != nil {
+			err = errors.Wrap(err, "astihearing: starting vosk process failed")
+			return
+		}
+	}
+
+	// Recognize
+	if b, err = v.p.recognize(ctx, path, v.o.Timeout); err != nil {
+		// Process state is unknown, it is restarted on the next utterance
+		v.p.close()
+		v.p = nil
+		return
+	}
+	return
+}
+
+// Close implements the io.Closer interface
+func (v *vosk) Close() error {
+	v.m.Lock()
+	defer v.m.Unlock()
+	if v.p != nil {
+		v.p.close()
+		v.p = nil
+	}
+	return nil
+}
+
+// voskProcess is a running vosk process
+type voskProcess struct {
+	cmd   *exec.Cmd
+	lines chan []byte // Lines read on stdout, closed once stdout is closed
+	stdin io.WriteCloser
+}
+
+// startVoskProcess starts a vosk process and waits for its model to be loaded
+func startVoskProcess(ctx context.Context, o SpeechToTextOptions) (p *voskProcess, err error) {
+	// Create command
+	p = &voskProcess{
+		cmd:   exec.Command(o.binaryPath("vosk"), append(append([]string{}, o.Args...), o.ModelPath, strconv.Itoa(o.NumAlternatives))...),
+		lines: make(chan []byte),
+	}
+	var stdout, stderr io.ReadCloser
+	if p.stdin, err = p.cmd.StdinPipe(); err != nil {
+		err = errors.Wrap(err, "astihearing: getting stdin pipe failed")
+		return
+	}
+	if stdout, err = p.cmd.StdoutPipe(); err != nil {
+		err = errors.Wrap(err, "astihearing: getting stdout pipe failed")
+		return
+	}
+	if stderr, err = p.cmd.StderrPipe(); err != nil {
+		err = errors.Wrap(err, "astihearing: getting stderr pipe failed")
+		return
+	}
+
+	// Start
+	astilog.Debugf("astihearing: starting %s", strings.Join(p.cmd.Args, " "))
+	if err = p.cmd.Start(); err != nil {
+		err = errors.Wrapf(err, "astihearing: starting %s failed", strings.Join(p.cmd.Args, " "))
+		return
+	}
+
+	// Read stdout
+	go func() {
+		defer close(p.lines)
+		r := bufio.NewReader(stdout)
+		for {
+			b, err := r.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			p.lines <- bytes.TrimSpace(b)
+		}
+	}()
+
+	// Log stderr
+	go func() {
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			astilog.Debugf("astihearing: vosk: %s", s.Text())
+		}
+	}()
+
+	// Wait for the model to be loaded
+	var b []byte
+	if b, err = p.readLine(ctx, 0); err != nil {
+		p.close()
+		err = errors.Wrap(err, "astihearing: waiting for vosk to be ready failed")
+		return
+	} else if string(b) != voskReady {
+		p.close()
+		err = fmt.Errorf("astihearing: vosk wrote %s instead of %s", b, voskReady)
+		return
+	}
+	return
+}
+
+// readLine reads a line on stdout.
+// A zero timeout means no timeout.
+func (p *voskProcess) readLine(ctx context.Context, timeout time.Duration) (b []byte, err error) {
+	// Create timeout
+	var t <-chan time.Time
+	if timeout > 0 {
+		tm := time.NewTimer(timeout)
+		defer tm.Stop()
+		t = tm.C
+	}
+
+	// Read
+	var ok bool
+	select {
+	case b, ok = <-p.lines:
+		if !ok {
+			err = errors.New("astihearing: vosk process exited")
+		}
+	case <-t:
+		err = fmt.Errorf("astihearing: vosk process didn't answer within %s", timeout)
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "astihearing: context error")
+	}
+	return
+}
+
+// recognize sends a wav path and reads its output
+func (p *voskProcess) recognize(ctx context.Context, path string, timeout time.Duration) (b []byte, err error) {
+	// Write path
+	if _, err = io.WriteString(p.stdin, path+"\n"); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing %s to vosk process failed", path)
+		return
+	}
+
+	// Read output
+	if b, err = p.readLine(ctx, timeout); err != nil {
+		err = errors.Wrap(err, "astihearing: reading vosk output failed")
+		return
+	}
+	return
+}
+
+// close stops the process
+func (p *voskProcess) close() {
+	// Stop process
+	p.stdin.Close()
+	if p.cmd.Process != nil {
+		p.cmd.Process.Kill()
+	}
+
+	// Unblock stdout reader
+	go func() {
+		for range p.lines {
+		}
+	}()
+
+	// Wait
+	p.cmd.Wait()
+}
@@ -0,0 +1,92 @@
+package astihearing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// whisper is a speech to text executing the whisper.cpp CLI which loads the model file.
+// Whisper doesn't provide alternatives.
+type whisper struct {
+	o SpeechToTextOptions
+}
+
+// newWhisper creates a new whisper
+func newWhisper(o SpeechToTextOptions) *whisper {
+	return &whisper{o: o}
+}
+
+// whisperOutput represents the full json output of the whisper.cpp CLI
+type whisperOutput struct {
+	Transcription []struct {
+		Text   string `json:"text"`
+		Tokens []struct {
+			P    float64 `json:"p"`
+			Text string  `json:"text"`
+		} `json:"tokens"`
+	} `json:"transcription"`
+}
+
+// SpeechToText implements the SpeechToText interface
+func (w *whisper) SpeechToText(ctx context.Context, u Utterance) (t Transcription, err error) {
+	err = withWAVFile(u, func(path string) (err error) {
+		// Build args
+		// Output is written next to the wav file
+		args := []string{"-m", w.o.ModelPath, "-f", path, "-np", "-nt", "-ojf", "-of", path}
+		if len(w.o.Language) > 0 {
+			args = append(args, "-l", w.o.Language)
+		}
+		args = append(args, w.o.Args...)
+
+		// Execute
+		if _, err = execute(ctx, w.o.Timeout, w.o.binaryPath("whisper-cli"), args...); err != nil {
+			err = errors.Wrap(err, "astihearing: executing whisper failed")
+			return
+		}
+
+		// Read output
+		var b []byte
+		p := path + ".json"
+		defer os.Remove(p)
+		if b, err = ioutil.ReadFile(p); err != nil {
+			err = errors.Wrapf(err, "astihearing: reading %s failed", p)
+			return
+		}
+
+		// Unmarshal
+		var o whisperOutput
+		if err = json.Unmarshal(b, &o); err != nil {
+			err = errors.Wrapf(err, "astihearing: unmarshaling %s failed", b)
+			return
+		}
+
+		// Loop through segments
+		var ts []string
+		var c float64
+		var n int
+		for _, s := range o.Transcription {
+			ts = append(ts, strings.TrimSpace(s.Text))
+			for _, tk := range s.Tokens {
+				// Special tokens such as [_BEG_] are skipped
+				if strings.HasPrefix(tk.Text, "[_") {
+					continue
+				}
+				c += tk.P
+				n++
+			}
+		}
+
+		// Confidence is the mean of tokens probabilities
+		if n > 0 {
+			c /= float64(n)
+		}
+		t = newTranscription([]Alternative{{Confidence: c, Text: strings.Join(ts, " ")}})
+		return
+	})
+	return
+}
@@ -0,0 +1,317 @@
+package astihearing
+
+import (
+	"math"
+	"time"
+)
+
+// Default VAD options
+const (
+	defaultVADFrameDuration        = 20 * time.Millisecond
+	defaultVADHangoverDuration     = 300 * time.Millisecond
+	defaultVADMaxUtteranceDuration = 10 * time.Second
+	defaultVADMinUtteranceDuration = 200 * time.Millisecond
+	defaultVADNoiseFloorAdaptation = 0.05
+	defaultVADNoiseFloorWindow     = 5 * time.Second
+	defaultVADPreRollDuration      = 100 * time.Millisecond
+	defaultVADThreshold            = 10
+	defaultVADZeroCrossingRate     = 0.3
+)
+
+// minEnergyDB is the energy of digital silence
+const minEnergyDB = -120
+
+// VADOptions represents voice activity detection options.
+// A frame is considered as speech if its energy is above the noise floor by more than Threshold dB, or if its energy
+// is above the noise floor by more than half of Threshold dB and its zero crossing rate is above ZeroCrossingRate,
+// which catches unvoiced sounds such as fricatives.
+type VADOptions struct {
+	FrameDuration time.Duration `toml:"frame_duration"`
+	// Duration of silence after which an utterance is considered as done
+	HangoverDuration     time.Duration `toml:"hangover_duration"`
+	MaxUtteranceDuration time.Duration `toml:"max_utterance_duration"`
+	// Utterances shorter than this are dropped
+	MinUtteranceDuration time.Duration `toml:"min_utterance_duration"`
+	// Rate between 0 and 1 at which the noise floor rises towards the energy of non speech frames. It drops instantly.
+	NoiseFloorAdaptation float64 `toml:"noise_floor_adaptation"`
+	// The noise floor is raised to the minimum energy of the frames of this window if all of them are above it, which
+	// keeps a sustained increase of the noise from being mistaken for endless speech
+	NoiseFloorWindow time.Duration `toml:"noise_floor_window"`
+	// Duration of audio kept before the first speech frame
+	PreRollDuration  time.Duration `toml:"pre_roll_duration"`
+	Threshold        float64       `toml:"threshold"` // In dB
+	ZeroCrossingRate float64       `toml:"zero_crossing_rate"`
+}
+
+// Utterance represents a chunk of speech.
+// Samples are interleaved and in the hearing's format.
+type Utterance struct {
+	BitDepth    int
+	Channel     int // Channel the utterance has been heard on when channels are split or selected
+	End         time.Time
+	NumChannels int
+	SampleRate  int
+	Samples     []int32
+	Start       time.Time
+}
+
+// Duration returns the utterance duration
+func (u Utterance) Duration() time.Duration {
+	return u.End.Sub(u.Start)
+}
+
+// UtteranceFunc represents a function executed on each utterance detected
+type UtteranceFunc func(u Utterance)
+
+// vad splits a stream of samples into utterances
+type vad struct {
+	buf         []int32 // Samples of the current frame
+	bitDepth    int
+	energies    []float64 // Energies of the frames of the noise floor window
+	energyIdx   int       // Index of the oldest energy once the window is full
+	fn          func(u Utterance)
+	frameSize   int // In samples
+	noiseFloor  float64
+	numChannels int
+	o           VADOptions
+	offset      int // Number of samples processed since start
+	preRoll     []int32
+	sampleRate  int
+	silence     int // Number of consecutive non speech samples in the current utterance
+	start       time.Time
+	u           *Utterance
+	uOffset     int // Offset of the first sample of the current utterance
+	uPreRoll    int // Number of pre roll samples in the current utterance
+}
+
+// newVAD creates a new vad
+func newVAD(bitDepth, numChannels, sampleRate int, o VADOptions, fn func(u Utterance)) (v *vad) {
+	// Default options
+	if o.FrameDuration <= 0 {
+		o.FrameDuration = defaultVADFrameDuration
+	}
+	if o.HangoverDuration <= 0 {
+		o.HangoverDuration = defaultVADHangoverDuration
+	}
+	if o.MaxUtteranceDuration <= 0 {
+		o.MaxUtteranceDuration = defaultVADMaxUtteranceDuration
+	}
+	if o.MinUtteranceDuration <= 0 {
+		o.MinUtteranceDuration = defaultVADMinUtteranceDuration
+	}
+	if o.NoiseFloorAdaptation <= 0 || o.NoiseFloorAdaptation > 1 {
+		o.NoiseFloorAdaptation = defaultVADNoiseFloorAdaptation
+	}
+	if o.NoiseFloorWindow <= 0 {
+		o.NoiseFloorWindow = defaultVADNoiseFloorWindow
+	}
+	if o.PreRollDuration <= 0 {
+		o.PreRollDuration = defaultVADPreRollDuration
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = defaultVADThreshold
+	}
+	if o.ZeroCrossingRate <= 0 {
+		o.ZeroCrossingRate = defaultVADZeroCrossingRate
+	}
+
+	// Create vad
+	v = &vad{
+		bitDepth:    bitDepth,
+		energies:    make([]float64, 0, int(o.NoiseFloorWindow/o.FrameDuration)+1),
+		fn:          fn,
+		frameSize:   durationToSamples(o.FrameDuration, numChannels, sampleRate),
+		numChannels: numChannels,
+		o:           o,
+		sampleRate:  sampleRate,
+	}
+	v.reset(time.Now())
+	return
+}
+
+// durationToSamples returns the number of interleaved samples in a duration
+func durationToSamples(d time.Duration, numChannels, sampleRate int) int {
+	n := int(d.Seconds()*float64(sampleRate)) * numChannels
+	if n < numChannels {
+		n = numChannels
+	}
+	return n
+}
+
+// duration returns the duration of a number of interleaved samples
+func (v *vad) duration(n int) time.Duration {
+	return time.Duration(float64(n/v.numChannels) / float64(v.sampleRate) * float64(time.Second))
+}
+
+// reset resets the vad state, the first sample written next is considered as having been read at the provided time
+func (v *vad) reset(start time.Time) {
+	v.buf = v.buf[:0]
+	v.energies = v.energies[:0]
+	v.energyIdx = 0
+	v.noiseFloor = math.NaN()
+	v.offset = 0
+	v.preRoll = v.preRoll[:0]
+	v.silence = 0
+	v.start = start
+	v.u = nil
+}
+
+// write processes samples
+func (v *vad) write(samples []int32) {
+	for len(samples) > 0 {
+		// Fill frame
+		n := v.frameSize - len(v.buf)
+		if n > len(samples) {
+			n = len(samples)
+		}
+		v.buf = append(v.buf, samples[:n]...)
+		samples = samples[n:]
+
+		// Frame is not complete
+		if len(v.buf) < v.frameSize {
+			return
+		}
+
+		// Process frame
+		v.processFrame(v.buf)
+		v.offset += len(v.buf)
+		v.buf = v.buf[:0]
+	}
+}
+
+// frameFeatures returns the energy in dB relative to full scale and the zero crossing rate of a frame
+func (v *vad) frameFeatures(frame []int32) (energyDB, zcr float64) {
+	var max = math.Pow(2, float64(v.bitDepth-1))
+	var sum float64
+	var crossings int
+	var prev float64
+	for i, s := range frame {
+		x := float64(s) / max
+		sum += x * x
+		if i > 0 && ((x >= 0) != (prev >= 0)) {
+			crossings++
+		}
+		prev = x
+	}
+	energyDB = minEnergyDB
+	if e := sum / float64(len(frame)); e > 0 {
+		energyDB = math.Max(10*math.Log10(e), minEnergyDB)
+	}
+	if len(frame) > 1 {
+		zcr = float64(crossings) / float64(len(frame)-1)
+	}
+	return
+}
+
+// isSpeech checks whether a frame is speech and adapts the noise floor
+func (v *vad) isSpeech(frame []int32) (speech bool) {
+	// Get features
+	e, zcr := v.frameFeatures(frame)
+
+	// Add energy to the window
+	if len(v.energies) < cap(v.energies) {
+		v.energies = append(v.energies, e)
+	} else {
+		v.energies[v.energyIdx] = e
+		v.energyIdx = (v.energyIdx + 1) % len(v.energies)
+	}
+
+	// First frame
+	if math.IsNaN(v.noiseFloor) {
+		v.noiseFloor = e
+		return false
+	}
+
+	// Check
+	speech = e-v.noiseFloor > v.o.Threshold || (e-v.noiseFloor > v.o.Threshold/2 && zcr > v.o.ZeroCrossingRate)
+
+	// Adapt noise floor
+	if !speech {
+		if e < v.noiseFloor {
+			v.noiseFloor = e
+		} else {
+			v.noiseFloor += v.o.NoiseFloorAdaptation * (e - v.noiseFloor)
+		}
+	}
+
+	// Raise noise floor to the window minimum
+	// Speech has pauses, therefore a window without any frame close to the noise floor means the noise has increased
+	if len(v.energies) == cap(v.energies) {
+		var min = v.energies[0]
+		for _, x := range v.energies[1:] {
+			min = math.Min(min, x)
+		}
+		if min > v.noiseFloor {
+			v.noiseFloor = min
+		}
+	}
+	return
+}
+
+// processFrame updates the utterance state machine with a frame
+func (v *vad) processFrame(frame []int32) {
+	// Check whether the frame is speech
+	speech := v.isSpeech(frame)
+
+	// No utterance in progress
+	if v.u == nil {
+		// Not speech
+		if !speech {
+			// Keep pre roll
+			v.preRoll = append(v.preRoll, frame...)
+			if max := durationToSamples(v.o.PreRollDuration, v.numChannels, v.sampleRate); len(v.preRoll) > max {
+				v.preRoll = append(v.preRoll[:0], v.preRoll[len(v.preRoll)-max:]...)
+			}
+			return
+		}
+
+		// Start utterance
+		v.u = &Utterance{
+			BitDepth:    v.bitDepth,
+			NumChannels: v.numChannels,
+			SampleRate:  v.sampleRate,
+			Samples:     append([]int32{}, v.preRoll...),
+		}
+		v.uOffset = v.offset - len(v.preRoll)
+		v.uPreRoll = len(v.preRoll)
+		v.preRoll = v.preRoll[:0]
+		v.silence = 0
+	}
+
+	// Add frame
+	v.u.Samples = append(v.u.Samples, frame...)
+	if speech {
+		v.silence = 0
+	} else {
+		v.silence += len(frame)
+	}
+
+	// Utterance is done
+	if d := v.duration(len(v.u.Samples)); v.duration(v.silence) >= v.o.HangoverDuration || d >= v.o.MaxUtteranceDuration {
+		v.flush()
+	}
+}
+
+// flush emits the current utterance if it's long enough
+func (v *vad) flush() {
+	// No utterance
+	if v.u == nil {
+		return
+	}
+
+	// Reset state
+	u, silence := *v.u, v.silence
+	v.u = nil
+	v.silence = 0
+
+	// Utterance is too short
+	// Pre roll and trailing silence are not taken into account
+	if v.duration(len(u.Samples)-v.uPreRoll-silence) < v.o.MinUtteranceDuration {
+		return
+	}
+
+	// Emit
+	u.Start = v.start.Add(v.duration(v.uOffset))
+	u.End = u.Start.Add(v.duration(len(u.Samples)))
+	v.fn(u)
+}
@@ -0,0 +1,95 @@
+package astihearing
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vadSamples generates a 440Hz sine wave with the provided amplitude on top of low noise
+func vadSamples(d time.Duration, amplitude float64) (o []int32) {
+	n := int(d.Seconds() * 16000)
+	o = make([]int32, n)
+	for i := 0; i < n; i++ {
+		v := float64(1<<8) * float64(i%7-3)
+		if amplitude > 0 {
+			v += amplitude * math.Pow(2, 15) * math.Sin(2*math.Pi*440*float64(i)/16000)
+		}
+		o[i] = int32(v)
+	}
+	return
+}
+
+func TestVAD(t *testing.T) {
+	var us []Utterance
+	v := newVAD(16, 1, 16000, VADOptions{}, func(u Utterance) { us = append(us, u) })
+	s := time.Unix(100, 0)
+	v.reset(s)
+
+	// Silence only
+	v.write(vadSamples(time.Second, 0))
+	assert.Len(t, us, 0)
+
+	// Utterance
+	v.write(vadSamples(time.Second, 0.5))
+	v.write(vadSamples(time.Second, 0))
+	assert.Len(t, us, 1)
+	assert.InDelta(t, 900*time.Millisecond, us[0].Start.Sub(s), float64(20*time.Millisecond))
+	assert.InDelta(t, 2300*time.Millisecond, us[0].End.Sub(s), float64(20*time.Millisecond))
+	assert.Equal(t, int(us[0].Duration().Seconds()*16000), len(us[0].Samples))
+
+	// Too short
+	v.write(vadSamples(100*time.Millisecond, 0.5))
+	v.write(vadSamples(time.Second, 0))
+	assert.Len(t, us, 1)
+
+	// Too long
+	// Speech has short pauses which keep the noise floor from rising
+	for i := 0; i < 25; i++ {
+		v.write(vadSamples(900*time.Millisecond, 0.5))
+		v.write(vadSamples(100*time.Millisecond, 0))
+	}
+	assert.Len(t, us, 3)
+	assert.Equal(t, 10*time.Second, us[1].Duration())
+}
+
+func TestVADNoiseStep(t *testing.T) {
+	var us []Utterance
+	v := newVAD(16, 1, 16000, VADOptions{}, func(u Utterance) { us = append(us, u) })
+	s := time.Unix(100, 0)
+	v.reset(s)
+
+	// Noise increases and stays high
+	r := rand.New(rand.NewSource(1))
+	noise := func(d time.Duration) (o []int32) {
+		o = vadSamples(d, 0)
+		for i := range o {
+			o[i] += int32(r.Intn(1<<13) - 1<<12)
+		}
+		return
+	}
+	v.write(vadSamples(2*time.Second, 0))
+	v.write(noise(10 * time.Second))
+
+	// Noise step is only mistaken for speech until the noise floor has caught up
+	if !assert.Len(t, us, 1) {
+		return
+	}
+	assert.True(t, us[0].Duration() < defaultVADNoiseFloorWindow+time.Second)
+
+	// Speech is still detected on top of the new noise floor
+	sp := noise(time.Second)
+	for i, v := range vadSamples(time.Second, 0.5) {
+		sp[i] += v
+	}
+	v.write(sp)
+	v.write(noise(time.Second))
+	if !assert.Len(t, us, 2) {
+		return
+	}
+	assert.InDelta(t, 11900*time.Millisecond, us[1].Start.Sub(s), float64(40*time.Millisecond))
+	assert.InDelta(t, 13300*time.Millisecond, us[1].End.Sub(s), float64(40*time.Millisecond))
+}
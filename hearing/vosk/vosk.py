@@ -0,0 +1,52 @@
+#!/usr/bin/env python3
+"""Process used by the vosk speech to text backend of astihearing.
+
+Usage: vosk.py <model path> <number of alternatives>
+
+The model is loaded once, then "ready" is written on stdout. Each line read on stdin is the path of a 16 bits mono
+wav file, for which the recognizer's final result json is written on stdout, on a single line. Errors are written as
+{"error": "<message>"}.
+
+It requires the vosk python package: pip install vosk
+"""
+
+import json
+import sys
+import wave
+
+from vosk import KaldiRecognizer, Model, SetLogLevel
+
+
+def recognize(model, path, alternatives):
+    with wave.open(path, "rb") as w:
+        r = KaldiRecognizer(model, w.getframerate())
+        r.SetMaxAlternatives(alternatives)
+        r.SetWords(True)
+
+        # The whole utterance is accepted at once so that an endpoint can't split it
+        if r.AcceptWaveform(w.readframes(w.getnframes())):
+            return json.loads(r.Result())
+        return json.loads(r.FinalResult())
+
+
+def main():
+    if len(sys.argv) < 3:
+        sys.exit("usage: vosk.py <model path> <number of alternatives>")
+
+    # Load model
+    SetLogLevel(-1)
+    model = Model(sys.argv[-2])
+    alternatives = int(sys.argv[-1])
+    print("ready", flush=True)
+
+    # Recognize
+    for line in sys.stdin:
+        try:
+            result = recognize(model, line.rstrip("\n"), alternatives)
+        except Exception as e:
+            result = {"error": str(e)}
+        print(json.dumps(result), flush=True)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,279 @@
+package astihearing
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// WakeWordOptions represents wake word options.
+// When enabled, an utterance is only passed to speech to text if it follows, within the timeout, an utterance
+// matching one of the enrolled samples.
+type WakeWordOptions struct {
+	Enabled bool `toml:"enabled"`
+	// Max distance between an utterance and a sample for them to match. Distances are logged to help tuning it.
+	Threshold float64       `toml:"threshold"`
+	Timeout   time.Duration `toml:"timeout"`
+}
+
+// Default wake word options
+const (
+	defaultWakeWordThreshold = 15
+	defaultWakeWordTimeout   = 5 * time.Second
+)
+
+// WakeWordSample represents an enrolled wake word sample
+type WakeWordSample struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Duration  time.Duration `json:"duration"`
+	ID        string        `json:"id"`
+}
+
+// WakeWordFunc represents a function executed each time the wake word is detected
+type WakeWordFunc func(u Utterance, distance float64)
+
+// wakeWord matches utterances against enrolled samples using MFCC features and DTW
+type wakeWord struct {
+	armedUntil map[int]time.Time // Indexed by channel so that channels split in utterances are gated independently
+	dir        string
+	enroll     bool // Whether the next utterance is enrolled
+	m          sync.Mutex
+	o          WakeWordOptions
+	samples    map[string]*wakeWordSample
+}
+
+// wakeWordSample represents an enrolled sample and its features
+type wakeWordSample struct {
+	features [][]float64
+	s        WakeWordSample
+}
+
+// newWakeWord creates a new wake word and loads the samples stored in a directory
+func newWakeWord(dir string, o WakeWordOptions) (w *wakeWord, err error) {
+	// Default options
+	if o.Threshold <= 0 {
+		o.Threshold = defaultWakeWordThreshold
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultWakeWordTimeout
+	}
+
+	// Create wake word
+	w = &wakeWord{
+		armedUntil: make(map[int]time.Time),
+		dir:        dir,
+		o:          o,
+		samples:    make(map[string]*wakeWordSample),
+	}
+
+	// Create directory
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		err = errors.Wrapf(err, "astihearing: mkdirall %s failed", dir)
+		return
+	}
+
+	// Read directory
+	var fs []os.FileInfo
+	if fs, err = ioutil.ReadDir(dir); err != nil {
+		err = errors.Wrapf(err, "astihearing: reading directory %s failed", dir)
+		return
+	}
+
+	// Loop through files
+	for _, f := range fs {
+		// Not a wav file
+		if f.IsDir() || filepath.Ext(f.Name()) != ".wav" {
+			continue
+		}
+
+		// Read file
+		var b []byte
+		p := filepath.Join(dir, f.Name())
+		if b, err = ioutil.ReadFile(p); err != nil {
+			err = errors.Wrapf(err, "astihearing: reading %s failed", p)
+			return
+		}
+
+		// Read wav
+		var u Utterance
+		if u, err = wavToUtterance(b); err != nil {
+			err = errors.Wrapf(err, "astihearing: reading wav %s failed", p)
+			return
+		}
+
+		// Add sample
+		w.addSample(strings.TrimSuffix(f.Name(), ".wav"), u)
+	}
+	return
+}
+
+// wavToUtterance reads a wav file as an utterance
+func wavToUtterance(b []byte) (u Utterance, err error) {
+	if u.Samples, u.BitDepth, u.NumChannels, u.SampleRate, err = readWAV(bytes.NewReader(b)); err != nil {
+		return
+	}
+	u.End = u.Start.Add(time.Duration(float64(len(u.Samples)/u.NumChannels) / float64(u.SampleRate) * float64(time.Second)))
+	return
+}
+
+// addSample adds a sample. It assumes the lock is held or not needed.
+func (w *wakeWord) addSample(id string, u Utterance) WakeWordSample {
+	var createdAt time.Time
+	if v, err := strconv.ParseInt(id, 10, 64); err == nil {
+		createdAt = time.Unix(0, v)
+	}
+	s := &wakeWordSample{
+		features: mfcc(u),
+		s: WakeWordSample{
+			CreatedAt: createdAt,
+			Duration:  u.Duration(),
+			ID:        id,
+		},
+	}
+	w.samples[id] = s
+	return s.s
+}
+
+// wavPath returns the path of a sample wav file
+func (w *wakeWord) wavPath(id string) string {
+	return filepath.Join(w.dir, id+".wav")
+}
+
+// enrollNext makes the next utterance be enrolled as a sample
+func (w *wakeWord) enrollNext() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	w.enroll = true
+}
+
+// enrolling checks whether an utterance must be enrolled and resets the enrollment
+func (w *wakeWord) enrolling() (ok bool) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	ok, w.enroll = w.enroll, false
+	return
+}
+
+// add enrolls an utterance as a sample
+func (w *wakeWord) add(u Utterance) (s WakeWordSample, err error) {
+	// Lock
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	// Create file
+	var f *os.File
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	p := w.wavPath(id)
+	if f, err = os.Create(p); err != nil {
+		err = errors.Wrapf(err, "astihearing: creating %s failed", p)
+		return
+	}
+	defer f.Close()
+
+	// Write wav
+	if err = writeWAV(f, u); err != nil {
+		err = errors.Wrapf(err, "astihearing: writing wav in %s failed", p)
+		return
+	}
+
+	// Add sample
+	s = w.addSample(id, u)
+	return
+}
+
+// list returns the samples sorted by creation date
+func (w *wakeWord) list() (o []WakeWordSample) {
+	w.m.Lock()
+	defer w.m.Unlock()
+	o = []WakeWordSample{}
+	for _, s := range w.samples {
+		o = append(o, s.s)
+	}
+	sort.Slice(o, func(i, j int) bool { return o[i].ID < o[j].ID })
+	return
+}
+
+// has checks whether a sample exists
+func (w *wakeWord) has(id string) bool {
+	w.m.Lock()
+	defer w.m.Unlock()
+	_, ok := w.samples[id]
+	return ok
+}
+
+// delete deletes a sample
+func (w *wakeWord) delete(id string) (err error) {
+	// Lock
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	// Sample doesn't exist
+	if _, ok := w.samples[id]; !ok {
+		err = fmt.Errorf("astihearing: unknown wake word sample %s", id)
+		return
+	}
+
+	// Remove file
+	if err = os.Remove(w.wavPath(id)); err != nil && !os.IsNotExist(err) {
+		err = errors.Wrapf(err, "astihearing: removing %s failed", w.wavPath(id))
+		return
+	}
+	delete(w.samples, id)
+	return nil
+}
+
+// match returns the smallest distance between an utterance and the samples
+func (w *wakeWord) match(u Utterance) (distance float64) {
+	// Get features without keeping the lock
+	var fs [][][]float64
+	w.m.Lock()
+	for _, s := range w.samples {
+		fs = append(fs, s.features)
+	}
+	w.m.Unlock()
+
+	// Loop through samples
+	distance = math.Inf(1)
+	features := mfcc(u)
+	for _, f := range fs {
+		distance = math.Min(distance, dtw(features, f))
+	}
+	return
+}
+
+// gate checks whether an utterance must be passed to speech to text.
+// An utterance matching the wake word is not passed but arms the gate for the next one heard on the same channel.
+func (w *wakeWord) gate(u Utterance) (pass, matched bool, distance float64) {
+	// Gate is armed
+	w.m.Lock()
+	armed := time.Now().Before(w.armedUntil[u.Channel])
+	delete(w.armedUntil, u.Channel)
+	w.m.Unlock()
+	if armed {
+		return true, false, 0
+	}
+
+	// Match
+	distance = w.match(u)
+	astilog.Debugf("astihearing: wake word distance of utterance detected at %s is %.2f", u.Start, distance)
+	if distance > w.o.Threshold {
+		return
+	}
+
+	// Arm gate
+	w.m.Lock()
+	w.armedUntil[u.Channel] = time.Now().Add(w.o.Timeout)
+	w.m.Unlock()
+	return false, true, distance
+}
@@ -0,0 +1,85 @@
+package astihearing
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chirp generates a 16 bits utterance sweeping from a frequency to another with some noise
+func chirp(from, to float64, noise float64) Utterance {
+	var r = rand.New(rand.NewSource(1))
+	var u = Utterance{BitDepth: 16, NumChannels: 1, SampleRate: 16000, Samples: make([]int32, 8000)}
+	var phase float64
+	for i := range u.Samples {
+		f := from + (to-from)*float64(i)/float64(len(u.Samples))
+		phase += 2 * math.Pi * f / float64(u.SampleRate)
+		u.Samples[i] = int32(10000*math.Sin(phase) + noise*10000*(r.Float64()*2-1))
+	}
+	u.End = u.Start.Add(500 * time.Millisecond)
+	return u
+}
+
+func TestWakeWord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astihearing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Enroll
+	w, err := newWakeWord(dir, WakeWordOptions{})
+	assert.NoError(t, err)
+	w.enrollNext()
+	assert.True(t, w.enrolling())
+	assert.False(t, w.enrolling())
+	s, err := w.add(chirp(300, 3000, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, s.Duration)
+
+	// Samples are reloaded
+	w, err = newWakeWord(dir, WakeWordOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []WakeWordSample{s}, w.list())
+
+	// Distances
+	same, other := w.match(chirp(300, 3000, 0.1)), w.match(chirp(3000, 300, 0.1))
+	t.Logf("same: %.2f, other: %.2f", same, other)
+	assert.True(t, same < other)
+
+	// Gate
+	w.o.Threshold = (same + other) / 2
+	pass, matched, _ := w.gate(chirp(3000, 300, 0.1))
+	assert.False(t, pass)
+	assert.False(t, matched)
+	pass, matched, _ = w.gate(chirp(300, 3000, 0.1))
+	assert.False(t, pass)
+	assert.True(t, matched)
+	pass, matched, _ = w.gate(chirp(3000, 300, 0.1))
+	assert.True(t, pass)
+	assert.False(t, matched)
+	pass, _, _ = w.gate(chirp(3000, 300, 0.1))
+	assert.False(t, pass)
+
+	// Channels are gated independently
+	c1 := chirp(300, 3000, 0.1)
+	c1.Channel = 1
+	_, matched, _ = w.gate(chirp(300, 3000, 0.1))
+	assert.True(t, matched)
+	_, matched, _ = w.gate(c1)
+	assert.True(t, matched)
+	pass, _, _ = w.gate(chirp(3000, 300, 0.1))
+	assert.True(t, pass)
+	c1 = chirp(3000, 300, 0.1)
+	c1.Channel = 1
+	pass, _, _ = w.gate(c1)
+	assert.True(t, pass)
+
+	// Delete
+	assert.NoError(t, w.delete(s.ID))
+	assert.Len(t, w.list(), 0)
+	assert.Error(t, w.delete(s.ID))
+}
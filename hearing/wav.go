@@ -0,0 +1,209 @@
+package astihearing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// wavBitDepth is the bit depth of wav files written by hearing, which is what most speech engines expect
+const wavBitDepth = 16
+
+// writeWAV writes an utterance as a 16 bits mono PCM wav file.
+// Channels are downmixed.
+func writeWAV(w io.Writer, u Utterance) (err error) {
+	// Convert samples
+	var numChannels = u.NumChannels
+	if numChannels <= 0 {
+		numChannels = 1
+	}
+	var data = make([]int16, len(u.Samples)/numChannels)
+	for i := range data {
+		var s int64
+		for c := 0; c < numChannels; c++ {
+			s += int64(u.Samples[i*numChannels+c])
+		}
+		data[i] = int16(convertBitDepth(s/int64(numChannels), u.BitDepth, wavBitDepth))
+	}
+
+	// Create header
+	var blockAlign = wavBitDepth / 8
+	var dataSize = len(data) * blockAlign
+	var h = []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + dataSize),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),
+		uint16(1), // PCM
+		uint16(1),
+		uint32(u.SampleRate),
+		uint32(u.SampleRate * blockAlign),
+		uint16(blockAlign),
+		uint16(wavBitDepth),
+		[4]byte{'d', 'a', 't', 'a'},
+		uint32(dataSize),
+	}
+
+	// Write header
+	for _, v := range h {
+		if err = binary.Write(w, binary.LittleEndian, v); err != nil {
+			err = errors.Wrap(err, "astihearing: writing wav header failed")
+			return
+		}
+	}
+
+	// Write data
+	if err = binary.Write(w, binary.LittleEndian, data); err != nil {
+		err = errors.Wrap(err, "astihearing: writing wav data failed")
+		return
+	}
+	return
+}
+
+// convertBitDepth converts a sample from a bit depth to another
+func convertBitDepth(s int64, from, to int) int64 {
+	if from > to {
+		return s >> uint(from-to)
+	}
+	return s << uint(to-from)
+}
+
+// readWAV reads a PCM wav file and returns its interleaved samples
+func readWAV(r io.Reader) (samples []int32, bitDepth, numChannels, sampleRate int, err error) {
+	// Read header
+	var dataSize uint32
+	if bitDepth, numChannels, sampleRate, dataSize, err = readWAVHeader(r); err != nil {
+		err = errors.Wrap(err, "astihearing: reading wav header failed")
+		return
+	}
+
+	// Read data
+	var b = make([]byte, dataSize)
+	if _, err = io.ReadFull(r, b); err != nil {
+		err = errors.Wrap(err, "astihearing: reading data chunk failed")
+		return
+	}
+
+	// Convert samples
+	if samples, err = bytesToSamples(b, bitDepth); err != nil {
+		err = errors.Wrap(err, "astihearing: converting bytes to samples failed")
+		return
+	}
+	return
+}
+
+// readWAVHeader reads a PCM wav file until the beginning of its data chunk
+func readWAVHeader(r io.Reader) (bitDepth, numChannels, sampleRate int, dataSize uint32, err error) {
+	// Read RIFF header
+	var h struct {
+		ID     [4]byte
+		Size   uint32
+		Format [4]byte
+	}
+	if err = binary.Read(r, binary.LittleEndian, &h); err != nil {
+		err = errors.Wrap(err, "astihearing: reading riff header failed")
+		return
+	}
+	if string(h.ID[:]) != "RIFF" || string(h.Format[:]) != "WAVE" {
+		err = errors.New("astihearing: not a wav file")
+		return
+	}
+
+	// Loop through chunks
+	for {
+		// Read chunk header
+		var c struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err = binary.Read(r, binary.LittleEndian, &c); err != nil {
+			err = errors.Wrap(err, "astihearing: reading chunk header failed")
+			return
+		}
+
+		// Switch on chunk
+		switch string(c.ID[:]) {
+		case "fmt ":
+			// Read format
+			var f struct {
+				AudioFormat   uint16
+				NumChannels   uint16
+				SampleRate    uint32
+				ByteRate      uint32
+				BlockAlign    uint16
+				BitsPerSample uint16
+			}
+			if err = binary.Read(r, binary.LittleEndian, &f); err != nil {
+				err = errors.Wrap(err, "astihearing: reading fmt chunk failed")
+				return
+			}
+			if f.AudioFormat != 1 {
+				err = fmt.Errorf("astihearing: audio format %d is not PCM", f.AudioFormat)
+				return
+			}
+			bitDepth, numChannels, sampleRate = int(f.BitsPerSample), int(f.NumChannels), int(f.SampleRate)
+
+			// Skip extra bytes
+			if _, err = io.CopyN(ioutil.Discard, r, int64(c.Size)-16); err != nil {
+				err = errors.Wrap(err, "astihearing: skipping fmt chunk extra bytes failed")
+				return
+			}
+		case "data":
+			// Format is unknown
+			if bitDepth == 0 {
+				err = errors.New("astihearing: data chunk found before fmt chunk")
+				return
+			}
+			dataSize = c.Size
+			return
+		default:
+			// Skip chunk, which are word aligned
+			if _, err = io.CopyN(ioutil.Discard, r, int64(c.Size+c.Size%2)); err != nil {
+				err = errors.Wrapf(err, "astihearing: skipping %s chunk failed", c.ID)
+				return
+			}
+		}
+	}
+}
+
+// bytesToSamples converts little endian signed PCM bytes to samples.
+// 8 bits PCM is unsigned.
+func bytesToSamples(b []byte, bitDepth int) (samples []int32, err error) {
+	if bitDepth%8 != 0 || bitDepth <= 0 {
+		err = fmt.Errorf("astihearing: bit depth %d is not supported", bitDepth)
+		return
+	}
+	samples = make([]int32, len(b)/(bitDepth/8))
+	err = decodeSamples(samples, b, bitDepth)
+	return
+}
+
+// decodeSamples converts little endian signed PCM bytes to samples stored in a caller provided slice.
+// 8 bits PCM is unsigned.
+func decodeSamples(samples []int32, b []byte, bitDepth int) (err error) {
+	switch bitDepth {
+	case 8:
+		for i := range samples {
+			samples[i] = int32(b[i]) - 128
+		}
+	case 16:
+		for i := range samples {
+			samples[i] = int32(int16(binary.LittleEndian.Uint16(b[2*i:])))
+		}
+	case 24:
+		for i := range samples {
+			samples[i] = int32(uint32(b[3*i])<<8|uint32(b[3*i+1])<<16|uint32(b[3*i+2])<<24) >> 8
+		}
+	case 32:
+		for i := range samples {
+			samples[i] = int32(binary.LittleEndian.Uint32(b[4*i:]))
+		}
+	default:
+		err = fmt.Errorf("astihearing: bit depth %d is not supported", bitDepth)
+	}
+	return
+}
@@ -1,10 +1,7 @@
 package astiportaudio
 
 import (
-	"os"
-
 	"github.com/asticode/go-astilog"
-	"github.com/cryptix/wav"
 	"github.com/gordonklaus/portaudio"
 	"github.com/pkg/errors"
 )
@@ -13,7 +10,7 @@ import (
 type Stream struct {
 	b     []int32
 	o     StreamOptions
-	queue []int32
+	queue []int32 // Part of b that has not been read yet
 	s     *portaudio.Stream
 }
 
@@ -74,71 +71,34 @@ func (s *Stream) Stop() (err error) {
 	return
 }
 
-// TEST
-var w *wav.Writer
-var count int
-var f *os.File
-
-// ReadSample implements the astihearing.SampleReader interface.
-func (s *Stream) ReadSample() (r int32, err error) {
+// ReadSamples implements the astihearing.SamplesReader interface.
+// Samples are copied from the stream buffer which is only refilled once it has been entirely read, so that no
+// allocation is needed.
+func (s *Stream) ReadSamples(samples []int32) (n int, err error) {
 	// Queue is empty
 	if len(s.queue) == 0 {
 		// Read
 		if err = s.s.Read(); err != nil {
 			err = errors.Wrap(err, "astiportaudio: reading failed")
-			count = 0
-			w.Close()
-			f.Close()
-			w = nil
 			return
 		}
 
-		// Copy buffer to queue
-		s.queue = make([]int32, len(s.b))
-		copy(s.queue, s.b)
-
-		// TEST
-		if w == nil {
-			const filename = "caca.wav"
-			if f, err = os.Create(filename); err != nil {
-				err = errors.Wrapf(err, "creating %s failed", filename)
-				return
-			}
-			caca := wav.File{
-				Channels:        1,
-				SampleRate:      16000,
-				SignificantBits: 32,
-			}
-			if w, err = caca.NewWriter(f); err != nil {
-				err = errors.Wrap(err, "creating wav writer failed")
-				return
-			}
-		}
+		// Queue is a view of the buffer
+		s.queue = s.b
 	}
 
 	// Process queue
+	n = copy(samples, s.queue)
+	s.queue = s.queue[n:]
+	return
+}
 
-	// TEST
-	if err = w.WriteInt32(s.queue[0]); err != nil {
-		w.Close()
-		f.Close()
-		w = nil
-		count = 0
-		err = errors.Wrap(err, "writing failed")
-		return
-	}
-	count++
-	if count > 100000 {
-		astilog.Debug("Wrote wav")
-		w.Close()
-		f.Close()
-		w = nil
-		count = 0
-		err = errors.New("caca")
+// ReadSample implements the astihearing.SampleReader interface.
+func (s *Stream) ReadSample() (r int32, err error) {
+	var b [1]int32
+	if _, err = s.ReadSamples(b[:]); err != nil {
 		return
 	}
-
-	// Update queue
-	s.queue = s.queue[1:]
+	r = b[0]
 	return
 }
@@ -0,0 +1,98 @@
+package astibob
+
+import (
+	"github.com/asticode/go-astilog"
+	"github.com/pkg/errors"
+)
+
+// ConfigLoader loads Bob's options, usually by reading the configuration file again
+type ConfigLoader func() (Options, error)
+
+// ReloadReport represents what has been applied live when reloading options and what requires a restart
+type ReloadReport struct {
+	Applied         []string `json:"applied,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// SetConfigLoader sets the function used to load options when reloading
+func (b *Bob) SetConfigLoader(fn ConfigLoader) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.loader = fn
+}
+
+// Reload loads options with the config loader and applies them
+func (b *Bob) Reload() (r ReloadReport, err error) {
+	// Get loader
+	b.m.Lock()
+	fn := b.loader
+	b.m.Unlock()
+
+	// No loader
+	if fn == nil {
+		err = errors.New("astibob: no config loader has been set")
+		return
+	}
+
+	// Load
+	var o Options
+	if o, err = fn(); err != nil {
+		err = errors.Wrap(err, "astibob: loading options failed")
+		return
+	}
+
+	// Apply
+	r = b.Apply(o)
+	return
+}
+
+// Apply diffs options against the running ones and applies what can be applied live.
+// Brain connections are kept. Options that require a restart are reported and left untouched so that they
+// keep being reported until Bob is restarted.
+func (b *Bob) Apply(o Options) (r ReloadReport) {
+	// Lock
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	// Servers
+	b.o.BrainsServer = r.applyServer("brains_server", b.brainsServer.server, b.o.BrainsServer, o.BrainsServer)
+	b.o.ClientsServer = r.applyServer("clients_server", b.clientsServer.server, b.o.ClientsServer, o.ClientsServer)
+
+	// Resources directory
+	if b.o.ResourcesDirectory != o.ResourcesDirectory {
+		r.RestartRequired = append(r.RestartRequired, "resources_directory")
+	}
+
+	// Log
+	astilog.Infof("astibob: reload applied %v, restart required for %v", r.Applied, r.RestartRequired)
+	return
+}
+
+// applyServer applies server options that can be applied live and returns the resulting options
+func (r *ReloadReport) applyServer(prefix string, s *server, old, new ServerOptions) ServerOptions {
+	// Options read at request time
+	var o = old
+	if old.Password != new.Password {
+		o.Password = new.Password
+		r.Applied = append(r.Applied, prefix+".password")
+	}
+	if old.PublicAddr != new.PublicAddr {
+		o.PublicAddr = new.PublicAddr
+		r.Applied = append(r.Applied, prefix+".public_addr")
+	}
+	if old.Timeout != new.Timeout {
+		o.Timeout = new.Timeout
+		r.Applied = append(r.Applied, prefix+".timeout")
+	}
+	if old.Username != new.Username {
+		o.Username = new.Username
+		r.Applied = append(r.Applied, prefix+".username")
+	}
+	s.setOptions(o)
+
+	// Options read when the server starts
+	if old.ListenAddr != new.ListenAddr {
+		r.RestartRequired = append(r.RestartRequired, prefix+".listen_addr")
+	}
+	return o
+}
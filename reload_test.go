@@ -0,0 +1,18 @@
+package astibob
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadReport_ApplyServer(t *testing.T) {
+	s := newServer("test", ServerOptions{ListenAddr: "127.0.0.1:1", Password: "p", Timeout: time.Second, Username: "u"})
+	var r ReloadReport
+	o := r.applyServer("test", s, s.options(), ServerOptions{ListenAddr: "127.0.0.1:2", Password: "p2", Timeout: time.Second, Username: "u"})
+	assert.Equal(t, []string{"test.password"}, r.Applied)
+	assert.Equal(t, []string{"test.listen_addr"}, r.RestartRequired)
+	assert.Equal(t, ServerOptions{ListenAddr: "127.0.0.1:1", Password: "p2", Timeout: time.Second, Username: "u"}, o)
+	assert.Equal(t, o, s.options())
+}
@@ -0,0 +1,142 @@
+let audio = {
+    context: null,
+    nextTimes: {},
+    outputs: {},
+    sockets: {},
+    bytes: function(data) {
+        // Decode base64
+        let raw = atob(data);
+        let view = new DataView(new ArrayBuffer(raw.length));
+        for (let i = 0; i < raw.length; i++) {
+            view.setUint8(i, raw.charCodeAt(i));
+        }
+        return view;
+    },
+    decode: function(data) {
+        // Decode the frame header, see astibrain.AudioFrame
+        let header = new DataView(data);
+        let offset = 2 + header.getUint8(1);
+        let bitDepth = header.getUint8(offset);
+        let numChannels = header.getUint8(offset + 1);
+        let sampleRate = header.getUint32(offset + 2, true);
+        let view = new DataView(data, offset + 10);
+
+        // Decode little-endian signed PCM
+        let bytesPerSample = bitDepth / 8;
+        let max = Math.pow(2, bitDepth - 1);
+        let numSamples = view.byteLength / bytesPerSample / numChannels;
+        let buffer = audio.context.createBuffer(numChannels, numSamples, sampleRate);
+        for (let c = 0; c < numChannels; c++) {
+            let channel = buffer.getChannelData(c);
+            for (let i = 0; i < numSamples; i++) {
+                let offset = (i * numChannels + c) * bytesPerSample;
+                let v;
+                switch (bytesPerSample) {
+                    case 1:
+                        v = view.getInt8(offset);
+                        break;
+                    case 2:
+                        v = view.getInt16(offset, true);
+                        break;
+                    case 3:
+                        v = (view.getInt8(offset + 2) << 16) | (view.getUint8(offset + 1) << 8) | view.getUint8(offset);
+                        break;
+                    default:
+                        v = view.getInt32(offset, true);
+                }
+                channel[i] = v / max;
+            }
+        }
+        return buffer;
+    },
+    initContext: function() {
+        // It needs to be created after a user gesture
+        if (audio.context === null) {
+            audio.context = new (window.AudioContext || window.webkitAudioContext)();
+        }
+    },
+    key: function(brainKey, abilityKey) {
+        return brainKey + "/" + abilityKey;
+    },
+    play: function(key, data) {
+        // Schedule buffer right after the previous one
+        let source = audio.context.createBufferSource();
+        source.buffer = audio.decode(data);
+        source.connect(audio.context.destination);
+        let startAt = Math.max(audio.context.currentTime, audio.nextTimes[key] || 0);
+        source.start(startAt);
+        audio.nextTimes[key] = startAt + source.buffer.duration;
+    },
+    playOutput: function(payload) {
+        // Not listening
+        let key = audio.key(payload.brain_key, payload.name);
+        if (!audio.outputs[key]) {
+            return;
+        }
+
+        // Decode WAV and schedule it right after the previous one
+        audio.context.decodeAudioData(audio.bytes(payload.data).buffer, function(buffer) {
+            let source = audio.context.createBufferSource();
+            source.buffer = buffer;
+            source.connect(audio.context.destination);
+            let startAt = Math.max(audio.context.currentTime, audio.nextTimes[key] || 0);
+            source.start(startAt);
+            audio.nextTimes[key] = startAt + buffer.duration;
+        }, function(err) {
+            asticode.notifier.error("Decoding audio output " + key + " failed: " + err);
+        });
+    },
+    reset: function() {
+        for (let key in audio.sockets) {
+            if (audio.sockets.hasOwnProperty(key)) {
+                audio.sockets[key].close();
+            }
+        }
+        audio.nextTimes = {};
+        audio.outputs = {};
+        audio.sockets = {};
+    },
+    toggle: function(brainKey, abilityKey) {
+        // Init context
+        audio.initContext();
+
+        // Stop listening
+        let key = audio.key(brainKey, abilityKey);
+        let id = "#" + base.toggleID(brainKey, abilityKey) + "-audio";
+        if (audio.sockets[key]) {
+            audio.sockets[key].close();
+            return;
+        }
+
+        // Listen through a websocket dedicated to the stream that receives binary frames
+        let ws = new WebSocket(base.ws.url + "/audio/streams/" + brainKey + "/" + abilityKey);
+        ws.binaryType = "arraybuffer";
+        ws.onmessage = function(event) { audio.play(key, event.data); };
+        ws.onclose = function() {
+            if (audio.sockets[key] === ws) {
+                delete audio.sockets[key];
+                delete audio.nextTimes[key];
+            }
+            $(id).toggleClass("active", typeof audio.sockets[key] !== "undefined");
+        };
+        audio.sockets[key] = ws;
+        $(id).toggleClass("active", true);
+    },
+    toggleOutput: function(brainKey, name) {
+        // Init context
+        audio.initContext();
+
+        // Toggle
+        let key = audio.key(brainKey, name);
+        let payload = {brain_key: brainKey, name: name};
+        if (audio.outputs[key]) {
+            delete audio.outputs[key];
+            delete audio.nextTimes[key];
+            base.sendWs(consts.webSocket.eventNames.audioOutputUnsubscribe, payload);
+        } else {
+            audio.outputs[key] = true;
+            base.sendWs(consts.webSocket.eventNames.audioOutputSubscribe, payload);
+        }
+        $(".btn-speaker[data-key='" + key + "']").toggleClass("active", audio.outputs[key] === true);
+    }
+};
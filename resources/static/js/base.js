@@ -32,21 +32,32 @@ let base = {
         });
     },
     initButtons: function() {
+        // Reload Bob's configuration
+        $("#btn-bob-reload").click(function() {
+            base.sendHttp("/api/bob/reload", "GET", function(data) {
+                asticode.notifier.success("Applied: " + (typeof data.applied !== "undefined" ? data.applied.join(", ") : "nothing"));
+                if (typeof data.restart_required !== "undefined") {
+                    asticode.notifier.warning("Restart required: " + data.restart_required.join(", "));
+                }
+            });
+        });
+
         // Stop Bob
         $("#btn-bob-stop").click(function() {
             base.sendHttp("/api/bob/stop", "GET");
         });
     },
     initMenu: function(data) {
+        // Store brains
+        base.brains = (typeof data.brains !== "undefined" ? data.brains : {});
+
         // Init html
         let html = `<div class="table">`;
 
-        // Loop through abilities
-        if (typeof data.abilities !== "undefined") {
-            for (let k in data.abilities) {
-                if (data.abilities.hasOwnProperty(k)) {
-                    html += base.initToggle(k, data.abilities[k])
-                }
+        // Loop through brains
+        for (let k in base.brains) {
+            if (base.brains.hasOwnProperty(k)) {
+                html += base.initBrain(base.brains[k])
             }
         }
 
@@ -54,13 +65,47 @@ let base = {
         html += "</div>";
         $("#menu").html(html);
     },
-    initToggle: function(key, data) {
+    initBrain: function(data) {
+        let inputsHtml = "";
+        if (typeof data.audio_inputs !== "undefined") {
+            for (let name in data.audio_inputs) {
+                if (data.audio_inputs.hasOwnProperty(name)) {
+                    inputsHtml += ` <i class="fa fa-microphone btn-audio btn-microphone" data-key="` + data.key + `/` + name + `" onclick="microphone.toggle('` + data.key + `', '` + name + `')" title="Send your microphone to ` + name + `"></i>`;
+                }
+            }
+        }
+        if (typeof data.audio_outputs !== "undefined") {
+            for (let i = 0; i < data.audio_outputs.length; i++) {
+                let name = data.audio_outputs[i];
+                inputsHtml += ` <i class="fa fa-volume-up btn-audio btn-speaker" data-key="` + data.key + `/` + name + `" onclick="audio.toggleOutput('` + data.key + `', '` + name + `')" title="Play ` + name + ` in your browser"></i>`;
+            }
+        }
+        let html = `<div class="row">
+            <div class="cell" style="padding-top: 10px"><a href="/web/brain?key=` + data.key + `" title="Stats"><b>` + data.name + `</b></a>` + inputsHtml + `</div>
+            <div class="cell"></div>
+        </div>`;
+        if (typeof data.abilities !== "undefined") {
+            for (let k in data.abilities) {
+                if (data.abilities.hasOwnProperty(k)) {
+                    html += base.initToggle(data.key, data.abilities[k])
+                }
+            }
+        }
+        return html
+    },
+    initToggle: function(brainKey, data) {
         if (typeof data !== "undefined") {
+            let id = base.toggleID(brainKey, data.key);
             let state = (data.is_on ? "on" : "off");
+            let audioHtml = "";
+            if (typeof data.audio_format !== "undefined") {
+                audioHtml = ` <i class="fa fa-headphones btn-audio" id="` + id + `-audio" onclick="audio.toggle('` + brainKey + `', '` + data.key + `')" title="Listen"></i>
+                    <a href="/api/brains/` + brainKey + `/abilities/` + data.key + `/audio/record?duration=10s" title="Record 10 seconds"><i class="fa fa-download btn-audio"></i></a>`;
+            }
             return `<div class="row">
-                <div class="cell" style="padding-right: 10px">` + data.name + `</div>
+                <div class="cell" style="padding-right: 10px">` + data.name + audioHtml + `</div>
                 <div class="cell">
-                    <label class="toggle ` + state + `" id="` + key + `" onclick="base.handleToggle('` + key + `')" data-state="` + state + `">
+                    <label class="toggle ` + state + `" id="` + id + `" onclick="base.handleToggle('` + brainKey + `', '` + data.key + `')" data-state="` + state + `" title="` + data.state + `">
                         <span class="slider"></span>
                     </label>
                 </div>
@@ -88,18 +133,22 @@ let base = {
         };
         base.ws.onmessage = function(event) {
             let data = JSON.parse(event.data);
-            if (!base.webSocketFunc(data.event_name, data.payload)) {
+            base.webSocketFunc(data.event_name, data.payload);
+            if (webSocketFunc !== null) {
                 webSocketFunc(data.event_name, data.payload);
             }
         };
     },
-    handleToggle: function(key) {
-        base.sendHttp("/api/abilities/" + key + "/" + ($("#" + key).data("state") === "on" ? "off" : "on"), "GET");
+    handleToggle: function(brainKey, abilityKey) {
+        let id = base.toggleID(brainKey, abilityKey);
+        base.sendHttp("/api/brains/" + brainKey + "/abilities/" + abilityKey + "/" + ($("#" + id).data("state") === "on" ? "stop" : "start"), "POST");
     },
-    sendHttp: function(url, method, successFunc, errorFunc) {
+    sendHttp: function(url, method, successFunc, errorFunc, data) {
         $.ajax({
             url: url,
             type: method,
+            contentType: (typeof data !== "undefined" ? "application/json" : undefined),
+            data: (typeof data !== "undefined" ? JSON.stringify(data) : undefined),
             dataType: "json",
             error: function(jqXHR) {
                 // Get message
@@ -130,20 +179,44 @@ let base = {
     sendWs: function(event_name, payload) {
         base.ws.send(JSON.stringify({event_name: event_name, payload: payload}));
     },
-    updateToggle: function(key, is_on) {
-        let sw = $("#" + key);
+    toggleID: function(brainKey, abilityKey) {
+        return "toggle-" + brainKey + "-" + abilityKey;
+    },
+    updateToggle: function(brainKey, abilityKey, is_on, state) {
+        let sw = $("#" + base.toggleID(brainKey, abilityKey));
         sw.removeClass(is_on ? "off" : "on");
         sw.addClass(is_on ? "on" : "off");
-        sw.data("state", is_on ? "on" : "off")
+        sw.data("state", is_on ? "on" : "off");
+        sw.attr("title", state);
     },
     webSocketFunc: function(event_name, payload) {
         switch (event_name) {
             case consts.webSocket.eventNames.abilityCrashed:
-            case consts.webSocket.eventNames.abilityOff:
-                base.updateToggle(payload, false);
+                asticode.notifier.error(payload.brain_key + "/" + payload.ability_key + " has crashed: " + payload.error);
+                break;
+            case consts.webSocket.eventNames.abilityHealth:
+                if (payload.status === "unhealthy") {
+                    asticode.notifier.warning(payload.brain_key + "/" + payload.ability_key + " is unhealthy: " + payload.error);
+                }
+                break;
+            case consts.webSocket.eventNames.abilityTransitioned:
+                base.updateToggle(payload.brain_key, payload.ability_key, payload.is_on, payload.to);
+                break;
+            case consts.webSocket.eventNames.audioOutput:
+                audio.playOutput(payload);
+                break;
+            case consts.webSocket.eventNames.brainDisconnected:
+                audio.reset();
+                if (microphone.key !== null && microphone.key.indexOf(payload.key + "/") === 0) {
+                    microphone.stop();
+                }
+                delete base.brains[payload.key];
+                base.initMenu({brains: base.brains});
                 break;
-            case consts.webSocket.eventNames.abilityOn:
-                base.updateToggle(payload, true);
+            case consts.webSocket.eventNames.brainRegistered:
+                base.brains[payload.key] = payload;
+                base.initMenu({brains: base.brains});
+                microphone.update();
                 break;
             default:
                 return false;
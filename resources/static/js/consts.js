@@ -2,8 +2,14 @@ let consts = {
     webSocket: {
         eventNames: {
             abilityCrashed: "ability.crashed",
-            abilityOff: "ability.off",
-            abilityOn: "ability.on"
+            abilityHealth: "ability.health",
+            abilityTransitioned: "ability.transitioned",
+            audioOutput: "audio.output",
+            audioOutputSubscribe: "audio.output.subscribe",
+            audioOutputUnsubscribe: "audio.output.unsubscribe",
+            brainDisconnected: "brain.disconnected",
+            brainRegistered: "brain.registered",
+            brainStats: "brain.stats"
         }
     }
 };
\ No newline at end of file
@@ -0,0 +1,114 @@
+let microphone = {
+    context: null,
+    key: null,
+    offset: 0,
+    processor: null,
+    sequence: 0,
+    source: null,
+    stream: null,
+    ws: null,
+    encode: function(samples, format) {
+        // Encode the frame header, see astibrain.AudioFrame
+        // The name is left empty since the websocket is dedicated to the input
+        let bytesPerSample = format.bit_depth / 8;
+        let view = new DataView(new ArrayBuffer(12 + samples.length * bytesPerSample));
+        view.setUint8(0, 1);
+        view.setUint8(1, 0);
+        view.setUint8(2, format.bit_depth);
+        view.setUint8(3, format.num_channels);
+        view.setUint32(4, format.sample_rate, true);
+        view.setUint32(8, microphone.sequence++, true);
+
+        // Convert to little-endian signed PCM
+        let max = Math.pow(2, format.bit_depth - 1) - 1;
+        for (let i = 0; i < samples.length; i++) {
+            let v = Math.round(Math.max(-1, Math.min(1, samples[i])) * max);
+            for (let j = 0; j < bytesPerSample; j++) {
+                view.setUint8(12 + i * bytesPerSample + j, (v >> (8 * j)) & 0xff);
+            }
+        }
+        return view.buffer;
+    },
+    resample: function(input, inputRate, outputRate) {
+        // Linear interpolation, the offset is kept between buffers so that there's no discontinuity
+        let ratio = inputRate / outputRate;
+        let output = [];
+        let position = microphone.offset;
+        while (position < input.length - 1) {
+            let index = Math.floor(position);
+            let fraction = position - index;
+            output.push(input[index] * (1 - fraction) + input[index + 1] * fraction);
+            position += ratio;
+        }
+        microphone.offset = position - input.length;
+        return output;
+    },
+    start: function(brainKey, name, format) {
+        navigator.mediaDevices.getUserMedia({audio: true}).then(function(stream) {
+            // Init
+            microphone.key = brainKey + "/" + name;
+            microphone.offset = 0;
+            microphone.sequence = 0;
+            microphone.stream = stream;
+            microphone.ws = new WebSocket(base.ws.url + "/audio/inputs/" + brainKey + "/" + name);
+            microphone.ws.binaryType = "arraybuffer";
+            microphone.context = new (window.AudioContext || window.webkitAudioContext)();
+            microphone.source = microphone.context.createMediaStreamSource(stream);
+            microphone.processor = microphone.context.createScriptProcessor(4096, 1, 1);
+
+            // Send mono samples in the input's format as binary frames
+            microphone.processor.onaudioprocess = function(event) {
+                let samples = microphone.resample(event.inputBuffer.getChannelData(0), microphone.context.sampleRate, format.sample_rate);
+                if (microphone.ws !== null && microphone.ws.readyState === WebSocket.OPEN) {
+                    microphone.ws.send(microphone.encode(samples, format));
+                }
+            };
+
+            // Connect
+            microphone.source.connect(microphone.processor);
+            microphone.processor.connect(microphone.context.destination);
+            microphone.update();
+        }).catch(function(err) {
+            asticode.notifier.error("Accessing the microphone failed: " + err);
+        });
+    },
+    stop: function() {
+        if (microphone.processor !== null) {
+            microphone.processor.disconnect();
+            microphone.source.disconnect();
+            microphone.stream.getTracks().forEach(function(track) { track.stop(); });
+            microphone.context.close();
+        }
+        if (microphone.ws !== null) {
+            microphone.ws.close();
+        }
+        microphone.context = null;
+        microphone.key = null;
+        microphone.processor = null;
+        microphone.source = null;
+        microphone.stream = null;
+        microphone.ws = null;
+        microphone.update();
+    },
+    toggle: function(brainKey, name) {
+        // Get format
+        let format = base.brains[brainKey].audio_inputs[name];
+        if (format.num_channels !== 1) {
+            asticode.notifier.error("Only mono audio inputs are supported");
+            return;
+        }
+
+        // Toggle
+        let key = brainKey + "/" + name;
+        let isOn = microphone.key === key;
+        microphone.stop();
+        if (!isOn) {
+            microphone.start(brainKey, name, format);
+        }
+    },
+    update: function() {
+        $(".btn-microphone").each(function() {
+            $(this).toggleClass("active", $(this).data("key") === microphone.key);
+        });
+    }
+};
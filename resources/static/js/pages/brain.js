@@ -0,0 +1,121 @@
+let brain = {
+    key: null,
+    init: function () {
+        // Get brain key
+        brain.key = new URLSearchParams(window.location.search).get("key");
+
+        base.init(brain.webSocketFunc, function() {
+            // Render
+            brain.render();
+
+            // Finish
+            base.finish();
+        });
+    },
+    formatBytes: function(v) {
+        let units = ["B", "kB", "MB", "GB"];
+        let i = 0;
+        while (v >= 1024 && i < units.length - 1) {
+            v /= 1024;
+            i++;
+        }
+        return v.toFixed(i === 0 ? 0 : 1) + units[i];
+    },
+    formatDuration: function(v) {
+        // Durations are in nanoseconds
+        let s = Math.floor(v / 1e9);
+        let h = Math.floor(s / 3600);
+        let m = Math.floor((s % 3600) / 60);
+        return (h > 0 ? h + "h" : "") + (h > 0 || m > 0 ? m + "m" : "") + (s % 60) + "s";
+    },
+    render: function() {
+        // Brain doesn't exist
+        let data = base.brains[brain.key];
+        if (typeof data === "undefined") {
+            $("#brain").html("<p>Brain " + brain.key + " is not connected</p>");
+            return;
+        }
+
+        // Runtime
+        let html = `<h2>` + data.name + `</h2>`;
+        if (typeof data.runtime !== "undefined") {
+            html += `<h3>Runtime</h3>
+            <div class="table stats">
+                <div class="row"><div class="cell">Uptime</div><div class="cell">` + brain.formatDuration(data.runtime.uptime) + `</div></div>
+                <div class="row"><div class="cell">Goroutines</div><div class="cell">` + data.runtime.goroutines + `</div></div>
+                <div class="row"><div class="cell">Heap in use</div><div class="cell">` + brain.formatBytes(data.runtime.heap_inuse) + `</div></div>
+                <div class="row"><div class="cell">Heap objects</div><div class="cell">` + data.runtime.heap_objects + `</div></div>
+                <div class="row"><div class="cell">Sys</div><div class="cell">` + brain.formatBytes(data.runtime.sys) + `</div></div>
+                <div class="row"><div class="cell">GC</div><div class="cell">` + data.runtime.num_gc + ` (` + brain.formatDuration(data.runtime.pause_total) + ` paused)</div></div>
+                <div class="row"><div class="cell">Go</div><div class="cell">` + data.runtime.go_version + ` on ` + data.runtime.num_cpu + ` CPUs</div></div>
+            </div>`;
+        }
+
+        // Abilities
+        html += `<h3>Abilities</h3>
+        <div class="table stats">
+            <div class="row header">
+                <div class="cell">Name</div>
+                <div class="cell">State</div>
+                <div class="cell">Health</div>
+                <div class="cell">Uptime</div>
+                <div class="cell">Time in Run</div>
+                <div class="cell">Starts</div>
+                <div class="cell">Crashes</div>
+                <div class="cell">Events</div>
+            </div>`;
+        for (let k in data.abilities) {
+            if (data.abilities.hasOwnProperty(k)) {
+                let a = data.abilities[k];
+                let s = (typeof a.stats !== "undefined" ? a.stats : {uptime: 0, run_duration: 0, starts: 0, crashes: 0, events: 0});
+                html += `<div class="row">
+                    <div class="cell">` + a.name + `</div>
+                    <div class="cell">` + a.state + `</div>
+                    <div class="cell">` + a.health + `</div>
+                    <div class="cell">` + brain.formatDuration(s.uptime) + `</div>
+                    <div class="cell">` + brain.formatDuration(s.run_duration) + `</div>
+                    <div class="cell">` + s.starts + `</div>
+                    <div class="cell">` + s.crashes + `</div>
+                    <div class="cell">` + s.events + `</div>
+                </div>`;
+            }
+        }
+        html += `</div>`;
+        $("#brain").html(html);
+    },
+    webSocketFunc: function(event_name, payload) {
+        switch (event_name) {
+            case consts.webSocket.eventNames.abilityHealth:
+                if (payload.brain_key === brain.key && typeof base.brains[brain.key] !== "undefined") {
+                    base.brains[brain.key].abilities[payload.ability_key].health = payload.status;
+                    brain.render();
+                }
+                break;
+            case consts.webSocket.eventNames.abilityTransitioned:
+                if (payload.brain_key === brain.key && typeof base.brains[brain.key] !== "undefined") {
+                    base.brains[brain.key].abilities[payload.ability_key].state = payload.to;
+                    brain.render();
+                }
+                break;
+            case consts.webSocket.eventNames.brainDisconnected:
+            case consts.webSocket.eventNames.brainRegistered:
+                if (payload.key === brain.key) {
+                    brain.render();
+                }
+                break;
+            case consts.webSocket.eventNames.brainStats:
+                if (payload.brain_key !== brain.key || typeof base.brains[brain.key] === "undefined") {
+                    break;
+                }
+                let data = base.brains[brain.key];
+                data.runtime = payload.runtime;
+                for (let k in payload.abilities) {
+                    if (payload.abilities.hasOwnProperty(k) && typeof data.abilities[k] !== "undefined") {
+                        data.abilities[k].stats = payload.abilities[k];
+                    }
+                }
+                brain.render();
+                break;
+        }
+    }
+};
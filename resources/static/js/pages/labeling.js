@@ -0,0 +1,148 @@
+let labeling = {
+    status: "",
+    utterances: {},
+    init: function () {
+        base.init(labeling.webSocketFunc, function() {
+            // Fetch
+            labeling.fetch();
+
+            // Finish
+            base.finish();
+        });
+    },
+    abilityURL: function(brainKey, abilityKey) {
+        return "/api/brains/" + brainKey + "/abilities/" + abilityKey + "/api";
+    },
+    escape: function(v) {
+        return $("<div>").text(v).html();
+    },
+    fetch: function() {
+        // Reset
+        labeling.utterances = {};
+        labeling.render();
+
+        // Loop through abilities exposing an API
+        for (let bk in base.brains) {
+            if (!base.brains.hasOwnProperty(bk)) {
+                continue;
+            }
+            for (let ak in base.brains[bk].abilities) {
+                if (!base.brains[bk].abilities.hasOwnProperty(ak) || !base.brains[bk].abilities[ak].api) {
+                    continue;
+                }
+                labeling.fetchAbility(bk, ak);
+            }
+        }
+    },
+    fetchAbility: function(brainKey, abilityKey) {
+        let url = labeling.abilityURL(brainKey, abilityKey) + "/utterances" + (labeling.status !== "" ? "?status=" + labeling.status : "");
+        base.sendHttp(url, "GET", function(data) {
+            for (let i = 0; i < data.length; i++) {
+                let u = data[i];
+                u.ability_key = abilityKey;
+                u.brain_key = brainKey;
+                labeling.utterances[labeling.key(u)] = u;
+            }
+            labeling.render();
+        });
+    },
+    formatDuration: function(v) {
+        // Durations are in nanoseconds
+        return (v / 1e9).toFixed(2) + "s";
+    },
+    key: function(u) {
+        return u.brain_key + "/" + u.ability_key + "/" + u.id;
+    },
+    render: function() {
+        // Sort utterances by start
+        let us = [];
+        for (let k in labeling.utterances) {
+            if (labeling.utterances.hasOwnProperty(k)) {
+                us.push(labeling.utterances[k]);
+            }
+        }
+        us.sort(function(a, b) { return a.start < b.start ? -1 : (a.start > b.start ? 1 : 0); });
+
+        // Filter
+        let statuses = ["", "failed", "low_confidence", "validated", "junk"];
+        let html = `<h2>Labeling</h2>
+        <p>
+            Status <select onchange="labeling.status = this.value; labeling.fetch()">`;
+        for (let i = 0; i < statuses.length; i++) {
+            html += `<option value="` + statuses[i] + `"` + (statuses[i] === labeling.status ? " selected" : "") + `>` + (statuses[i] === "" ? "all" : statuses[i]) + `</option>`;
+        }
+        html += `</select>
+            <a href="/api/dataset" title="Export validated utterances as a training dataset"><i class="fa fa-download btn-label"></i> Export dataset</a>
+        </p>`;
+
+        // Utterances
+        if (us.length === 0) {
+            html += `<p>No utterances</p>`;
+            $("#labeling").html(html);
+            return;
+        }
+        html += `<div class="table stats">
+            <div class="row header">
+                <div class="cell">Brain</div>
+                <div class="cell">Start</div>
+                <div class="cell">Duration</div>
+                <div class="cell">Attempts</div>
+                <div class="cell">Status</div>
+                <div class="cell">Audio</div>
+                <div class="cell">Recognized</div>
+                <div class="cell">Transcript</div>
+                <div class="cell"></div>
+            </div>`;
+        for (let i = 0; i < us.length; i++) {
+            let u = us[i];
+            let k = labeling.key(u);
+            let recognized = (typeof u.transcription !== "undefined" ? u.transcription.text + " (" + u.transcription.confidence.toFixed(2) + ")" : u.error);
+            html += `<div class="row">
+                <div class="cell">` + labeling.escape(u.brain) + `</div>
+                <div class="cell">` + new Date(u.start).toLocaleString() + `</div>
+                <div class="cell">` + labeling.formatDuration(u.duration) + `</div>
+                <div class="cell">` + u.attempts + `</div>
+                <div class="cell">` + u.status + `</div>
+                <div class="cell"><audio controls preload="none" src="` + labeling.abilityURL(u.brain_key, u.ability_key) + `/utterances/` + u.id + `/wav"></audio></div>
+                <div class="cell">` + labeling.escape(typeof recognized !== "undefined" ? recognized : "") + `</div>
+                <div class="cell"><input type="text" class="transcript" data-key="` + k + `" value="` + labeling.escape(typeof u.transcript !== "undefined" ? u.transcript : (typeof u.transcription !== "undefined" ? u.transcription.text : "")) + `"></div>
+                <div class="cell">
+                    <i class="fa fa-check btn-label" onclick="labeling.update('` + k + `', 'validated')" title="Validate transcript"></i>
+                    <i class="fa fa-ban btn-label" onclick="labeling.update('` + k + `', 'junk')" title="Mark as junk"></i>
+                    <i class="fa fa-trash btn-label" onclick="labeling.delete('` + k + `')" title="Delete"></i>
+                </div>
+            </div>`;
+        }
+        html += `</div>`;
+        $("#labeling").html(html);
+    },
+    delete: function(key) {
+        let u = labeling.utterances[key];
+        base.sendHttp(labeling.abilityURL(u.brain_key, u.ability_key) + "/utterances/" + u.id, "DELETE", function() {
+            delete labeling.utterances[key];
+            labeling.render();
+        });
+    },
+    update: function(key, status) {
+        let u = labeling.utterances[key];
+        let transcript = $(".transcript").filter(function() { return $(this).data("key") === key; }).val();
+        base.sendHttp(labeling.abilityURL(u.brain_key, u.ability_key) + "/utterances/" + u.id, "PATCH", function(data) {
+            data.ability_key = u.ability_key;
+            data.brain_key = u.brain_key;
+            if (labeling.status === "" || labeling.status === data.status) {
+                labeling.utterances[key] = data;
+            } else {
+                delete labeling.utterances[key];
+            }
+            labeling.render();
+        }, undefined, {status: status, transcript: transcript});
+    },
+    webSocketFunc: function(event_name, payload) {
+        switch (event_name) {
+            case consts.webSocket.eventNames.brainDisconnected:
+            case consts.webSocket.eventNames.brainRegistered:
+                labeling.fetch();
+                break;
+        }
+    }
+};
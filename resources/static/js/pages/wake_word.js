@@ -0,0 +1,131 @@
+let wakeWord = {
+    samples: {},
+    init: function () {
+        base.init(wakeWord.webSocketFunc, function() {
+            // Fetch
+            wakeWord.fetch();
+
+            // Finish
+            base.finish();
+        });
+    },
+    abilities: function() {
+        let as = [];
+        for (let bk in base.brains) {
+            if (!base.brains.hasOwnProperty(bk)) {
+                continue;
+            }
+            for (let ak in base.brains[bk].abilities) {
+                if (base.brains[bk].abilities.hasOwnProperty(ak) && base.brains[bk].abilities[ak].api) {
+                    as.push({ability_key: ak, brain_key: bk, key: bk + "/" + ak, name: base.brains[bk].name + " / " + base.brains[bk].abilities[ak].name});
+                }
+            }
+        }
+        return as;
+    },
+    fetch: function() {
+        // Reset
+        wakeWord.samples = {};
+        wakeWord.render();
+
+        // Loop through abilities exposing an API
+        let as = wakeWord.abilities();
+        for (let i = 0; i < as.length; i++) {
+            wakeWord.fetchAbility(as[i]);
+        }
+    },
+    fetchAbility: function(a) {
+        base.sendHttp(labeling.abilityURL(a.brain_key, a.ability_key) + "/wake_word/samples", "GET", function(data) {
+            wakeWord.samples[a.key] = data;
+            wakeWord.render();
+        });
+    },
+    render: function() {
+        let html = `<h2>Wake word</h2>`;
+        let as = wakeWord.abilities();
+        for (let i = 0; i < as.length; i++) {
+            let a = as[i];
+            let ss = wakeWord.samples[a.key];
+            if (typeof ss === "undefined") {
+                continue;
+            }
+            let url = labeling.abilityURL(a.brain_key, a.ability_key);
+            html += `<h3>` + labeling.escape(a.name) + `</h3>
+            <p>
+                <i class="fa fa-microphone btn-label" onclick="wakeWord.enroll('` + a.key + `')"></i> Enroll the next utterance
+                <input type="file" accept=".wav,audio/wav" onchange="wakeWord.upload('` + a.key + `', this)" title="Enroll a wav file">
+            </p>`;
+            if (ss.length === 0) {
+                html += `<p>No samples</p>`;
+                continue;
+            }
+            html += `<div class="table stats">
+                <div class="row header">
+                    <div class="cell">Enrolled</div>
+                    <div class="cell">Duration</div>
+                    <div class="cell">Audio</div>
+                    <div class="cell"></div>
+                </div>`;
+            for (let j = 0; j < ss.length; j++) {
+                let s = ss[j];
+                html += `<div class="row">
+                    <div class="cell">` + new Date(s.created_at).toLocaleString() + `</div>
+                    <div class="cell">` + labeling.formatDuration(s.duration) + `</div>
+                    <div class="cell"><audio controls preload="none" src="` + url + `/wake_word/samples/` + s.id + `/wav"></audio></div>
+                    <div class="cell"><i class="fa fa-trash btn-label" onclick="wakeWord.delete('` + a.key + `', '` + s.id + `')" title="Delete"></i></div>
+                </div>`;
+            }
+            html += `</div>`;
+        }
+        $("#wake-word").html(html);
+    },
+    ability: function(key) {
+        let as = wakeWord.abilities();
+        for (let i = 0; i < as.length; i++) {
+            if (as[i].key === key) {
+                return as[i];
+            }
+        }
+    },
+    delete: function(key, id) {
+        let a = wakeWord.ability(key);
+        base.sendHttp(labeling.abilityURL(a.brain_key, a.ability_key) + "/wake_word/samples/" + id, "DELETE", function() {
+            wakeWord.fetchAbility(a);
+        });
+    },
+    enroll: function(key) {
+        let a = wakeWord.ability(key);
+        base.sendHttp(labeling.abilityURL(a.brain_key, a.ability_key) + "/wake_word/enroll", "POST", function() {
+            asticode.notifier.info("Say the wake word, the next utterance will be enrolled");
+            setTimeout(function() { wakeWord.fetchAbility(a); }, 5000);
+        });
+    },
+    upload: function(key, input) {
+        let a = wakeWord.ability(key);
+        if (input.files.length === 0) {
+            return;
+        }
+        $.ajax({
+            url: labeling.abilityURL(a.brain_key, a.ability_key) + "/wake_word/samples",
+            type: "POST",
+            contentType: "audio/wav",
+            data: input.files[0],
+            dataType: "json",
+            processData: false,
+            error: function(jqXHR) {
+                asticode.notifier.error(typeof jqXHR.responseJSON !== "undefined" ? jqXHR.responseJSON.message : "Enrolling failed");
+            },
+            success: function() {
+                wakeWord.fetchAbility(a);
+            }
+        });
+    },
+    webSocketFunc: function(event_name, payload) {
+        switch (event_name) {
+            case consts.webSocket.eventNames.brainDisconnected:
+            case consts.webSocket.eventNames.brainRegistered:
+                wakeWord.fetch();
+                break;
+        }
+    }
+};
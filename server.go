@@ -2,16 +2,22 @@ package astibob
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/asticode/go-astilog"
+	"github.com/asticode/go-astitools/http"
 	"github.com/asticode/go-astiws"
+	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
 )
 
 // server represents a server
 type server struct {
+	l    net.Listener
+	m    sync.Mutex // Locks l and o
 	name string
 	o    ServerOptions
 	s    *http.Server
@@ -20,19 +26,22 @@ type server struct {
 
 // ServerOptions are server options
 type ServerOptions struct {
-	ListenAddr string
-	Password   string
-	PublicAddr string
-	Timeout    time.Duration
-	Username   string
+	ListenAddr string        `toml:"listen_addr"`
+	Password   string        `toml:"password"`
+	PublicAddr string        `toml:"public_addr"`
+	Timeout    time.Duration `toml:"timeout"`
+	Username   string        `toml:"username"`
 }
 
+// webSocketMaxMessageSize is the websocket max message size, it needs to be big enough for audio frames
+const webSocketMaxMessageSize = 1 << 20
+
 // newServer creates a new server
 func newServer(name string, o ServerOptions) *server {
 	return &server{
 		name: name,
 		o:    o,
-		ws:   astiws.NewManager(4096),
+		ws:   astiws.NewManager(webSocketMaxMessageSize),
 	}
 }
 
@@ -41,6 +50,36 @@ func (s *server) setHandler(h http.Handler) {
 	s.s = &http.Server{Addr: s.o.ListenAddr, Handler: h}
 }
 
+// options returns the server options
+func (s *server) options() ServerOptions {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.o
+}
+
+// setOptions sets the server options.
+// Only options read at request time are taken into account: the listen addr needs a restart.
+func (s *server) setOptions(o ServerOptions) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.o = o
+}
+
+// middlewareBasicAuth adds basic auth based on the current options so that credentials can be reloaded
+func (s *server) middlewareBasicAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		o := s.options()
+		astihttp.MiddlewareBasicAuth(o.Username, o.Password)(h).ServeHTTP(rw, r)
+	})
+}
+
+// routerMiddlewareTimeout adds a timeout based on the current options so that it can be reloaded
+func (s *server) routerMiddlewareTimeout(h httprouter.Handle) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		astihttp.RouterMiddlewareTimeout(s.options().Timeout)(h)(rw, r, p)
+	}
+}
+
 // Close implements the io.Closer interface
 func (s *server) Close() (err error) {
 	// Close ws
@@ -54,16 +93,50 @@ func (s *server) Close() (err error) {
 	if err = s.s.Shutdown(context.Background()); err != nil {
 		astilog.Error(errors.Wrapf(err, "shutting down %s server serving failed", s.name))
 	}
+
+	// Close listener in case it has not been served, it is already closed otherwise
+	s.m.Lock()
+	if s.l != nil {
+		s.l.Close()
+	}
+	s.m.Unlock()
 	return
 }
 
+// listen listens on the listen addr
+func (s *server) listen() (err error) {
+	// Listen
+	var l net.Listener
+	if l, err = net.Listen("tcp", s.s.Addr); err != nil {
+		err = errors.Wrapf(err, "astibob: listening on %s failed", s.s.Addr)
+		return
+	}
+
+	// Store listener
+	s.m.Lock()
+	s.l = l
+	s.m.Unlock()
+	return
+}
+
+// addr returns the addr the server is listening on or an empty string if it's not listening yet
+func (s *server) addr() string {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.l == nil {
+		return ""
+	}
+	return s.l.Addr().String()
+}
+
 // run runs the server
+// listen must have been called first.
 func (s *server) run() (err error) {
 	// Run
-	astilog.Infof("astibob: running %s server on %s", s.name, s.s.Addr)
-	if err = s.s.ListenAndServe(); err != nil {
-		err = errors.Wrapf(err, "astibob: running %s server failed")
+	astilog.Infof("astibob: running %s server on %s", s.name, s.addr())
+	if err = s.s.Serve(s.l); err != nil && err != http.ErrServerClosed {
+		err = errors.Wrapf(err, "astibob: running %s server failed", s.name)
 		return
 	}
-	return
+	return nil
 }
@@ -2,8 +2,11 @@ package astibob
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
 
+	"github.com/asticode/go-astibob/brain"
 	"github.com/asticode/go-astilog"
 	"github.com/asticode/go-astitools/http"
 	"github.com/asticode/go-astiws"
@@ -14,25 +17,43 @@ import (
 // brainsServer is a server for the brains
 type brainsServer struct {
 	*server
-	brains *brains
+	apiRequests  *abilityAPIRequests
+	audioOutputs *audioHub
+	audioStreams *audioHub
+	brains       *brains
+	dispatch     dispatchFunc
+	ms           sync.Mutex // Locks sequences
+	sequences    map[string]brainSequence
+}
+
+// brainSequence represents the last event received from a brain
+type brainSequence struct {
+	sequence uint64
+	session  string
 }
 
 // newBrainsServer creates a new brains server.
-func newBrainsServer(brains *brains, o ServerOptions) (s *brainsServer) {
+func newBrainsServer(brains *brains, audioStreams, audioOutputs *audioHub, apiRequests *abilityAPIRequests, dispatch dispatchFunc, o ServerOptions) (s *brainsServer) {
 	// Create server
 	s = &brainsServer{
-		brains: brains,
-		server: newServer("brains", o),
+		apiRequests:  apiRequests,
+		audioOutputs: audioOutputs,
+		audioStreams: audioStreams,
+		brains:       brains,
+		dispatch:     dispatch,
+		sequences:    make(map[string]brainSequence),
+		server:       newServer("brains", o),
 	}
 
 	// Init router
 	var r = httprouter.New()
 
-	// Websocket
+	// Websockets
 	r.GET("/websocket", s.handleWebsocketGET)
+	r.GET("/websocket"+astibrain.AudioWebSocketPath, s.handleAudioWebsocketGET)
 
 	// Chain middlewares
-	var h = astihttp.ChainMiddlewares(r, astihttp.MiddlewareBasicAuth(o.Username, o.Password))
+	var h = astihttp.ChainMiddlewares(r, s.middlewareBasicAuth)
 
 	// Set handler
 	s.setHandler(h)
@@ -41,17 +62,301 @@ func newBrainsServer(brains *brains, o ServerOptions) (s *brainsServer) {
 
 // handleWebsocketGET handles the websockets.
 func (s *brainsServer) handleWebsocketGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	if err := s.ws.ServeHTTP(rw, r, s.adaptWebsocketClient); err != nil {
-		astilog.Error(errors.Wrapf(err, "astibob: handling webrainsocket on %s failed", s.s.Addr))
+	// Serve
+	// The brain is set once the register event has been received
+	var b *brain
+	if err := s.ws.ServeHTTP(rw, r, func(c *astiws.Client) { s.adaptWebsocketClient(c, &b) }); err != nil {
+		astilog.Error(errors.Wrapf(err, "astibob: handling brains websocket on %s failed", s.s.Addr))
 		rw.WriteHeader(http.StatusInternalServerError)
-		return
+	}
+
+	// Unregister brain
+	if b != nil {
+		astilog.Infof("astibob: brain %s has disconnected", b.name)
+		s.brains.del(b.name)
+		b.setAudio(nil)
+		s.audioOutputs.delBrain(b)
+		s.audioStreams.delBrain(b)
+		s.dispatch(ClientsWebsocketEventNameBrainDisconnected, b.toAPI())
 	}
 }
 
-// ClientAdapter returns the client adapter.
-func (s *brainsServer) adaptWebsocketClient(c *astiws.Client) {
-	// TODO Register on connect with brain's name
-	c.AddListener(clientsWebsocketEventNamePing, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+// adaptWebsocketClient adapts the websocket client.
+func (s *brainsServer) adaptWebsocketClient(c *astiws.Client, b **brain) {
+	c.AddListener(ClientsWebsocketEventNamePing, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
 		return c.HandlePing()
 	})
+	c.AddListener(astibrain.WebsocketEventNameRegister, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		return s.handleRegister(c, payload, b)
+	})
+	c.AddListener(astibrain.WebsocketEventNameAbilityAPIResponse, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		return s.handleAbilityAPIResponse(payload)
+	})
+	c.AddListener(astibrain.WebsocketEventNameAbilityCrashed, s.brainListener(b, s.handleAbilityCrashed))
+	c.AddListener(astibrain.WebsocketEventNameAbilityHealth, s.brainListener(b, s.handleAbilityHealth))
+	c.AddListener(astibrain.WebsocketEventNameAbilityTransitioned, s.brainListener(b, s.handleAbilityTransitioned))
+	c.AddListener(astibrain.WebsocketEventNameAudioOutput, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		return s.handleAudioOutput(*b, payload)
+	})
+	c.AddListener(astibrain.WebsocketEventNameStats, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		return s.handleStats(*b, payload)
+	})
+}
+
+// brainListener creates a listener that makes sure the brain is registered, unwraps the event sent by the brain
+// and ignores events that have already been received.
+func (s *brainsServer) brainListener(b **brain, fn func(b *brain, payload json.RawMessage) error) astiws.ListenerFunc {
+	return func(c *astiws.Client, eventName string, payload json.RawMessage) (err error) {
+		// Brain is not registered
+		if *b == nil {
+			err = fmt.Errorf("astibob: brain is not registered, ignoring %s event", eventName)
+			return
+		}
+
+		// Decode payload
+		var e astibrain.WebSocketEvent
+		if err = json.Unmarshal(payload, &e); err != nil {
+			err = errors.Wrapf(err, "astibob: json unmarshaling %s payload %s failed", eventName, payload)
+			return
+		}
+
+		// Event has already been received
+		if !s.isNewEvent((*b).name, e) {
+			astilog.Debugf("astibob: ignoring duplicate %s event #%d of brain %s", eventName, e.Sequence, (*b).name)
+			return
+		}
+
+		// Handle
+		return fn(*b, e.Payload)
+	}
+}
+
+// isNewEvent checks whether an event has not already been received and updates the brain's last sequence
+func (s *brainsServer) isNewEvent(name string, e astibrain.WebSocketEvent) bool {
+	s.ms.Lock()
+	defer s.ms.Unlock()
+	if v, ok := s.sequences[name]; ok && v.session == e.Session && e.Sequence <= v.sequence {
+		return false
+	}
+	s.sequences[name] = brainSequence{
+		sequence: e.Sequence,
+		session:  e.Session,
+	}
+	return true
+}
+
+// handleRegister handles the register websocket event
+func (s *brainsServer) handleRegister(c *astiws.Client, payload json.RawMessage, b **brain) (err error) {
+	// Decode payload
+	var p astibrain.WebSocketRegister
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibob: json unmarshaling register payload %s failed", payload)
+		return
+	}
+
+	// Brain is already registered
+	if _, ok := s.brains.brain(p.Name); ok {
+		err = fmt.Errorf("astibob: brain %s is already registered", p.Name)
+		return
+	}
+
+	// Register brain
+	*b = newBrainFromWebSocket(p, c)
+	s.brains.set(*b)
+	astilog.Infof("astibob: brain %s has registered", p.Name)
+
+	// Dispatch
+	s.dispatch(ClientsWebsocketEventNameBrainRegistered, (*b).toAPI())
+	return
+}
+
+// handleAbilityCrashed handles the ability.crashed websocket event
+func (s *brainsServer) handleAbilityCrashed(b *brain, payload json.RawMessage) (err error) {
+	// Decode payload
+	var p astibrain.WebSocketAbilityCrashed
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibob: json unmarshaling ability.crashed payload %s failed", payload)
+		return
+	}
+
+	// Retrieve ability
+	a, ok := b.ability(p.Name)
+	if !ok {
+		err = fmt.Errorf("astibob: unknown ability %s for brain %s", p.Name, b.name)
+		return
+	}
+
+	// Dispatch
+	s.dispatch(ClientsWebsocketEventNameAbilityCrashed, APIAbilityCrashed{
+		AbilityKey: a.key,
+		BrainKey:   b.key,
+		Error:      p.Error,
+	})
+	return
+}
+
+// handleAbilityHealth handles the ability.health websocket event
+func (s *brainsServer) handleAbilityHealth(b *brain, payload json.RawMessage) (err error) {
+	// Decode payload
+	var p astibrain.WebSocketAbilityHealth
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibob: json unmarshaling ability.health payload %s failed", payload)
+		return
+	}
+
+	// Retrieve ability
+	a, ok := b.ability(p.Name)
+	if !ok {
+		err = fmt.Errorf("astibob: unknown ability %s for brain %s", p.Name, b.name)
+		return
+	}
+
+	// Update health
+	a.setHealth(p.Status)
+
+	// Dispatch
+	s.dispatch(ClientsWebsocketEventNameAbilityHealth, APIAbilityHealth{
+		AbilityKey: a.key,
+		BrainKey:   b.key,
+		Error:      p.Error,
+		Status:     string(p.Status),
+	})
+	return
+}
+
+// handleAbilityTransitioned handles the ability.transitioned websocket event
+func (s *brainsServer) handleAbilityTransitioned(b *brain, payload json.RawMessage) (err error) {
+	// Decode payload
+	var p astibrain.WebSocketAbilityTransition
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibob: json unmarshaling ability.transitioned payload %s failed", payload)
+		return
+	}
+
+	// Retrieve ability
+	a, ok := b.ability(p.Name)
+	if !ok {
+		err = fmt.Errorf("astibob: unknown ability %s for brain %s", p.Name, b.name)
+		return
+	}
+
+	// Update state
+	a.setState(p.To)
+
+	// Dispatch
+	s.dispatch(ClientsWebsocketEventNameAbilityTransitioned, APIAbilityTransition{
+		AbilityKey: a.key,
+		BrainKey:   b.key,
+		From:       string(p.From),
+		IsOn:       p.To.IsOn(),
+		To:         string(p.To),
+	})
+	return
+}
+
+// handleAudioWebsocketGET handles the websocket dedicated to a registered brain's audio frames.
+// Frames are exchanged as binary messages: the brain sends its audio streams and receives its audio inputs.
+func (s *brainsServer) handleAudioWebsocketGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve brain
+	var name = r.URL.Query().Get("name")
+	b, ok := s.brains.brain(name)
+	if !ok {
+		astibrain.APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astibob: unknown brain %s", name))
+		return
+	}
+
+	// Upgrade
+	a, err := upgradeAudio(rw, r, "brain "+name)
+	if err != nil {
+		astilog.Error(err)
+		return
+	}
+	defer a.Close()
+
+	// Store audio websocket
+	b.setAudio(a)
+	defer b.delAudio(a)
+
+	// Read
+	if err = a.Read(func(f astibrain.AudioFrame) {
+		if err := s.handleAudioFrame(b, f); err != nil {
+			astilog.Error(errors.Wrap(err, "astibob: handling audio frame failed"))
+		}
+	}); err != nil {
+		astilog.Error(errors.Wrapf(err, "astibob: reading audio websocket of brain %s failed", name))
+	}
+}
+
+// handleAudioFrame handles an audio frame sent by a brain.
+// Audio frames are live data and are therefore not wrapped nor deduplicated.
+func (s *brainsServer) handleAudioFrame(b *brain, f astibrain.AudioFrame) (err error) {
+	// Retrieve ability
+	a, ok := b.ability(f.Name)
+	if !ok {
+		err = fmt.Errorf("astibob: unknown ability %s for brain %s", f.Name, b.name)
+		return
+	}
+
+	// Dispatch
+	// Frames are named after the audio key for clients
+	f.Name = audioKey(b.key, a.key)
+	s.audioStreams.dispatch(b, a.name, f)
+	return
+}
+
+// handleAudioOutput handles the audio.output websocket event
+// Audio outputs are live data and are therefore not wrapped nor deduplicated.
+func (s *brainsServer) handleAudioOutput(b *brain, payload json.RawMessage) (err error) {
+	// Brain is not registered
+	if b == nil {
+		err = errors.New("astibob: brain is not registered, ignoring audio.output event")
+		return
+	}
+
+	// Decode payload
+	var p astibrain.WebSocketAudioOutput
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrap(err, "astibob: json unmarshaling audio.output payload failed")
+		return
+	}
+
+	// Dispatch
+	s.audioOutputs.dispatch(b, p.Name, APIAudioOutput{
+		BrainKey: b.key,
+		Data:     p.Data,
+		Name:     p.Name,
+	})
+	return
+}
+
+// handleStats handles the stats websocket event
+// Stats are live data and are therefore not wrapped nor deduplicated.
+func (s *brainsServer) handleStats(b *brain, payload json.RawMessage) (err error) {
+	// Brain is not registered
+	if b == nil {
+		err = errors.New("astibob: brain is not registered, ignoring stats event")
+		return
+	}
+
+	// Decode payload
+	var p astibrain.Stats
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrap(err, "astibob: json unmarshaling stats payload failed")
+		return
+	}
+
+	// Update brain
+	b.setStats(p)
+
+	// Dispatch
+	var o = APIBrainStats{
+		Abilities: make(map[string]astibrain.AbilityStats),
+		BrainKey:  b.key,
+		Runtime:   p.Runtime,
+	}
+	for n, as := range p.Abilities {
+		o.Abilities[abilityKey(n)] = as
+	}
+	s.dispatch(ClientsWebsocketEventNameBrainStats, o)
+	return
 }
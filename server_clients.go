@@ -1,11 +1,16 @@
 package astibob
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"path/filepath"
+	"sync"
 	"text/template"
+	"time"
 
+	"github.com/asticode/go-astibob/brain"
 	"github.com/asticode/go-astilog"
 	"github.com/asticode/go-astitools/http"
 	"github.com/asticode/go-astiws"
@@ -15,23 +20,43 @@ import (
 
 // Clients websocket events
 const (
-	clientsWebsocketEventNamePing = "ping"
+	ClientsWebsocketEventNameAbilityCrashed         = "ability.crashed"
+	ClientsWebsocketEventNameAbilityHealth          = "ability.health"
+	ClientsWebsocketEventNameAbilityTransitioned    = "ability.transitioned"
+	ClientsWebsocketEventNameAudioOutput            = "audio.output"
+	ClientsWebsocketEventNameAudioOutputSubscribe   = "audio.output.subscribe"
+	ClientsWebsocketEventNameAudioOutputUnsubscribe = "audio.output.unsubscribe"
+	ClientsWebsocketEventNameBrainDisconnected      = "brain.disconnected"
+	ClientsWebsocketEventNameBrainRegistered        = "brain.registered"
+	ClientsWebsocketEventNameBrainStats             = "brain.stats"
+	ClientsWebsocketEventNamePing                   = "ping"
 )
 
 // clientsServer is a server for the clients
 type clientsServer struct {
 	*server
-	brains   *brains
-	stopFunc func()
+	apiRequests  *abilityAPIRequests
+	audioOutputs *audioHub
+	audioStreams *audioHub
+	brains       *brains
+	reloadFunc   func() (ReloadReport, error)
+	stopFunc     func()
 }
 
+// maxAudioRecordDuration is the max duration of an audio recording
+const maxAudioRecordDuration = 5 * time.Minute
+
 // newClientsServer creates a new clients server.
-func newClientsServer(t map[string]*template.Template, brains *brains, stopFunc func(), o Options) (s *clientsServer) {
+func newClientsServer(t map[string]*template.Template, brains *brains, audioStreams, audioOutputs *audioHub, apiRequests *abilityAPIRequests, stopFunc func(), reloadFunc func() (ReloadReport, error), o Options) (s *clientsServer) {
 	// Create server
 	s = &clientsServer{
-		brains:   brains,
-		server:   newServer("clients", o.ClientsServer),
-		stopFunc: stopFunc,
+		apiRequests:  apiRequests,
+		audioOutputs: audioOutputs,
+		audioStreams: audioStreams,
+		brains:       brains,
+		server:       newServer("clients", o.ClientsServer),
+		reloadFunc:   reloadFunc,
+		stopFunc:     stopFunc,
 	}
 
 	// Init router
@@ -44,17 +69,28 @@ func newClientsServer(t map[string]*template.Template, brains *brains, stopFunc
 	r.GET("/", s.handleHomepageGET)
 	r.GET("/web/*page", astihttp.ChainRouterMiddlewares(
 		s.handleWebGET(t),
-		astihttp.RouterMiddlewareTimeout(o.ClientsServer.Timeout),
+		s.routerMiddlewareTimeout,
 		astihttp.RouterMiddlewareContentType("text/html; charset=UTF-8"),
 	))
 
 	// Websockets
+	// Audio is exchanged as binary frames through websockets dedicated to one audio stream or input
 	r.GET("/websocket", s.handleWebsocketGET)
+	r.GET("/websocket/audio/inputs/:brain/:name", s.handleAudioInputWebsocketGET)
+	r.GET("/websocket/audio/streams/:brain/:ability", s.handleAudioStreamWebsocketGET)
 
 	// API
 	r.GET("/api/bob", astihttp.ChainRouterMiddlewares(s.handleAPIBobGET, astihttp.RouterMiddlewareContentType("application/json")))
+	r.GET("/api/bob/reload", astihttp.ChainRouterMiddlewares(s.handleAPIBobReloadGET, astihttp.RouterMiddlewareContentType("application/json")))
 	r.GET("/api/bob/stop", s.handleAPIBobStopGET)
+	r.GET("/api/dataset", s.handleAPIDatasetGET)
 	r.GET("/api/references", astihttp.ChainRouterMiddlewares(s.handleAPIReferencesGET, astihttp.RouterMiddlewareContentType("application/json")))
+	for _, m := range []string{http.MethodDelete, http.MethodGet, http.MethodPatch, http.MethodPost, http.MethodPut} {
+		r.Handle(m, "/api/brains/:brain/abilities/:ability/api/*path", s.handleAPIAbilityAPI)
+	}
+	r.GET("/api/brains/:brain/abilities/:ability/audio/record", s.handleAPIAudioRecordGET)
+	r.POST("/api/brains/:brain/abilities/:ability/start", astihttp.ChainRouterMiddlewares(s.handleAPIAbilityTogglePOST(astibrain.WebsocketEventNameAbilityStart), astihttp.RouterMiddlewareContentType("application/json")))
+	r.POST("/api/brains/:brain/abilities/:ability/stop", astihttp.ChainRouterMiddlewares(s.handleAPIAbilityTogglePOST(astibrain.WebsocketEventNameAbilityStop), astihttp.RouterMiddlewareContentType("application/json")))
 
 	// Abilities
 	// TODO
@@ -66,7 +102,7 @@ func newClientsServer(t map[string]*template.Template, brains *brains, stopFunc
 	*/
 
 	// Chain middlewares
-	var h = astihttp.ChainMiddlewares(r, astihttp.MiddlewareBasicAuth(o.ClientsServer.Username, o.ClientsServer.Password))
+	var h = astihttp.ChainMiddlewares(r, s.middlewareBasicAuth)
 
 	// Set handler
 	s.setHandler(h)
@@ -116,41 +152,227 @@ func (s *clientsServer) templateData(r *http.Request, p httprouter.Params, name
 
 // handleWebsocketGET handles the websockets.
 func (s *clientsServer) handleWebsocketGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	if err := s.ws.ServeHTTP(rw, r, s.adaptWebsocketClient); err != nil {
+	// Serve
+	var client *astiws.Client
+	var subs = newClientAudioSubscriptions()
+	if err := s.ws.ServeHTTP(rw, r, func(c *astiws.Client) {
+		client = c
+		s.adaptWebsocketClient(c, subs)
+	}); err != nil {
 		astilog.Error(errors.Wrapf(err, "astibob: handling websocket on %s failed", s.s.Addr))
 		rw.WriteHeader(http.StatusInternalServerError)
-		return
 	}
+
+	// Unregister client
+	if client != nil {
+		s.ws.UnregisterClient(client)
+	}
+
+	// Unsubscribe
+	subs.close()
 }
 
-// ClientAdapter returns the client adapter.
-func (s *clientsServer) adaptWebsocketClient(c *astiws.Client) {
-	// TODO Register on connect with brain's name
-	c.AddListener(clientsWebsocketEventNamePing, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+// adaptWebsocketClient adapts the websocket client.
+func (s *clientsServer) adaptWebsocketClient(c *astiws.Client, subs *clientAudioSubscriptions) {
+	// Register client so that it receives events
+	s.ws.RegisterClient(c, c)
+
+	// Add listeners
+	c.AddListener(ClientsWebsocketEventNamePing, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
 		return c.HandlePing()
 	})
+	c.AddListener(ClientsWebsocketEventNameAudioOutputSubscribe, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		return s.handleAudioOutputSubscribe(c, payload, subs)
+	})
+	c.AddListener(ClientsWebsocketEventNameAudioOutputUnsubscribe, func(c *astiws.Client, eventName string, payload json.RawMessage) error {
+		return s.handleAudioOutputUnsubscribe(payload, subs)
+	})
+}
+
+// clientAudioSubscriptions represents the audio subscriptions of a websocket client
+type clientAudioSubscriptions struct {
+	m sync.Mutex        // Locks u
+	u map[string]func() // Unsubscribe funcs indexed by subscription key
 }
 
-// APIError represents an API error.
-type APIError struct {
-	Message string `json:"message"`
+// newClientAudioSubscriptions creates new client audio subscriptions
+func newClientAudioSubscriptions() *clientAudioSubscriptions {
+	return &clientAudioSubscriptions{u: make(map[string]func())}
+}
+
+// close unsubscribes from everything
+func (s *clientAudioSubscriptions) close() {
+	s.m.Lock()
+	defer s.m.Unlock()
+	for k, fn := range s.u {
+		fn()
+		delete(s.u, k)
+	}
+}
+
+// APIAudioOutputSubscription represents an audio output subscription.
+type APIAudioOutputSubscription struct {
+	BrainKey string `json:"brain_key"`
+	Name     string `json:"name"`
+}
+
+// handleAudioOutputSubscribe handles the audio.output.subscribe websocket event
+func (s *clientsServer) handleAudioOutputSubscribe(c *astiws.Client, payload json.RawMessage, subs *clientAudioSubscriptions) (err error) {
+	// Decode payload
+	var p APIAudioOutputSubscription
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibob: json unmarshaling audio.output.subscribe payload %s failed", payload)
+		return
+	}
+
+	// Retrieve brain
+	b, ok := s.brains.brainByKey(p.BrainKey)
+	if !ok {
+		err = fmt.Errorf("astibob: unknown brain %s", p.BrainKey)
+		return
+	}
+
+	// Unknown output
+	if _, ok = b.audioOutputs[p.Name]; !ok {
+		err = fmt.Errorf("astibob: unknown audio output %s for brain %s", p.Name, b.name)
+		return
+	}
+
+	// Lock
+	subs.m.Lock()
+	defer subs.m.Unlock()
+
+	// Already subscribed, the brain may have reconnected in the meantime so we subscribe again
+	var k = "output/" + audioKey(p.BrainKey, p.Name)
+	if fn, ok := subs.u[k]; ok {
+		fn()
+	}
+
+	// Subscribe
+	if subs.u[k], _, err = s.audioOutputs.subscribe(b, p.Name, func(o interface{}) {
+		if err := c.Write(ClientsWebsocketEventNameAudioOutput, o); err != nil {
+			astilog.Error(errors.Wrap(err, "astibob: writing audio output to websocket client failed"))
+		}
+	}); err != nil {
+		delete(subs.u, k)
+		err = errors.Wrapf(err, "astibob: subscribing to audio output %s failed", k)
+		return
+	}
+	return
 }
 
-// APIWriteError writes an API error
-func APIWriteError(rw http.ResponseWriter, code int, err error) {
-	rw.WriteHeader(code)
-	astilog.Error(err)
-	if err := json.NewEncoder(rw).Encode(APIError{Message: err.Error()}); err != nil {
-		astilog.Error(errors.Wrap(err, "astibob: json encoding failed"))
+// handleAudioOutputUnsubscribe handles the audio.output.unsubscribe websocket event
+func (s *clientsServer) handleAudioOutputUnsubscribe(payload json.RawMessage, subs *clientAudioSubscriptions) (err error) {
+	// Decode payload
+	var p APIAudioOutputSubscription
+	if err = json.Unmarshal(payload, &p); err != nil {
+		err = errors.Wrapf(err, "astibob: json unmarshaling audio.output.unsubscribe payload %s failed", payload)
+		return
+	}
+
+	// Unsubscribe
+	subs.m.Lock()
+	defer subs.m.Unlock()
+	var k = "output/" + audioKey(p.BrainKey, p.Name)
+	if fn, ok := subs.u[k]; ok {
+		fn()
+		delete(subs.u, k)
 	}
+	return
 }
 
-// APIWrite writes API data
-func APIWrite(rw http.ResponseWriter, data interface{}) {
-	if err := json.NewEncoder(rw).Encode(data); err != nil {
-		APIWriteError(rw, http.StatusInternalServerError, errors.Wrap(err, "astibob: json encoding failed"))
+// handleAudioStreamWebsocketGET handles a websocket streaming an ability's audio frames to a client until either side
+// closes it
+func (s *clientsServer) handleAudioStreamWebsocketGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve brain and ability
+	b, a, err := s.brainAbility(p.ByName("brain"), p.ByName("ability"))
+	if err != nil {
+		astibrain.APIWriteError(rw, http.StatusNotFound, err)
+		return
+	}
+
+	// Upgrade
+	w, err := upgradeAudio(rw, r, "client stream "+audioKey(b.key, a.key))
+	if err != nil {
+		astilog.Error(err)
+		return
+	}
+	defer w.Close()
+
+	// Subscribe
+	unsubscribe, done, err := s.audioStreams.subscribe(b, a.name, func(f interface{}) { w.Write(f.(astibrain.AudioFrame)) })
+	if err != nil {
+		astilog.Error(errors.Wrapf(err, "astibob: subscribing to audio stream %s failed", audioKey(b.key, a.key)))
 		return
 	}
+	defer unsubscribe()
+
+	// Close the websocket once unsubscribed, which happens when the brain disconnects
+	go func() {
+		select {
+		case <-done:
+			w.Close()
+		case <-w.Done():
+		}
+	}()
+
+	// Read until the client closes the websocket
+	if err = w.Read(func(astibrain.AudioFrame) {}); err != nil {
+		astilog.Debug(errors.Wrap(err, "astibob: reading client audio stream websocket failed"))
+	}
+}
+
+// handleAudioInputWebsocketGET handles a websocket through which a client sends audio frames to a brain's audio input
+func (s *clientsServer) handleAudioInputWebsocketGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve brain
+	b, ok := s.brains.brainByKey(p.ByName("brain"))
+	if !ok {
+		astibrain.APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astibob: unknown brain %s", p.ByName("brain")))
+		return
+	}
+
+	// Unknown input
+	var name = p.ByName("name")
+	if _, ok = b.audioInputs[name]; !ok {
+		astibrain.APIWriteError(rw, http.StatusNotFound, fmt.Errorf("astibob: unknown audio input %s for brain %s", name, b.name))
+		return
+	}
+
+	// Upgrade
+	w, err := upgradeAudio(rw, r, "client input "+audioKey(b.key, name))
+	if err != nil {
+		astilog.Error(err)
+		return
+	}
+	defer w.Close()
+
+	// Forward frames until the client closes the websocket
+	// Frames are sent to the input the websocket has been opened for whatever their name
+	if err = w.Read(func(f astibrain.AudioFrame) {
+		f.Name = name
+		if err := b.sendAudio(f); err != nil {
+			astilog.Error(errors.Wrap(err, "astibob: forwarding audio input failed"))
+		}
+	}); err != nil {
+		astilog.Debug(errors.Wrap(err, "astibob: reading client audio input websocket failed"))
+	}
+}
+
+// brainAbility retrieves a brain and one of its abilities based on their keys
+func (s *clientsServer) brainAbility(brainKey, abilityKey string) (b *brain, a *ability, err error) {
+	// Retrieve brain
+	var ok bool
+	if b, ok = s.brains.brainByKey(brainKey); !ok {
+		err = fmt.Errorf("astibob: unknown brain %s", brainKey)
+		return
+	}
+
+	// Retrieve ability
+	if a, ok = b.abilityByKey(abilityKey); !ok {
+		err = fmt.Errorf("astibob: unknown ability %s for brain %s", abilityKey, brainKey)
+		return
+	}
+	return
 }
 
 // APIBob represents Bob.
@@ -160,39 +382,97 @@ type APIBob struct {
 
 // APIBrain represents a brain
 type APIBrain struct {
-	Abilities map[string]APIAbility `json:"abilities,omitempty"`
-	Name      string                `json:"name"`
+	Abilities    map[string]APIAbility            `json:"abilities,omitempty"`
+	AudioInputs  map[string]astibrain.AudioFormat `json:"audio_inputs,omitempty"`
+	AudioOutputs []string                         `json:"audio_outputs,omitempty"`
+	Key          string                           `json:"key"`
+	Name         string                           `json:"name"`
+	Runtime      *astibrain.RuntimeStats          `json:"runtime,omitempty"`
 }
 
 // APIAbility represents an ability.
 type APIAbility struct {
-	IsOn bool   `json:"is_on"`
-	Name string `json:"name"`
+	API         bool                    `json:"api,omitempty"` // Set if the ability exposes an API
+	AudioFormat *astibrain.AudioFormat  `json:"audio_format,omitempty"`
+	Health      string                  `json:"health"`
+	IsOn        bool                    `json:"is_on"`
+	Key         string                  `json:"key"`
+	Name        string                  `json:"name"`
+	State       string                  `json:"state"`
+	Stats       *astibrain.AbilityStats `json:"stats,omitempty"`
+}
+
+// APIBrainStats represents a brain's stats.
+type APIBrainStats struct {
+	Abilities map[string]astibrain.AbilityStats `json:"abilities"` // Indexed by ability key
+	BrainKey  string                            `json:"brain_key"`
+	Runtime   astibrain.RuntimeStats            `json:"runtime"`
+}
+
+// APIAudioOutput represents a rendered audio sent by a brain to be played by clients.
+type APIAudioOutput struct {
+	BrainKey string `json:"brain_key"`
+	Data     []byte `json:"data"` // WAV file
+	Name     string `json:"name"`
+}
+
+// APIAbilityHealth represents an ability health change.
+type APIAbilityHealth struct {
+	AbilityKey string `json:"ability_key"`
+	BrainKey   string `json:"brain_key"`
+	Error      string `json:"error,omitempty"`
+	Status     string `json:"status"`
+}
+
+// APIAbilityTransition represents an ability transition.
+type APIAbilityTransition struct {
+	AbilityKey string `json:"ability_key"`
+	BrainKey   string `json:"brain_key"`
+	From       string `json:"from"`
+	IsOn       bool   `json:"is_on"`
+	To         string `json:"to"`
+}
+
+// APIAbilityCrashed represents an ability crash.
+type APIAbilityCrashed struct {
+	AbilityKey string `json:"ability_key"`
+	BrainKey   string `json:"brain_key"`
+	Error      string `json:"error"`
 }
 
 // handleAPIBobGET returns Bob's information.
 func (s *clientsServer) handleAPIBobGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	// Init data
-	d := APIBob{Brains: make(map[string]APIBrain)}
+	astibrain.APIWrite(rw, s.brains.toAPI())
+}
 
-	// Loop through brains
-	s.brains.brains(func(b *brain) error {
-		// Init brain data
-		bd := APIBrain{Name: b.name}
+// handleAPIAbilityTogglePOST asks a brain to start or stop an ability.
+// The ability state is updated once the brain has transitioned it.
+func (s *clientsServer) handleAPIAbilityTogglePOST(eventName string) httprouter.Handle {
+	return func(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		// Retrieve brain and ability
+		b, a, err := s.brainAbility(p.ByName("brain"), p.ByName("ability"))
+		if err != nil {
+			astibrain.APIWriteError(rw, http.StatusNotFound, err)
+			return
+		}
 
-		// Loop through abilities
-		b.abilities(func(a *ability) error {
-			bd.Abilities[a.key] = APIAbility{
-				IsOn: a.isOn,
-				Name: a.name,
-			}
-			return nil
-		})
-		return nil
-	})
+		// Send
+		if err = b.send(eventName, a.name); err != nil {
+			astibrain.APIWriteError(rw, http.StatusInternalServerError, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
 
-	// Write
-	APIWrite(rw, d)
+// handleAPIBobReloadGET reloads Bob's configuration and returns what has been applied and what requires a restart.
+func (s *clientsServer) handleAPIBobReloadGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	rp, err := s.reloadFunc()
+	if err != nil {
+		astibrain.APIWriteError(rw, http.StatusInternalServerError, errors.Wrap(err, "astibob: reloading failed"))
+		return
+	}
+	astibrain.APIWrite(rw, rp)
 }
 
 // handleAPIBobStopGET stops Bob.
@@ -208,8 +488,67 @@ type APIReferences struct {
 
 // handleAPIReferencesGET returns the references.
 func (s *clientsServer) handleAPIReferencesGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	APIWrite(rw, APIReferences{
-		WsURL:        "ws://" + s.o.PublicAddr + "/websocket",
+	astibrain.APIWrite(rw, APIReferences{
+		WsURL:        "ws://" + s.options().PublicAddr + "/websocket",
 		WsPingPeriod: int(astiws.PingPeriod.Seconds()),
 	})
 }
+
+// handleAPIAudioRecordGET records an ability's audio stream for the requested duration and returns it as a WAV file.
+func (s *clientsServer) handleAPIAudioRecordGET(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	// Retrieve brain and ability
+	b, a, err := s.brainAbility(p.ByName("brain"), p.ByName("ability"))
+	if err != nil {
+		astibrain.APIWriteError(rw, http.StatusNotFound, err)
+		return
+	}
+
+	// Ability doesn't stream audio
+	if a.audioFormat == nil {
+		astibrain.APIWriteError(rw, http.StatusBadRequest, fmt.Errorf("astibob: ability %s of brain %s doesn't stream audio", a.name, b.name))
+		return
+	}
+
+	// Parse duration
+	var d = 10 * time.Second
+	if v := r.URL.Query().Get("duration"); len(v) > 0 {
+		if d, err = time.ParseDuration(v); err != nil {
+			astibrain.APIWriteError(rw, http.StatusBadRequest, errors.Wrapf(err, "astibob: parsing duration %s failed", v))
+			return
+		}
+	}
+	if d <= 0 || d > maxAudioRecordDuration {
+		astibrain.APIWriteError(rw, http.StatusBadRequest, fmt.Errorf("astibob: duration should be between 0 and %s", maxAudioRecordDuration))
+		return
+	}
+
+	// Subscribe
+	var buf = &bytes.Buffer{}
+	var m sync.Mutex
+	unsubscribe, _, err := s.audioStreams.subscribe(b, a.name, func(f interface{}) {
+		m.Lock()
+		defer m.Unlock()
+		buf.Write(f.(astibrain.AudioFrame).Data)
+	})
+	if err != nil {
+		astibrain.APIWriteError(rw, http.StatusInternalServerError, errors.Wrap(err, "astibob: subscribing to audio stream failed"))
+		return
+	}
+
+	// Wait
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+	unsubscribe()
+
+	// Write
+	m.Lock()
+	defer m.Unlock()
+	rw.Header().Set("Content-Type", "audio/wav")
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.wav\"", b.key, a.key))
+	if err = writeWAV(rw, *a.audioFormat, buf.Bytes()); err != nil {
+		astilog.Error(errors.Wrap(err, "astibob: writing wav failed"))
+		return
+	}
+}
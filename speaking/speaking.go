@@ -11,11 +11,15 @@ import (
 	"github.com/pkg/errors"
 )
 
+// AudioFunc represents a function executed with the WAV file rendered when saying words
+type AudioFunc func(wav []byte)
+
 // Speaking represents an object capable of saying words to an audio output.
 type Speaking struct {
-	isMuted bool
-	o       Options
-	m       sync.Mutex
+	audioFuncs []AudioFunc
+	isMuted    bool
+	o          Options
+	m          sync.Mutex
 
 	// Windows
 	windowsIDispatch *ole.IDispatch
@@ -25,7 +29,9 @@ type Speaking struct {
 // Options represents speaking options.
 type Options struct {
 	BinaryPath string `toml:"binary_path"`
-	Voice      string `toml:"voice"`
+	// If true, words are rendered to a WAV file handed to the audio funcs instead of being played locally
+	Render bool   `toml:"render"`
+	Voice  string `toml:"voice"`
 }
 
 // New creates a new speaking
@@ -49,6 +55,14 @@ func (s *Speaking) Mute() {
 	s.isMuted = true
 }
 
+// OnAudio adds a function executed with the WAV file rendered each time words are said.
+// It is only used when the Render option is true.
+func (s *Speaking) OnAudio(fn AudioFunc) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.audioFuncs = append(s.audioFuncs, fn)
+}
+
 // Run implements the astibob.Ability interface
 func (s *Speaking) Run(ctx context.Context) (err error) {
 	// Handle muted attributed
@@ -69,12 +83,52 @@ func (s *Speaking) Run(ctx context.Context) (err error) {
 	return
 }
 
-// Say says words
+// CheckHealth implements the astibrain.HealthChecker interface
+func (s *Speaking) CheckHealth(ctx context.Context) (err error) {
+	if err = s.checkHealth(ctx); err != nil {
+		err = errors.Wrap(err, "astispeaking: checking health failed")
+		return
+	}
+	return
+}
+
+// Say says words.
+// Nothing is played nor rendered while muted.
 func (s *Speaking) Say(i string) (err error) {
+	// Get muted attribute
+	s.m.Lock()
+	m := s.isMuted
+	s.m.Unlock()
+
+	// Do nothing if muted
+	if m {
+		astilog.Debugf("astispeaking: muted, not saying \"%s\"", i)
+		return
+	}
 	astilog.Debugf("astispeaking: saying \"%s\"", i)
-	if err = s.say(i); err != nil {
-		err = errors.Wrapf(err, "saying \"%s\" failed", i)
+
+	// Play locally
+	if !s.o.Render {
+		if err = s.say(i); err != nil {
+			err = errors.Wrapf(err, "saying \"%s\" failed", i)
+			return
+		}
+		return
+	}
+
+	// Render
+	var b []byte
+	if b, err = s.render(i); err != nil {
+		err = errors.Wrapf(err, "astispeaking: rendering \"%s\" failed", i)
 		return
 	}
+
+	// Dispatch
+	s.m.Lock()
+	fs := append([]AudioFunc{}, s.audioFuncs...)
+	s.m.Unlock()
+	for _, fn := range fs {
+		fn(b)
+	}
 	return
 }
@@ -1,7 +1,24 @@
 package astispeaking
 
+import (
+	"context"
+	"errors"
+)
+
+// checkHealth checks whether speaking is healthy
+func (s *Speaking) checkHealth(ctx context.Context) (err error) {
+	// TODO
+	return
+}
+
 // say says words
 func (s *Speaking) say(i string) (err error) {
 	// TODO
 	return
 }
+
+// render renders words to a WAV file
+func (s *Speaking) render(i string) (b []byte, err error) {
+	err = errors.New("astispeaking: rendering is not supported on darwin")
+	return
+}
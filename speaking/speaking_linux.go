@@ -1,6 +1,8 @@
 package astispeaking
 
 import (
+	"bytes"
+	"context"
 	"os/exec"
 	"strings"
 
@@ -8,20 +10,53 @@ import (
 	"github.com/pkg/errors"
 )
 
-// say says words
-func (s *Speaking) say(i string) (err error) {
-	// Init args
-	var args []string
+// args returns the binary args
+func (s *Speaking) args(i string, extra ...string) (args []string) {
 	if len(s.o.Voice) > 0 {
 		args = append(args, "-v", s.o.Voice)
 	}
+	args = append(args, extra...)
 	args = append(args, i)
+	return
+}
+
+// say says words
+func (s *Speaking) say(i string) (err error) {
+	// Init cmd
+	var cmd = exec.Command(s.o.BinaryPath, s.args(i)...)
+
+	// Exec
+	astilog.Debugf("astispeaking: executing %s", strings.Join(cmd.Args, " "))
+	var b []byte
+	if b, err = cmd.CombinedOutput(); err != nil {
+		err = errors.Wrapf(err, "astispeaking: running %s failed with combined output %s", strings.Join(cmd.Args, " "), b)
+		return
+	}
+	return
+}
 
+// render renders words to a WAV file
+func (s *Speaking) render(i string) (b []byte, err error) {
 	// Init cmd
-	var cmd = exec.Command(s.o.BinaryPath, args...)
+	var cmd = exec.Command(s.o.BinaryPath, s.args(i, "--stdout")...)
+	var stderr = &bytes.Buffer{}
+	cmd.Stderr = stderr
 
 	// Exec
 	astilog.Debugf("astispeaking: executing %s", strings.Join(cmd.Args, " "))
+	if b, err = cmd.Output(); err != nil {
+		err = errors.Wrapf(err, "astispeaking: running %s failed with stderr %s", strings.Join(cmd.Args, " "), stderr.Bytes())
+		return
+	}
+	return
+}
+
+// checkHealth checks whether the binary is runnable
+func (s *Speaking) checkHealth(ctx context.Context) (err error) {
+	// Init cmd
+	var cmd = exec.CommandContext(ctx, s.o.BinaryPath, "--version")
+
+	// Exec
 	var b []byte
 	if b, err = cmd.CombinedOutput(); err != nil {
 		err = errors.Wrapf(err, "astispeaking: running %s failed with combined output %s", strings.Join(cmd.Args, " "), b)
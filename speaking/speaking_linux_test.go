@@ -0,0 +1,56 @@
+package astispeaking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEspeak creates an executable printing its args to stdout and appending them to a file
+func fakeEspeak(t *testing.T, dir string) (binary, output string) {
+	binary = filepath.Join(dir, "espeak")
+	output = filepath.Join(dir, "output")
+	err := ioutil.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\" | tee -a "+output+"\n"), 0755)
+	assert.NoError(t, err)
+	return
+}
+
+func TestSpeaking(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astispeaking")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	binary, output := fakeEspeak(t, dir)
+
+	// Play locally
+	s := New(Options{BinaryPath: binary, Voice: "fr"})
+	assert.NoError(t, s.Say("hello"))
+	b, err := ioutil.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "-v fr hello\n", string(b))
+
+	// Render
+	s = New(Options{BinaryPath: binary, Render: true})
+	var wavs []string
+	s.OnAudio(func(wav []byte) { wavs = append(wavs, string(wav)) })
+	s.OnAudio(func(wav []byte) { wavs = append(wavs, string(wav)) })
+	assert.NoError(t, s.Say("hello"))
+	assert.Equal(t, []string{"--stdout hello\n", "--stdout hello\n"}, wavs)
+
+	// Muted
+	assert.NoError(t, os.Remove(output))
+	s.Mute()
+	assert.NoError(t, s.Say("hello"))
+	assert.Len(t, wavs, 2)
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+	s.Unmute()
+	assert.NoError(t, s.Say("hello"))
+	assert.Len(t, wavs, 4)
+
+	// Binary fails
+	s = New(Options{BinaryPath: filepath.Join(dir, "missing"), Render: true})
+	assert.Error(t, s.Say("hello"))
+}
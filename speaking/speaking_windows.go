@@ -1,6 +1,8 @@
 package astispeaking
 
 import (
+	"context"
+
 	"github.com/asticode/go-astilog"
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
@@ -48,18 +50,17 @@ func (s *Speaking) Close() (err error) {
 	return
 }
 
-// say says words
-func (s *Speaking) say(i string) (err error) {
-	// Get muted attribute
-	s.m.Lock()
-	m := s.isMuted
-	s.m.Unlock()
-
-	// Do nothing if muted
-	if m {
+// checkHealth checks whether ole has been initialized properly
+func (s *Speaking) checkHealth(ctx context.Context) (err error) {
+	if s.windowsIDispatch == nil {
+		err = errors.New("astispeaking: ole IDispatch is not initialized")
 		return
 	}
+	return
+}
 
+// say says words
+func (s *Speaking) say(i string) (err error) {
 	// Init has not been executed
 	if s.windowsIDispatch == nil {
 		err = errors.New("astispeaking: the Init() method should be called before running anything else")
@@ -80,3 +81,9 @@ func (s *Speaking) say(i string) (err error) {
 	}
 	return
 }
+
+// render renders words to a WAV file
+func (s *Speaking) render(i string) (b []byte, err error) {
+	err = errors.New("astispeaking: rendering is not supported on windows")
+	return
+}